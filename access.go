@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ReadAccessConfig extends or restricts the paths read_file may read.
+//
+//	read_access:
+//	  allow: ["../shared-protos", "~/src/common"]
+//	  deny: ["*.pem", "secrets/", "/etc/**"]
+//
+// Allow entries are directories, relative to the project root or absolute
+// ("~/" is the home directory). Deny entries are gitignore-style patterns:
+// without a slash they match a name at any depth of any allowed directory,
+// with a slash they are anchored to the project root, or to the filesystem
+// root when absolute. Deny patterns override every allowed directory.
+// Allow is read from the global config only, the Deny entries of the
+// project config add to the global ones.
+type ReadAccessConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// ReadAccess is the read_access section of the config, resolved against the
+// project root by initSecurity.
+var ReadAccess ReadAccessConfig
+
+// deniedPaths matches the absolute paths denied by ReadAccess.Deny,
+// without their leading slash.
+var deniedPaths = &IgnoreMatcher{}
+
+// expandPath resolves a config path: "~/" prefix, then relative to root.
+func expandPath(root string, p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, strings.TrimPrefix(p, "~"))
+		}
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	return filepath.Clean(p)
+}
+
+// initReadAccess adds the allowed directories of the config to
+// AllowedPathPrefixes and compiles its deny patterns.
+func initReadAccess(rootPath string) {
+	for _, dir := range ReadAccess.Allow {
+		AllowedPathPrefixes = append(AllowedPathPrefixes, expandPath(rootPath, dir))
+	}
+
+	deniedPaths = &IgnoreMatcher{}
+	for _, pattern := range ReadAccess.Deny {
+		dirOnly := strings.HasSuffix(pattern, "/")
+		p := strings.TrimRight(pattern, "/")
+		if strings.Contains(p, "/") || strings.HasPrefix(p, "~") {
+			// Anchored: make it absolute
+			p = filepath.ToSlash(expandPath(rootPath, p))
+		}
+		if dirOnly {
+			p += "/"
+		}
+		if rule, ok := parseIgnoreLine(p, ""); ok {
+			deniedPaths.rules = append(deniedPaths.rules, rule)
+		}
+	}
+}
+
+// isDeniedPath reports whether the absolute, clean path target or one of its
+// parent directories matches a deny pattern.
+func isDeniedPath(target string) bool {
+	if len(deniedPaths.rules) == 0 {
+		return false
+	}
+	rel := strings.TrimPrefix(filepath.ToSlash(target), "/")
+	for dir := path.Dir(rel); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if deniedPaths.Match(dir, true) {
+			return true
+		}
+	}
+	return deniedPaths.Match(rel, false)
+}
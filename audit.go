@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AuditDir holds the audit log of the MCP tool calls, one JSONL file per
+// day, relative to the project root.
+const AuditDir = ".codemcp/audit"
+
+// maxAuditArg is the length above which string arguments (e.g. the content
+// of write_file) are truncated in the audit log.
+const maxAuditArg = 512
+
+// AuditEnabled turns the audit log on.
+// Set via the --audit flag, audit in the config or CODEMCP_AUDIT.
+var AuditEnabled = true
+
+// AuditEntry is a line of the audit log.
+type AuditEntry struct {
+	Time        time.Time      `json:"time"`
+	Tool        string         `json:"tool"`
+	Arguments   map[string]any `json:"arguments,omitempty"`
+	Paths       []string       `json:"paths,omitempty"`
+	Session     string         `json:"session,omitempty"`
+	Outcome     string         `json:"outcome"` // "ok", "error" (tool error), "failed" or "cancelled"
+	Error       string         `json:"error,omitempty"`
+	ResultBytes int            `json:"result_bytes"`
+	DurationMS  int64          `json:"duration_ms"`
+}
+
+// AuditLog appends entries to the daily file of its directory. Files are
+// only ever appended to.
+type AuditLog struct {
+	mu   sync.Mutex
+	dir  string
+	day  string
+	file *os.File
+}
+
+// NewAuditLog returns an audit log writing under dir, created on first use.
+func NewAuditLog(dir string) *AuditLog {
+	return &AuditLog{dir: dir}
+}
+
+// Write appends entry to the file of its day.
+func (a *AuditLog) Write(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	day := entry.Time.Format(time.DateOnly)
+	if a.file == nil || day != a.day {
+		if a.file != nil {
+			_ = a.file.Close()
+			a.file = nil
+		}
+		if err := os.MkdirAll(a.dir, 0o700); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(filepath.Join(a.dir, day+".jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		a.file, a.day = f, day
+	}
+	_, err = a.file.Write(append(line, '\n'))
+	return err
+}
+
+// auditMiddleware records every tool call in the audit log of the project.
+func auditMiddleware(rootPath string) server.ToolHandlerMiddleware {
+	audit := NewAuditLog(filepath.Join(rootPath, AuditDir))
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, request)
+
+			entry := AuditEntry{
+				Time:       start.UTC(),
+				Tool:       request.Params.Name,
+				Arguments:  auditArguments(request.GetArguments()),
+				Paths:      toolPaths(projectRoot(ctx, rootPath), request),
+				Outcome:    "ok",
+				DurationMS: time.Since(start).Milliseconds(),
+			}
+			if session := server.ClientSessionFromContext(ctx); session != nil {
+				entry.Session = session.SessionID()
+			}
+			switch {
+			case err != nil:
+				entry.Outcome, entry.Error = "failed", err.Error()
+			case ctx.Err() != nil:
+				entry.Outcome = "cancelled"
+			case result != nil && result.IsError:
+				entry.Outcome = "error"
+			}
+			if result != nil {
+				for _, c := range result.Content {
+					if text, ok := c.(mcp.TextContent); ok {
+						entry.ResultBytes += len(text.Text)
+						if result.IsError && entry.Error == "" {
+							entry.Error = text.Text
+						}
+					}
+				}
+			}
+			if werr := audit.Write(entry); werr != nil {
+				slog.Warn("audit log write failed", "err", werr)
+			}
+			return result, err
+		}
+	}
+}
+
+// auditArguments copies args, truncating the long strings.
+func auditArguments(args map[string]any) map[string]any {
+	if len(args) == 0 {
+		return nil
+	}
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		if s, ok := v.(string); ok && len(s) > maxAuditArg {
+			v = fmt.Sprintf("%s... (%d bytes)", s[:maxAuditArg], len(s))
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// toolPaths returns the absolute paths a tool call reads or writes: its path
+// argument, or the files of an apply_patch diff.
+func toolPaths(rootPath string, request mcp.CallToolRequest) []string {
+	var paths []string
+	if p := request.GetString("path", ""); p != "" {
+		paths = append(paths, filepath.Clean(resolvePath(rootPath, p)))
+	}
+	if patch := request.GetString("patch", ""); patch != "" {
+		files, _ := ParsePatch(patch)
+		for _, fp := range files {
+			for _, p := range []string{fp.OldPath, fp.NewPath} {
+				if p != "" && (len(paths) == 0 || paths[len(paths)-1] != filepath.Clean(resolvePath(rootPath, p))) {
+					paths = append(paths, filepath.Clean(resolvePath(rootPath, p)))
+				}
+			}
+		}
+	}
+	return paths
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxQueryLine bounds the length of a --stdin query line.
+const maxQueryLine = 1 << 20
+
+// runBatch reads one query per line from r and writes the results of each as
+// a JSON line on stdout, in input order, sharing the language servers across
+// queries. Blank lines are skipped. A failed search is reported in the error
+// field of its line. It returns the exit code.
+func runBatch(r io.Reader, absPath string, opts SearchOptions) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	enc := json.NewEncoder(os.Stdout)
+	code := ExitFound
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxQueryLine)
+	for scanner.Scan() {
+		query := strings.TrimSpace(scanner.Text())
+		if query == "" {
+			continue
+		}
+		start := time.Now()
+		results, err := Search(ctx, absPath, query, opts, nil)
+		if ctx.Err() != nil {
+			return ExitFailure
+		}
+		output := CLIOutput{
+			Query:    query,
+			Ref:      opts.Ref,
+			Worktree: FindWorktree(ctx, absPath),
+			Duration: time.Since(start).String(),
+			Count:    len(results),
+			Files:    results,
+		}
+		if err != nil {
+			slog.Error("search failed", "query", query, "err", err)
+			output.Error = err.Error()
+			code = ExitFailure
+		}
+		if output.Files == nil {
+			output.Files = []FileScore{}
+		}
+		_ = enc.Encode(output)
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Error("cannot read the queries", "err", err)
+		return ExitFailure
+	}
+	return code
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"strings"
+)
+
+// DefaultBranch returns the default branch of the repository of root: the
+// HEAD of origin, else the first of main and master that exists, locally or
+// on origin. It returns "" when none is found.
+func DefaultBranch(ctx context.Context, root string) string {
+	if out, err := runGit(ctx, root, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		return strings.TrimSpace(out)
+	}
+	for _, ref := range []string{"main", "master", "origin/main", "origin/master"} {
+		if _, err := runGit(ctx, root, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err == nil {
+			return ref
+		}
+	}
+	return ""
+}
+
+// BranchChanges returns the files of root changed on the current branch,
+// relative to root: those differing from the merge-base with the default
+// branch (committed or not), plus the untracked ones. On the default branch,
+// only the uncommitted changes remain. It returns nil outside a git
+// repository.
+func BranchChanges(ctx context.Context, root string) map[string]bool {
+	base := "HEAD"
+	if branch := DefaultBranch(ctx, root); branch != "" {
+		if out, err := runGit(ctx, root, "merge-base", "HEAD", branch); err == nil {
+			base = strings.TrimSpace(out)
+		}
+	}
+	diff, err := runGit(ctx, root, "diff", "--name-only", "-z", "--relative", "--no-renames", base, "--")
+	if err != nil {
+		return nil
+	}
+	untracked, _ := runGit(ctx, root, "ls-files", "-z", "-o", "--exclude-standard")
+
+	changed := make(map[string]bool)
+	for _, f := range strings.Split(diff+untracked, "\x00") {
+		if f != "" {
+			changed[f] = true
+		}
+	}
+	return changed
+}
+
+// BoostChanged adds Weights.BranchChange to the matched files changed on the
+// current branch, listed by changed, with a "branch-changed" reason.
+func BoostChanged(results []FileScore, changed map[string]bool) []FileScore {
+	if Weights.BranchChange == 0 || len(changed) == 0 {
+		return results
+	}
+	for i, r := range results {
+		if r.Score > 0 && changed[r.Path] {
+			results[i].Score = max(1, r.Score+Weights.BranchChange)
+			results[i].Reasons = append(results[i].Reasons, "branch-changed")
+		}
+	}
+	return results
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// MaxResultBytes is the maximum size of the text of a tool result (about 4
+// bytes per token), 0 for no limit.
+// Set via max_result_bytes in the config, the --max-result-bytes flag or
+// CODEMCP_MAX_RESULT_BYTES.
+var MaxResultBytes = 100_000
+
+// pagedTools are the tools fitting their result in MaxResultBytes
+// themselves, with a cursor to the rest.
+var pagedTools = map[string]bool{"read_file": true, "grep_files": true, "search_files": true}
+
+// cutText returns the length of the longest prefix of text of at most limit
+// bytes, ending with a line when text has one in that prefix, never splitting
+// a UTF-8 character.
+func cutText(text string, limit int) int {
+	if len(text) <= limit {
+		return len(text)
+	}
+	n := limit
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	if i := strings.LastIndexByte(text[:n], '\n'); i >= 0 {
+		return i + 1
+	}
+	return n
+}
+
+// fitLines returns the number of lines, each ending with a newline, fitting
+// in MaxResultBytes, at least one so that every page makes progress. A line
+// longer than the limit is cut to it.
+func fitLines(lines []string) int {
+	if MaxResultBytes <= 0 || len(lines) == 0 {
+		return len(lines)
+	}
+	if len(lines[0]) > MaxResultBytes {
+		lines[0] = lines[0][:cutText(lines[0], MaxResultBytes)] + "\n"
+	}
+	size := 0
+	for i, line := range lines {
+		if size += len(line); size > MaxResultBytes && i > 0 {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// pageSearch returns output with the files from offset that fit in
+// MaxResultBytes, at least one, and the offset of the next page when files
+// are left.
+func pageSearch(output CLIOutput, offset int) CLIOutput {
+	start := min(max(offset, 0), len(output.Files))
+	files := output.Files[start:]
+	fit := len(files)
+	for {
+		output.Files = files[:fit]
+		output.Count = fit
+		output.NextOffset = 0
+		if fit < len(files) {
+			output.NextOffset = start + fit
+		}
+		if fit <= 1 || MaxResultBytes <= 0 {
+			return output
+		}
+		data, _ := json.MarshalIndent(output, "", "  ")
+		if len(data) <= MaxResultBytes {
+			return output
+		}
+		// Files have similar sizes: jump close to the fitting count
+		fit = max(1, min(fit-1, fit*MaxResultBytes/len(data)))
+	}
+}
+
+// truncationMarker is appended to a truncated text, on a line of its own.
+func truncationMarker(text string, format string, args ...any) string {
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text + "[truncated: " + fmt.Sprintf(format, args...) + "]"
+}
+
+// budgetMiddleware truncates the text of the tool results larger than
+// MaxResultBytes, dropping their structured content. Must run inside the
+// audit middleware, so the log records what the client got.
+func budgetMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			result, err := next(ctx, request)
+			if result == nil || MaxResultBytes <= 0 || pagedTools[request.Params.Name] {
+				return result, err
+			}
+
+			total := 0
+			for _, c := range result.Content {
+				if text, ok := c.(mcp.TextContent); ok {
+					total += len(text.Text)
+				}
+			}
+			if total <= MaxResultBytes {
+				return result, err
+			}
+
+			left := MaxResultBytes
+			content := make([]mcp.Content, 0, len(result.Content))
+			for _, c := range result.Content {
+				text, ok := c.(mcp.TextContent)
+				if !ok {
+					content = append(content, c)
+					continue
+				}
+				if left <= 0 {
+					break
+				}
+				n := cutText(text.Text, left)
+				left -= n
+				if n < len(text.Text) || left <= 0 {
+					text.Text = truncationMarker(text.Text[:n], "%d of %d bytes shown, over the %d bytes result limit; narrow the request to get the rest", MaxResultBytes-left, total, MaxResultBytes)
+					left = 0
+				}
+				content = append(content, text)
+			}
+			result.Content = content
+			// Its text no longer holds all of it
+			result.StructuredContent = nil
+			return result, err
+		}
+	}
+}
+
+// pageText returns the part of the content of a file read_file returns from
+// offset: at most MaxResultBytes, followed by a truncation marker giving the
+// offset of the rest.
+func pageText(text string, offset int) string {
+	offset = min(max(offset, 0), len(text))
+	for offset > 0 && offset < len(text) && !utf8.RuneStart(text[offset]) {
+		offset--
+	}
+	page := text[offset:]
+	if MaxResultBytes <= 0 || len(page) <= MaxResultBytes {
+		return page
+	}
+	n := cutText(page, MaxResultBytes)
+	if n == 0 {
+		// A character larger than the limit
+		_, n = utf8.DecodeRuneInString(page)
+	}
+	first := strings.Count(text[:offset], "\n") + 1
+	last := first + strings.Count(page[:n-1], "\n")
+	return truncationMarker(page[:n], "lines %d-%d, bytes %d-%d of %d shown; call read_file with offset=%d for the rest",
+		first, last, offset, offset+n, len(text), offset+n)
+}
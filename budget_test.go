@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// withMaxResultBytes sets MaxResultBytes for the test.
+func withMaxResultBytes(t *testing.T, n int) {
+	t.Helper()
+	saved := MaxResultBytes
+	MaxResultBytes = n
+	t.Cleanup(func() { MaxResultBytes = saved })
+}
+
+func TestFitLines(t *testing.T) {
+	withMaxResultBytes(t, 10)
+	tests := []struct {
+		lines []string
+		want  int
+	}{
+		{nil, 0},
+		{[]string{"abc\n", "def\n"}, 2},
+		{[]string{"abc\n", "def\n", "ghi\n"}, 2},
+		{[]string{strings.Repeat("x", 50) + "\n", "def\n"}, 1},
+	}
+	for _, tt := range tests {
+		if got := fitLines(tt.lines); got != tt.want {
+			t.Errorf("fitLines(%q) = %d, want %d", tt.lines, got, tt.want)
+		}
+	}
+	long := []string{strings.Repeat("x", 50) + "\n"}
+	fitLines(long)
+	if len(long[0]) > 11 {
+		t.Errorf("long line kept %d bytes", len(long[0]))
+	}
+}
+
+func TestPageSearch(t *testing.T) {
+	output := CLIOutput{Query: "q", Files: make([]FileScore, 40)}
+	for i := range output.Files {
+		output.Files[i] = FileScore{Path: fmt.Sprintf("pkg/file%02d.go", i), Score: 100 - i}
+	}
+	output.Count = len(output.Files)
+	withMaxResultBytes(t, 1000)
+
+	var seen []string
+	offset := 0
+	for pages := 0; ; pages++ {
+		if pages > len(output.Files) {
+			t.Fatal("paging does not end")
+		}
+		page := pageSearch(output, offset)
+		data, _ := json.MarshalIndent(page, "", "  ")
+		if len(data) > MaxResultBytes && page.Count > 1 {
+			t.Errorf("page at %d is %d bytes", offset, len(data))
+		}
+		if page.Count != len(page.Files) {
+			t.Errorf("count = %d, want %d", page.Count, len(page.Files))
+		}
+		for _, f := range page.Files {
+			seen = append(seen, f.Path)
+		}
+		if page.NextOffset == 0 {
+			break
+		}
+		offset = page.NextOffset
+	}
+	if len(seen) != len(output.Files) {
+		t.Fatalf("got %d files over the pages, want %d", len(seen), len(output.Files))
+	}
+	for i, path := range seen {
+		if path != output.Files[i].Path {
+			t.Fatalf("file %d = %s, want %s", i, path, output.Files[i].Path)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"go/build"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// BuildConfig holds the build constraints Go files are analyzed with.
+//
+//	build:
+//	  tags: [integration]
+//	  goos: linux
+//	  goarch: arm64
+type BuildConfig struct {
+	Tags   []string `yaml:"tags"`
+	GOOS   string   `yaml:"goos"`
+	GOARCH string   `yaml:"goarch"`
+}
+
+// Build is the active build configuration, from the build config section.
+// Unset fields default to the host platform and no tags.
+var Build BuildConfig
+
+// buildExcludedPenalty divides the score of files the build constraints exclude.
+const buildExcludedPenalty = 2
+
+// Env returns the environment passing the configuration to gopls.
+func (b BuildConfig) Env() []string {
+	var env []string
+	if b.GOOS != "" {
+		env = append(env, "GOOS="+b.GOOS)
+	}
+	if b.GOARCH != "" {
+		env = append(env, "GOARCH="+b.GOARCH)
+	}
+	if len(b.Tags) > 0 {
+		env = append(env, "GOFLAGS=-tags="+strings.Join(b.Tags, ","))
+	}
+	return env
+}
+
+var (
+	buildTagRe  = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+	buildPlatRe = regexp.MustCompile(`^[a-z0-9]+$`)
+)
+
+// valid reports whether the tags and platform of b are plain names, which
+// cannot smuggle another go flag (-toolexec...) into GOFLAGS.
+func (b BuildConfig) valid() bool {
+	for _, tag := range b.Tags {
+		if !buildTagRe.MatchString(tag) {
+			return false
+		}
+	}
+	return (b.GOOS == "" || buildPlatRe.MatchString(b.GOOS)) &&
+		(b.GOARCH == "" || buildPlatRe.MatchString(b.GOARCH))
+}
+
+// tagsOnlyGOFLAGS reports whether the GOFLAGS value flags only sets build
+// tags, e.g. "-tags=integration,e2e".
+func tagsOnlyGOFLAGS(flags string) bool {
+	for _, f := range strings.Fields(flags) {
+		// -tags= or --tags=
+		tags, ok := strings.CutPrefix(strings.TrimLeft(f, "-"), "tags=")
+		if !ok || !strings.HasPrefix(f, "-") {
+			return false
+		}
+		for _, tag := range strings.Split(tags, ",") {
+			if tag != "" && !buildTagRe.MatchString(tag) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// GoFileExcluded reports whether the build constraints exclude the Go file
+// at absPath, through its name (foo_windows.go) or its //go:build line.
+func GoFileExcluded(absPath string) bool {
+	ctxt := build.Default
+	if Build.GOOS != "" {
+		ctxt.GOOS = Build.GOOS
+	}
+	if Build.GOARCH != "" {
+		ctxt.GOARCH = Build.GOARCH
+	}
+	ctxt.BuildTags = Build.Tags
+	match, err := ctxt.MatchFile(filepath.Dir(absPath), filepath.Base(absPath))
+	return err == nil && !match
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CacheDir is the query cache location, relative to the project root.
+const CacheDir = ".codemcp/cache"
+
+// maxCacheEntries bounds the number of cached queries kept on disk.
+const maxCacheEntries = 100
+
+// QueryCacheEntry is the on-disk form of a cached search.
+type QueryCacheEntry struct {
+	Query       string        `json:"query"`
+	Options     SearchOptions `json:"options"`
+	Settings    string        `json:"settings"`
+	Fingerprint string        `json:"fingerprint"`
+	Files       []FileScore   `json:"files"`
+}
+
+// ProjectFingerprint hashes the path, size and modification time of every
+// project file, plus go.mod/go.sum which determine the dependency results.
+// Any change in the tree produces a different fingerprint.
+func ProjectFingerprint(ctx context.Context, root string) (string, error) {
+	files, err := WorkspaceFiles(ctx, root)
+	if err != nil {
+		return "", err
+	}
+	files = append(files, "go.mod", "go.sum", "go.work")
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, f := range files {
+		// Skip empty entries and our own cache, which changes on every store
+		if f == "" || strings.HasPrefix(filepath.ToSlash(f), ".codemcp/") {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(root, f))
+		if err != nil {
+			// Deleted (or not yet created) files still count
+			fmt.Fprintf(h, "%s\x00-\n", f)
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", f, info.Size(), info.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CacheSettings hashes the settings the results of a search depend on
+// besides its query and options: the language server flags, the file
+// selection and the global and project config files of root.
+func CacheSettings(root string, gopls, rustAnalyzer bool) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "gopls=%t\x00rust-analyzer=%t\x00node-modules=%t\x00untracked=%t\x00ignored=%t\x00max-results=%d\n",
+		gopls, rustAnalyzer, NodeModules, IncludeUntracked, IncludeIgnored, MaxResults)
+	paths := []string{filepath.Join(root, ConfigFile)}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, GlobalConfigFile))
+	}
+	for _, path := range paths {
+		// A missing file hashes as an empty one
+		data, _ := os.ReadFile(path)
+		fmt.Fprintf(h, "%s\x00%d\n", path, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheEntryPath returns the file caching query for root.
+func cacheEntryPath(root string, query string, opts SearchOptions, settings string) string {
+	options, _ := json.Marshal(opts)
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%s\x00%s", settings, options, query))
+	return filepath.Join(root, CacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// LoadCachedQuery returns the cached results of query if they were computed
+// with the same CacheSettings against the same project fingerprint.
+func LoadCachedQuery(root string, query string, opts SearchOptions, settings string, fingerprint string) ([]FileScore, bool) {
+	data, err := os.ReadFile(cacheEntryPath(root, query, opts, settings))
+	if err != nil {
+		return nil, false
+	}
+	var entry QueryCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if entry.Query != query || entry.Options != opts || entry.Settings != settings || entry.Fingerprint != fingerprint {
+		return nil, false
+	}
+	return entry.Files, true
+}
+
+// StoreCachedQuery writes the results of query to the cache and evicts the
+// oldest entries beyond maxCacheEntries.
+func StoreCachedQuery(root string, query string, opts SearchOptions, settings string, fingerprint string, files []FileScore) error {
+	dir := filepath.Join(root, CacheDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(QueryCacheEntry{
+		Query:       query,
+		Options:     opts,
+		Settings:    settings,
+		Fingerprint: fingerprint,
+		Files:       files,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so concurrent runs never read a partial entry
+	path := cacheEntryPath(root, query, opts, settings)
+	tmp, err := os.CreateTemp(dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	pruneCache(dir)
+	return nil
+}
+
+// pruneCache removes the least recently written entries beyond maxCacheEntries.
+func pruneCache(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) <= maxCacheEntries {
+		return
+	}
+
+	type aged struct {
+		name  string
+		mtime int64
+	}
+	var files []aged
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && filepath.Ext(e.Name()) == ".json" {
+			files = append(files, aged{e.Name(), info.ModTime().UnixNano()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mtime > files[j].mtime })
+	for _, f := range files[min(maxCacheEntries, len(files)):] {
+		_ = os.Remove(filepath.Join(dir, f.name))
+	}
+}
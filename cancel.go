@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// requestIDMeta is the _meta field carrying the JSON-RPC ID of a tool call
+// from the hook that sees it to the handler middleware, which does not.
+const requestIDMeta = "codemcp/requestId"
+
+// errCancelled is the cause of the context of a tool call cancelled by the
+// client.
+var errCancelled = errors.New("cancelled by the client")
+
+// inflightCalls maps "session ID/request ID" to the context.CancelCauseFunc
+// of the tool calls running.
+var inflightCalls sync.Map
+
+// handleCancellation makes notifications/cancelled cancel the context of the
+// tool call it names, with cancelMiddleware, which stops its search and
+// language server requests ($/cancelRequest).
+func handleCancellation(s *server.MCPServer, hooks *server.Hooks) {
+	hooks.AddBeforeCallTool(func(ctx context.Context, id any, request *mcp.CallToolRequest) {
+		if id == nil {
+			return
+		}
+		if request.Params.Meta == nil {
+			request.Params.Meta = &mcp.Meta{}
+		}
+		if request.Params.Meta.AdditionalFields == nil {
+			request.Params.Meta.AdditionalFields = make(map[string]any)
+		}
+		request.Params.Meta.AdditionalFields[requestIDMeta] = mcp.NewRequestId(id).String()
+	})
+
+	s.AddNotificationHandler("notifications/cancelled", func(ctx context.Context, notification mcp.JSONRPCNotification) {
+		data, err := json.Marshal(notification.Params.AdditionalFields)
+		var params mcp.CancelledNotificationParams
+		if err != nil || json.Unmarshal(data, &params) != nil {
+			return
+		}
+		if cancel, ok := inflightCalls.Load(callKey(ctx, params.RequestId.String())); ok {
+			slog.Debug("tool call cancelled", "request", params.RequestId.String(), "reason", params.Reason)
+			cancel.(context.CancelCauseFunc)(errCancelled)
+		}
+	})
+}
+
+// cancelMiddleware runs the tool calls with a context notifications/cancelled
+// cancels, and reports them as cancelled. It must wrap the other middlewares.
+func cancelMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			var requestID string
+			if meta := request.Params.Meta; meta != nil {
+				requestID, _ = meta.AdditionalFields[requestIDMeta].(string)
+				delete(meta.AdditionalFields, requestIDMeta)
+			}
+			if requestID == "" {
+				return next(ctx, request)
+			}
+
+			ctx, cancel := context.WithCancelCause(ctx)
+			key := callKey(ctx, requestID)
+			inflightCalls.Store(key, cancel)
+			defer func() {
+				inflightCalls.Delete(key)
+				cancel(nil)
+			}()
+
+			result, err := next(ctx, request)
+			if errors.Is(context.Cause(ctx), errCancelled) {
+				return mcp.NewToolResultError("Cancelled: " + errCancelled.Error()), nil
+			}
+			return result, err
+		}
+	}
+}
+
+// callKey returns the inflightCalls key of a request of the MCP session of
+// ctx.
+func callKey(ctx context.Context, requestID string) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID() + "/" + requestID
+	}
+	return "/" + requestID
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// compileCommandsDirs are the directories, relative to the project root,
+// where a compile_commands.json is looked for (clangd searches the same ones).
+var compileCommandsDirs = []string{".", "build"}
+
+// clangLanguage describes clangd, used for C/C++ projects with a compilation
+// database.
+func clangLanguage() Language {
+	var markers []string
+	for _, dir := range compileCommandsDirs {
+		markers = append(markers, filepath.Join(dir, "compile_commands.json"))
+	}
+	return Language{
+		Name:        "c",
+		Command:     []string{"clangd"},
+		VersionArgs: []string{"--version"},
+		Markers:     markers,
+		ReadPaths:   compileCommandsIncludes,
+	}
+}
+
+// compileCommand is an entry of a compile_commands.json file.
+type compileCommand struct {
+	Directory string   `json:"directory"`
+	Command   string   `json:"command"`
+	Arguments []string `json:"arguments"`
+}
+
+// compileCommandsIncludes returns the include directories outside root
+// (-I, -isystem, -iquote, -idirafter) referenced by the compilation database,
+// so read_file can open the system headers clangd points to.
+func compileCommandsIncludes(root string) []string {
+	seen := make(map[string]bool)
+	for _, dir := range compileCommandsDirs {
+		data, err := os.ReadFile(filepath.Join(root, dir, "compile_commands.json"))
+		if err != nil {
+			continue
+		}
+		var commands []compileCommand
+		if err := json.Unmarshal(data, &commands); err != nil {
+			continue
+		}
+		for _, cc := range commands {
+			args := cc.Arguments
+			if len(args) == 0 {
+				args = strings.Fields(cc.Command)
+			}
+			for _, inc := range includeDirs(args) {
+				if !filepath.IsAbs(inc) {
+					inc = filepath.Join(cc.Directory, inc)
+				}
+				inc = filepath.Clean(inc)
+				if inc != root && !strings.HasPrefix(inc, root+string(os.PathSeparator)) {
+					seen[inc] = true
+				}
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for p := range seen {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// includeDirs extracts the include directories from compiler arguments,
+// both in the "-Idir" and "-I dir" forms.
+func includeDirs(args []string) []string {
+	var dirs []string
+	for i := 0; i < len(args); i++ {
+		arg := strings.Trim(args[i], `"'`)
+		for _, flag := range []string{"-isystem", "-iquote", "-idirafter", "-I"} {
+			if !strings.HasPrefix(arg, flag) {
+				continue
+			}
+			if dir := strings.TrimPrefix(arg, flag); dir != "" {
+				dirs = append(dirs, dir)
+			} else if i+1 < len(args) {
+				i++
+				dirs = append(dirs, strings.Trim(args[i], `"'`))
+			}
+			break
+		}
+	}
+	return dirs
+}
+
+// cHeaderExts and cSourceExts classify C/C++ files for header–implementation pairing.
+var (
+	cHeaderExts = map[string]bool{".h": true, ".hh": true, ".hpp": true, ".hxx": true}
+	cSourceExts = map[string]bool{".c": true, ".cc": true, ".cpp": true, ".cxx": true}
+)
+
+// pairBoost is added to a matched C/C++ file whose counterpart also matched.
+const pairBoost = 20
+
+// PairHeaders links C/C++ headers and implementations sharing a base name
+// (foo.h <-> foo.c/foo.cpp): a matched file's counterpart gets a "pair:"
+// reason and either pairBoost, if it matched too, or half the file's score.
+// Counterparts in the same directory are preferred over ones elsewhere
+// (e.g. include/foo.h <-> src/foo.c). files lists the project files.
+func PairHeaders(results []FileScore, files []string) []FileScore {
+	byStem := make(map[string][]string)
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f))
+		if cHeaderExts[ext] || cSourceExts[ext] {
+			stem := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+			byStem[stem] = append(byStem[stem], f)
+		}
+	}
+	if len(byStem) == 0 {
+		return results
+	}
+
+	index := make(map[string]int, len(results))
+	for i, r := range results {
+		index[r.Path] = i
+	}
+
+	// Scores before any boost, so the result order does not matter
+	n := len(results)
+	scores := make([]int, n)
+	for i := range n {
+		scores[i] = results[i].Score
+	}
+
+	for i := range n {
+		path := results[i].Path
+		ext := strings.ToLower(filepath.Ext(path))
+		if !cHeaderExts[ext] && !cSourceExts[ext] {
+			continue
+		}
+		reason := "pair:" + filepath.Base(path)
+		for _, other := range counterparts(path, byStem[strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))]) {
+			if j, ok := index[other]; ok {
+				results[j].Score += pairBoost
+				results[j].Reasons = append(results[j].Reasons, reason)
+				continue
+			}
+			index[other] = len(results)
+			results = append(results, FileScore{Path: other, Score: scores[i] / 2, Reasons: []string{reason}})
+		}
+	}
+	return results
+}
+
+// counterparts returns the candidates of the opposite kind (header vs
+// implementation), restricted to path's directory when it holds any.
+func counterparts(path string, candidates []string) []string {
+	isHeader := cHeaderExts[strings.ToLower(filepath.Ext(path))]
+	var sameDir, others []string
+	for _, c := range candidates {
+		if cHeaderExts[strings.ToLower(filepath.Ext(c))] == isHeader {
+			continue
+		}
+		if filepath.Dir(c) == filepath.Dir(path) {
+			sameDir = append(sameDir, c)
+		} else {
+			others = append(others, c)
+		}
+	}
+	if len(sameDir) > 0 {
+		return sameDir
+	}
+	return others
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// completeCommand is the hidden subcommand the completion scripts call to
+// complete query terms: codemcp -path root __complete <prefix>.
+const completeCommand = "__complete"
+
+// SymbolIndexFile caches the symbol names of the project for completion,
+// relative to the project root.
+const SymbolIndexFile = ".codemcp/symbols.json"
+
+// maxCompletions bounds the query terms offered to the shell.
+const maxCompletions = 200
+
+// symbolIndex is the on-disk form of SymbolIndexFile.
+type symbolIndex struct {
+	Fingerprint string   `json:"fingerprint"`
+	Names       []string `json:"names"`
+}
+
+// ProjectSymbols returns the sorted, unique symbol names of the project
+// files. They are read from SymbolIndexFile while the project fingerprint
+// does not change.
+func ProjectSymbols(ctx context.Context, root string) ([]string, error) {
+	fingerprint, err := ProjectFingerprint(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	indexPath := filepath.Join(root, SymbolIndexFile)
+	if data, err := os.ReadFile(indexPath); err == nil {
+		var index symbolIndex
+		if json.Unmarshal(data, &index) == nil && index.Fingerprint == fingerprint {
+			return index.Names, nil
+		}
+	}
+
+	files, err := WorkspaceFiles(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var mu sync.Mutex
+	sem := make(chan struct{}, max(1, Workers))
+	var wg sync.WaitGroup
+	for _, f := range files {
+		extract, ok := FileExtractor(f)
+		if f == "" || !ok {
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(absPath string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			symbols := extract(ctx, absPath)
+			mu.Lock()
+			for _, sym := range symbols {
+				seen[sym.Name] = true
+			}
+			mu.Unlock()
+		}(filepath.Join(root, f))
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if data, err := json.Marshal(symbolIndex{Fingerprint: fingerprint, Names: names}); err == nil {
+		if os.MkdirAll(filepath.Dir(indexPath), 0o755) == nil {
+			_ = os.WriteFile(indexPath, data, 0o644)
+		}
+	}
+	return names, nil
+}
+
+// printCompletions prints the project symbols starting with prefix, one per
+// line, for the completion scripts. A lower case prefix matches any case.
+func printCompletions(ctx context.Context, root string, prefix string) {
+	names, err := ProjectSymbols(ctx, root)
+	if err != nil {
+		return
+	}
+	matches := matchPrefix(names, prefix)
+	for _, name := range matches[:min(len(matches), maxCompletions)] {
+		fmt.Println(name)
+	}
+}
+
+// matchPrefix returns the names starting with prefix, in order. A lower case
+// prefix matches any case.
+func matchPrefix(names []string, prefix string) []string {
+	ignoreCase := prefix == strings.ToLower(prefix)
+	var matches []string
+	for _, name := range names {
+		candidate := name
+		if ignoreCase {
+			candidate = strings.ToLower(name)
+		}
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+// completionFlag describes a flag for the completion scripts.
+type completionFlag struct {
+	Name   string
+	Usage  string
+	IsBool bool
+	Values []string // Fixed values, if any
+	Kind   string   // "dir", "file" or "" for the value completion
+}
+
+// completionFlags returns the flags of the command line, with their value
+// completion.
+func completionFlags() []completionFlag {
+	var flags []completionFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		cf := completionFlag{Name: f.Name, Usage: f.Usage}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			cf.IsBool = true
+		}
+		switch f.Name {
+		case "path", "session-roots":
+			cf.Kind = "dir"
+		case "socket", "log-file", "tls-cert", "tls-key":
+			cf.Kind = "file"
+		case "mode":
+			cf.Values = []string{ModeReadOnly, ModeReadWrite}
+		case "format":
+			cf.Values = Formats
+		case "log-level":
+			cf.Values = []string{"debug", "info", "warn", "error"}
+		}
+		flags = append(flags, cf)
+	})
+	return flags
+}
+
+// printCompletionScript prints the completion script of shell (bash, zsh or
+// fish) for codemcp completion <shell>.
+func printCompletionScript(shell string) error {
+	flags := completionFlags()
+	switch shell {
+	case "bash":
+		printBashCompletion(flags)
+	case "zsh":
+		printZshCompletion(flags)
+	case "fish":
+		printFishCompletion(flags)
+	default:
+		return fmt.Errorf("unsupported shell %q: expected bash, zsh or fish", shell)
+	}
+	return nil
+}
+
+func printBashCompletion(flags []completionFlag) {
+	var all, dirs, files, others []string
+	var values strings.Builder
+	for _, f := range flags {
+		all = append(all, "-"+f.Name)
+		switch {
+		case f.IsBool:
+		case f.Kind == "dir":
+			dirs = append(dirs, "-"+f.Name, "--"+f.Name)
+		case f.Kind == "file":
+			files = append(files, "-"+f.Name, "--"+f.Name)
+		case len(f.Values) > 0:
+			fmt.Fprintf(&values, "        -%s|--%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", f.Name, f.Name, strings.Join(f.Values, " "))
+		default:
+			others = append(others, "-"+f.Name, "--"+f.Name)
+		}
+	}
+	fmt.Printf(`# bash completion for codemcp. Load it with:
+#   source <(codemcp completion bash)
+_codemcp() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+    case "$prev" in
+        %s) COMPREPLY=($(compgen -d -- "$cur")); return ;;
+        %s) COMPREPLY=($(compgen -f -- "$cur")); return ;;
+%s        %s) return ;;
+    esac
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "%s" -- "$cur"))
+        return
+    fi
+    local root=. i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "${COMP_WORDS[i]}" in
+            -path|--path) root="${COMP_WORDS[i+1]}" ;;
+            -path=*|--path=*) root="${COMP_WORDS[i]#*=}" ;;
+        esac
+    done
+    local IFS=$'\n'
+    COMPREPLY=($(codemcp -path "$root" %s "$cur" 2>/dev/null))
+}
+complete -F _codemcp codemcp
+`, strings.Join(dirs, "|"), strings.Join(files, "|"), values.String(), strings.Join(others, "|"), strings.Join(all, " "), completeCommand)
+}
+
+// zshQuote escapes s for a description of a zsh _arguments spec.
+func zshQuote(s string) string {
+	return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func printZshCompletion(flags []completionFlag) {
+	fmt.Print(`#compdef codemcp
+# zsh completion for codemcp. Load it with:
+#   source <(codemcp completion zsh)
+# or save it as _codemcp in a directory of $fpath.
+
+_codemcp_queries() {
+  local root=${opt_args[-path]:-.}
+  local -a terms
+  terms=(${(f)"$(codemcp -path "$root" ` + completeCommand + ` "$PREFIX" 2>/dev/null)"})
+  compadd -a terms
+}
+
+_codemcp() {
+  _arguments \
+`)
+	for _, f := range flags {
+		spec := fmt.Sprintf("'-%s[%s]'", f.Name, zshQuote(f.Usage))
+		if !f.IsBool {
+			action := " "
+			switch {
+			case f.Kind == "dir":
+				action = "_files -/"
+			case f.Kind == "file":
+				action = "_files"
+			case len(f.Values) > 0:
+				action = "(" + strings.Join(f.Values, " ") + ")"
+			}
+			spec = fmt.Sprintf("'-%s=[%s]:%s:%s'", f.Name, zshQuote(f.Usage), f.Name, action)
+		}
+		fmt.Printf("    %s \\\n", spec)
+	}
+	fmt.Print(`    '*:query:_codemcp_queries'
+}
+
+if [ "$funcstack[1]" = "_codemcp" ]; then
+  _codemcp "$@"
+else
+  compdef _codemcp codemcp
+fi
+`)
+}
+
+func printFishCompletion(flags []completionFlag) {
+	fmt.Printf(`# fish completion for codemcp. Load it with:
+#   codemcp completion fish | source
+function __codemcp_root
+    set -l tokens (commandline -opc)
+    for i in (seq (count $tokens))
+        switch $tokens[$i]
+            case -path --path
+                if test $i -lt (count $tokens)
+                    echo $tokens[(math $i + 1)]
+                    return
+                end
+            case '-path=*' '--path=*'
+                string replace -r '^-+path=' '' -- $tokens[$i]
+                return
+        end
+    end
+    echo .
+end
+
+complete -c codemcp -f
+complete -c codemcp -n 'not string match -q -- "-*" (commandline -ct)' -a '(codemcp -path (__codemcp_root) %s (commandline -ct) 2>/dev/null)'
+`, completeCommand)
+	for _, f := range flags {
+		desc := strings.ReplaceAll(f.Usage, "'", `\'`)
+		switch {
+		case f.IsBool:
+			fmt.Printf("complete -c codemcp -o %s -d '%s'\n", f.Name, desc)
+		case f.Kind == "dir":
+			fmt.Printf("complete -c codemcp -o %s -x -a '(__fish_complete_directories (commandline -ct))' -d '%s'\n", f.Name, desc)
+		case f.Kind == "file":
+			fmt.Printf("complete -c codemcp -o %s -r -F -d '%s'\n", f.Name, desc)
+		case len(f.Values) > 0:
+			fmt.Printf("complete -c codemcp -o %s -x -a '%s' -d '%s'\n", f.Name, strings.Join(f.Values, " "), desc)
+		default:
+			fmt.Printf("complete -c codemcp -o %s -x -d '%s'\n", f.Name, desc)
+		}
+	}
+}
+
+// isSubcommand reports whether args name a subcommand rather than a query.
+func isSubcommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch {
+	case len(args) == 1 && args[0] == "daemon":
+		return true
+	case len(args) == 2 && args[0] == "completion":
+		return true
+	case args[0] == completeCommand:
+		return true
+	}
+	return false
+}
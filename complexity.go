@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Limits of the number of functions returned by complexity.
+const (
+	defaultComplexityFuncs = 30
+	maxComplexityFuncs     = 500
+)
+
+// FuncComplexity is the complexity of a Go function.
+type FuncComplexity struct {
+	Path       string `json:"path"`
+	Line       int    `json:"line"`
+	Name       string `json:"name"`       // Type.Method for methods
+	Complexity int    `json:"complexity"` // Cyclomatic, its function literals included
+	Lines      int    `json:"lines"`
+}
+
+// ComplexityReport is the result of the complexity tool.
+type ComplexityReport struct {
+	Functions []FuncComplexity `json:"functions"`
+	Total     int              `json:"total"` // Functions measured, before the limit
+}
+
+// FileComplexity returns the complexity of the functions of the Go file at
+// absPath.
+func FileComplexity(absPath string) ([]FuncComplexity, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, absPath, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	var funcs []FuncComplexity
+	for _, decl := range file.Decls {
+		d, ok := decl.(*ast.FuncDecl)
+		if !ok || d.Body == nil {
+			continue
+		}
+		name := d.Name.Name
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			name = receiverName(d.Recv.List[0].Type) + "." + name
+		}
+		start, end := fset.Position(d.Pos()), fset.Position(d.End())
+		funcs = append(funcs, FuncComplexity{
+			Path:       absPath,
+			Line:       start.Line,
+			Name:       name,
+			Complexity: cyclomatic(d.Body),
+			Lines:      end.Line - start.Line + 1,
+		})
+	}
+	return funcs, nil
+}
+
+// cyclomatic returns the cyclomatic complexity of body: one plus its
+// decision points, the branches of if, for, case and select clauses and
+// the && and || operators.
+func cyclomatic(body *ast.BlockStmt) int {
+	n := 1
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch x := node.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			n++
+		case *ast.CaseClause:
+			// default is the path taken when no case is
+			if x.List != nil {
+				n++
+			}
+		case *ast.CommClause:
+			if x.Comm != nil {
+				n++
+			}
+		case *ast.BinaryExpr:
+			if x.Op == token.LAND || x.Op == token.LOR {
+				n++
+			}
+		}
+		return true
+	})
+	return n
+}
+
+// complexityTool returns the most complex functions of a file or package.
+func complexityTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("complexity",
+		mcp.WithDescription("Measure the Go functions of a file or package (directory): cyclomatic complexity and length in lines, most complex first. Use it to find the worst functions to refactor."),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Go file or package directory (absolute or relative to project root)")),
+		mcp.WithBoolean("recursive", mcp.Description("Include the packages under the directory")),
+		mcp.WithBoolean("tests", mcp.Description("Include the _test.go files of the directory")),
+		mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("Number of functions, %d by default, at most %d", defaultComplexityFuncs, maxComplexityFuncs))),
+		mcp.WithOutputSchema[ComplexityReport](),
+		readOnlyAnnotations("Function complexity"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		pathArg, err := request.RequireString("path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		rootPath := projectRoot(ctx, rootPath)
+		targetPath := resolvePath(rootPath, pathArg)
+		// Security Check
+		if !isAllowedPath(ctx, targetPath) {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Reading %s is not allowed.", pathArg)), nil
+		}
+		info, err := os.Stat(targetPath)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		var files []string
+		switch {
+		case !info.IsDir():
+			if filepath.Ext(targetPath) != ".go" {
+				return mcp.NewToolResultError(fmt.Sprintf("%s is not a Go file", pathArg)), nil
+			}
+			files = []string{targetPath}
+		case request.GetBool("recursive", false):
+			all, err := CollectFiles(ctx, targetPath)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			for _, f := range all {
+				files = append(files, filepath.Join(targetPath, f))
+			}
+		default:
+			entries, err := os.ReadDir(targetPath)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			for _, e := range entries {
+				if !e.IsDir() {
+					files = append(files, filepath.Join(targetPath, e.Name()))
+				}
+			}
+		}
+
+		tests := request.GetBool("tests", false)
+		result := ComplexityReport{Functions: []FuncComplexity{}}
+		for _, f := range files {
+			if filepath.Ext(f) != ".go" || !tests && info.IsDir() && strings.HasSuffix(f, "_test.go") {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			funcs, err := FileComplexity(f)
+			if err != nil {
+				// Unparsable files (e.g. templates named .go) are skipped
+				continue
+			}
+			for _, fn := range funcs {
+				if rel, err := filepath.Rel(rootPath, fn.Path); err == nil && filepath.IsLocal(rel) {
+					fn.Path = filepath.ToSlash(rel)
+				}
+				result.Functions = append(result.Functions, fn)
+			}
+		}
+		sort.SliceStable(result.Functions, func(i, j int) bool {
+			a, b := result.Functions[i], result.Functions[j]
+			if a.Complexity != b.Complexity {
+				return a.Complexity > b.Complexity
+			}
+			return a.Lines > b.Lines
+		})
+		result.Total = len(result.Functions)
+		limit := min(max(request.GetInt("limit", defaultComplexityFuncs), 1), maxComplexityFuncs)
+		if len(result.Functions) > limit {
+			result.Functions = result.Functions[:limit]
+		}
+		return jsonToolResult(result, result), nil
+	}
+}
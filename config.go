@@ -0,0 +1,404 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the per-project configuration file, read from the project root.
+const ConfigFile = ".codemcp.yaml"
+
+// GlobalConfigFile is the user configuration file, relative to the user
+// config directory ($XDG_CONFIG_HOME, ~/.config on Linux). The project
+// config is layered on top of it.
+const GlobalConfigFile = "codemcp/config.yaml"
+
+// Config is the content of the configuration file.
+type Config struct {
+	// LanguageServers maps a language name to the server to spawn for it.
+	// Entries named after a default language ("go", "rust") override it.
+	LanguageServers map[string]LanguageServerConfig `yaml:"language_servers"`
+
+	// ExtensionWeights adds or re-weights entries of the default
+	// ExtensionWeights, e.g. {".kt": 20, ".js": 5}.
+	ExtensionWeights map[string]int `yaml:"extension_weights"`
+
+	// LSPTimeout overrides CallTimeout, e.g. "30s".
+	LSPTimeout time.Duration `yaml:"lsp_timeout"`
+
+	// LSPConcurrency overrides MaxConcurrentCalls.
+	LSPConcurrency int `yaml:"lsp_concurrency"`
+
+	// Build sets the build tags and target platform of Go files, for gopls
+	// and the local analysis.
+	Build BuildConfig `yaml:"build"`
+
+	// IgnoreDirs adds directory names to the default IgnoreDirs.
+	IgnoreDirs []string `yaml:"ignore_dirs"`
+
+	// ScoreWeights overrides entries of Weights, e.g. {"exact_file": 1000}.
+	ScoreWeights map[string]int `yaml:"score_weights"`
+
+	// PathWeights adds a weight to the score of the files matching a glob,
+	// e.g. {"internal/**": 30, "**/testdata/**": -50}. See SetPathWeights.
+	PathWeights map[string]int `yaml:"path_weights"`
+
+	// MaxResults overrides the number of results returned by a search.
+	MaxResults int `yaml:"max_results"`
+
+	// MaxResultBytes overrides the maximum size of a tool result.
+	MaxResultBytes int `yaml:"max_result_bytes"`
+
+	// CallsPerMinute overrides the tool calls allowed to an MCP session per
+	// minute.
+	CallsPerMinute int `yaml:"calls_per_minute"`
+
+	// BytesPerMinute overrides the tool result bytes allowed to an MCP
+	// session per minute.
+	BytesPerMinute int `yaml:"bytes_per_minute"`
+
+	// GrepBackend overrides the content search backend of grep_files: auto,
+	// git or go.
+	GrepBackend string `yaml:"grep_backend"`
+
+	// Linter overrides the linter of the lint tool: auto, staticcheck or
+	// golangci-lint.
+	Linter string `yaml:"linter"`
+
+	// DisabledTools lists MCP tools not to expose, e.g. [outline_markdown].
+	DisabledTools []string `yaml:"disabled_tools"`
+
+	// LogLevel overrides LogLevel: debug, info, warn or error.
+	LogLevel string `yaml:"log_level"`
+
+	// LogFile overrides LogFile. Global config only.
+	LogFile string `yaml:"log_file"`
+
+	// ReadAccess adds directories read_file may read, and denies others.
+	ReadAccess ReadAccessConfig `yaml:"read_access"`
+
+	// Secrets tunes the refusal of secret files and the masking of secrets
+	// in the content returned by read_file.
+	Secrets SecretsConfig `yaml:"secrets"`
+
+	// Audit overrides AuditEnabled, e.g. false to disable the audit log.
+	// Global config only.
+	Audit *bool `yaml:"audit"`
+
+	// Untracked overrides IncludeUntracked, e.g. false to search the files
+	// git tracks only.
+	Untracked *bool `yaml:"untracked"`
+
+	// Ignored overrides IncludeIgnored, e.g. true to search generated code.
+	Ignored *bool `yaml:"ignored"`
+}
+
+// LanguageServerConfig configures one language server.
+//
+//	language_servers:
+//	  python:
+//	    command: ["pyright-langserver", "--stdio"]
+//	    extensions: [".py"]
+//	    markers: ["pyproject.toml"]
+//	  go:
+//	    env:
+//	      GOFLAGS: -tags=integration
+//	      GOPRIVATE: github.com/acme/*
+//	    initialization_options:
+//	      directoryFilters: ["-node_modules"]
+//	      buildFlags: ["-tags=integration"]
+//
+// Command, Env and InitializationOptions run code on the machine (a server
+// command, GOFLAGS=-toolexec, gopls buildFlags): they are read from the
+// global config only, except the variables of projectEnv. See
+// restrictProject.
+type LanguageServerConfig struct {
+	Command               []string          `yaml:"command"`
+	Extensions            []string          `yaml:"extensions"`
+	Markers               []string          `yaml:"markers"`
+	InitializationOptions map[string]any    `yaml:"initialization_options"`
+	Env                   map[string]string `yaml:"env"`
+	Disabled              bool              `yaml:"disabled"`
+}
+
+// LoadConfig reads the global configuration file, then the one of the
+// project at root: project settings override global ones, map entries
+// (language servers, weights) are merged. The settings a cloned repository
+// must not control are kept from the global file, see restrictProject.
+// Missing files are not an error and yield an empty Config.
+func LoadConfig(root string) (*Config, error) {
+	cfg, global := &Config{}, &Config{}
+	if dir, err := os.UserConfigDir(); err == nil {
+		path := filepath.Join(dir, GlobalConfigFile)
+		// Loaded twice so the merge below does not alter global
+		if err := cfg.load(path); err != nil {
+			return cfg, err
+		}
+		if err := global.load(path); err != nil {
+			return cfg, err
+		}
+	}
+	path := filepath.Join(root, ConfigFile)
+	project := &Config{}
+	if err := project.load(path); err != nil {
+		return cfg, err
+	}
+	if err := cfg.load(path); err != nil {
+		return cfg, err
+	}
+	cfg.restrictProject(global, project, path)
+	return cfg, nil
+}
+
+// restrictProject restores the settings of global that the project config
+// at path may not change, logging the ignored ones. The project config comes
+// with the repository, opening a cloned repository must not run its
+// commands.
+func (c *Config) restrictProject(global, project *Config, path string) {
+	ignored := func(key string) {
+		slog.Warn("ignoring a setting of the project config, allowed in the global config only", "file", path, "key", key)
+	}
+	for name, lc := range project.LanguageServers {
+		g := global.LanguageServers[name]
+		merged := c.LanguageServers[name]
+		if len(lc.Command) > 0 {
+			ignored("language_servers." + name + ".command")
+		}
+		if lc.InitializationOptions != nil {
+			ignored("language_servers." + name + ".initialization_options")
+		}
+		merged.Command = g.Command
+		merged.Env = maps.Clone(g.Env)
+		merged.InitializationOptions = g.InitializationOptions
+		for k, v := range lc.Env {
+			if valid, ok := projectEnv[k]; !ok || !valid(v) {
+				ignored("language_servers." + name + ".env." + k)
+				continue
+			}
+			if merged.Env == nil {
+				merged.Env = make(map[string]string)
+			}
+			merged.Env[k] = v
+		}
+		c.LanguageServers[name] = merged
+	}
+	if !project.Build.valid() {
+		ignored("build")
+		c.Build = global.Build
+	}
+	// A project may narrow read access, not widen it
+	if len(project.ReadAccess.Allow) > 0 {
+		ignored("read_access.allow")
+	}
+	c.ReadAccess.Allow = global.ReadAccess.Allow
+	c.ReadAccess.Deny = append(slices.Clone(global.ReadAccess.Deny), project.ReadAccess.Deny...)
+	// Nor turn off secret protection
+	if project.Secrets.Disabled {
+		ignored("secrets.disabled")
+	}
+	if len(project.Secrets.Allow) > 0 {
+		ignored("secrets.allow")
+	}
+	c.Secrets.Disabled = global.Secrets.Disabled
+	c.Secrets.Allow = global.Secrets.Allow
+	c.Secrets.Files = append(slices.Clone(global.Secrets.Files), project.Secrets.Files...)
+	// Logging is the operator's: a project could hide its calls from the
+	// audit log, or have the log written over any file
+	if project.Audit != nil {
+		ignored("audit")
+	}
+	if project.LogFile != "" {
+		ignored("log_file")
+	}
+	c.Audit = global.Audit
+	c.LogFile = global.LogFile
+}
+
+// projectEnv maps the language server environment variables a project
+// config may set to the check of their value.
+var projectEnv = map[string]func(string) bool{
+	"GOPRIVATE": func(string) bool { return true },
+	"GOFLAGS":   tagsOnlyGOFLAGS,
+}
+
+// load unmarshals the file at path over cfg, if it exists.
+func (c *Config) load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// Languages returns DefaultLanguages merged with the language_servers
+// section, and the names of the languages disabled by the config.
+// Configured fields replace the default ones, unset fields are kept.
+func (c *Config) Languages() ([]Language, map[string]bool) {
+	disabled := make(map[string]bool)
+	var languages []Language
+	known := make(map[string]bool)
+
+	for _, lang := range DefaultLanguages {
+		known[lang.Name] = true
+		if lc, ok := c.LanguageServers[lang.Name]; ok {
+			lang = lc.apply(lang)
+			if lc.Disabled {
+				disabled[lang.Name] = true
+			}
+		}
+		if lang.Name == "go" {
+			// Explicit env entries of the config come later and win
+			lang.Env = append(c.Build.Env(), lang.Env...)
+		}
+		languages = append(languages, lang)
+	}
+
+	// Sorted so detection and fan-out order is stable
+	names := make([]string, 0, len(c.LanguageServers))
+	for name := range c.LanguageServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		lc := c.LanguageServers[name]
+		if known[name] {
+			continue
+		}
+		languages = append(languages, lc.apply(Language{Name: name}))
+		if lc.Disabled {
+			disabled[name] = true
+		}
+	}
+	return languages, disabled
+}
+
+// apply overrides the fields of lang set in the config.
+func (lc LanguageServerConfig) apply(lang Language) Language {
+	if len(lc.Command) > 0 {
+		lang.Command = lc.Command
+		lang.VersionArgs = nil // Meant for the default command
+	}
+	if len(lc.Extensions) > 0 {
+		lang.Extensions = lc.Extensions
+	}
+	if len(lc.Markers) > 0 {
+		lang.Markers = lc.Markers
+	}
+	if lc.InitializationOptions != nil {
+		lang.InitOptions = lc.InitializationOptions
+	}
+	if len(lc.Env) > 0 {
+		// Sorted so the environment is stable
+		keys := make([]string, 0, len(lc.Env))
+		for k := range lc.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		lang.Env = nil
+		for _, k := range keys {
+			lang.Env = append(lang.Env, k+"="+lc.Env[k])
+		}
+	}
+	return lang
+}
+
+// Apply installs the settings of the config in the package variables they
+// override; unset ones keep their defaults. Language servers come from
+// Languages instead.
+func (c *Config) Apply() {
+	Build = c.Build
+	ReadAccess = c.ReadAccess
+	Secrets = c.Secrets
+	if c.Audit != nil {
+		AuditEnabled = *c.Audit
+	}
+	if c.Untracked != nil {
+		IncludeUntracked = *c.Untracked
+	}
+	if c.Ignored != nil {
+		IncludeIgnored = *c.Ignored
+	}
+	if c.LogLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+			slog.Warn("invalid log_level in config", "value", c.LogLevel)
+		} else {
+			LogLevel.Set(level)
+		}
+	}
+	if c.LogFile != "" {
+		LogFile = c.LogFile
+	}
+	if c.MaxResults > 0 {
+		MaxResults = c.MaxResults
+	}
+	if c.MaxResultBytes > 0 {
+		MaxResultBytes = c.MaxResultBytes
+	}
+	if c.CallsPerMinute > 0 {
+		CallsPerMinute = c.CallsPerMinute
+	}
+	if c.BytesPerMinute > 0 {
+		BytesPerMinute = c.BytesPerMinute
+	}
+	switch c.GrepBackend {
+	case "":
+	case GrepAuto, GrepGit, GrepGo:
+		GrepBackend = c.GrepBackend
+	default:
+		slog.Warn("invalid grep_backend in config", "value", c.GrepBackend)
+	}
+	switch c.Linter {
+	case "":
+	case LinterAuto, LinterStaticcheck, LinterGolangci:
+		Linter = c.Linter
+	default:
+		slog.Warn("invalid linter in config", "value", c.Linter)
+	}
+	for _, dir := range c.IgnoreDirs {
+		IgnoreDirs[dir] = true
+	}
+	for _, tool := range c.DisabledTools {
+		DisabledTools[tool] = true
+	}
+	weights := Weights.fields()
+	for name, w := range c.ScoreWeights {
+		if p, ok := weights[name]; ok {
+			*p = w
+		} else {
+			slog.Warn("unknown score weight in config", "name", name)
+		}
+	}
+	if len(c.PathWeights) > 0 {
+		SetPathWeights(c.PathWeights)
+	}
+	if c.LSPTimeout > 0 {
+		CallTimeout = c.LSPTimeout
+	}
+	if c.LSPConcurrency > 0 {
+		MaxConcurrentCalls = c.LSPConcurrency
+	}
+	for ext, w := range c.ExtensionWeights {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		ExtensionWeights[ext] = w
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// loadTestConfig writes the global and project configs (skipped when
+// empty) and loads them.
+func loadTestConfig(t *testing.T, global, project string) *Config {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		if content == "" {
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(home, GlobalConfigFile), global)
+	write(filepath.Join(root, ConfigFile), project)
+	cfg, err := LoadConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// language returns the language named name of cfg.
+func language(t *testing.T, cfg *Config, name string) Language {
+	t.Helper()
+	languages, _ := cfg.Languages()
+	for _, lang := range languages {
+		if lang.Name == name {
+			return lang
+		}
+	}
+	t.Fatalf("no language %s", name)
+	return Language{}
+}
+
+func TestProjectConfigCannotSetCommands(t *testing.T) {
+	cfg := loadTestConfig(t, "", `
+language_servers:
+  go:
+    command: ["sh", "-c", "touch /tmp/pwned"]
+    env:
+      GOFLAGS: -toolexec=/tmp/evil
+    initialization_options:
+      buildFlags: ["-toolexec=/tmp/evil"]
+    extensions: [".go", ".tmpl"]
+  evil:
+    command: ["sh", "-c", "touch /tmp/pwned"]
+    extensions: [".txt"]
+`)
+	var defaultGo Language
+	for _, lang := range DefaultLanguages {
+		if lang.Name == "go" {
+			defaultGo = lang
+		}
+	}
+	goLang := language(t, cfg, "go")
+	if !slices.Equal(goLang.Command, defaultGo.Command) {
+		t.Errorf("go command = %q, want the default %q", goLang.Command, defaultGo.Command)
+	}
+	if slices.Contains(goLang.Env, "GOFLAGS=-toolexec=/tmp/evil") {
+		t.Errorf("go env = %q, want no project env", goLang.Env)
+	}
+	if goLang.InitOptions["buildFlags"] != nil {
+		t.Errorf("go initialization options = %v, want no project options", goLang.InitOptions)
+	}
+	// Harmless settings still apply
+	if !slices.Equal(goLang.Extensions, []string{".go", ".tmpl"}) {
+		t.Errorf("go extensions = %q", goLang.Extensions)
+	}
+	if evil := language(t, cfg, "evil"); len(evil.Command) != 0 {
+		t.Errorf("evil command = %q, want none", evil.Command)
+	}
+}
+
+func TestGlobalConfigSetsCommands(t *testing.T) {
+	cfg := loadTestConfig(t, `
+language_servers:
+  go:
+    command: ["gopls", "-remote=auto"]
+    env:
+      GOPRIVATE: github.com/acme/*
+`, `
+language_servers:
+  go:
+    command: ["sh"]
+    extensions: [".go"]
+`)
+	goLang := language(t, cfg, "go")
+	if !slices.Equal(goLang.Command, []string{"gopls", "-remote=auto"}) {
+		t.Errorf("go command = %q, want the global one", goLang.Command)
+	}
+	if !slices.Contains(goLang.Env, "GOPRIVATE=github.com/acme/*") {
+		t.Errorf("go env = %q, want the global GOPRIVATE", goLang.Env)
+	}
+}
+
+func TestProjectConfigEnv(t *testing.T) {
+	cfg := loadTestConfig(t, "", `
+language_servers:
+  go:
+    env:
+      GOPRIVATE: github.com/acme/*
+      GOFLAGS: -tags=integration,e2e
+      GOTOOLCHAIN: local
+build:
+  tags: ["x -toolexec=/tmp/evil"]
+`)
+	env := language(t, cfg, "go").Env
+	for _, want := range []string{"GOPRIVATE=github.com/acme/*", "GOFLAGS=-tags=integration,e2e"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("go env = %q, missing %s", env, want)
+		}
+	}
+	if slices.Contains(env, "GOTOOLCHAIN=local") {
+		t.Errorf("go env = %q, want no GOTOOLCHAIN", env)
+	}
+	if len(cfg.Build.Tags) != 0 {
+		t.Errorf("build tags = %q, want none", cfg.Build.Tags)
+	}
+}
+
+func TestTagsOnlyGOFLAGS(t *testing.T) {
+	tests := []struct {
+		flags string
+		want  bool
+	}{
+		{"", true},
+		{"-tags=integration", true},
+		{"--tags=integration,e2e", true},
+		{"-tags=a -tags=b", true},
+		{"-tags=", true},
+		{"-toolexec=/tmp/evil", false},
+		{"-exec=/tmp/evil", false},
+		{"-tags=a -toolexec=/tmp/evil", false},
+		{"-tags=a;b", false},
+		{"-mod=mod", false},
+		{"tags=a", false},
+	}
+	for _, tt := range tests {
+		if got := tagsOnlyGOFLAGS(tt.flags); got != tt.want {
+			t.Errorf("tagsOnlyGOFLAGS(%q) = %v, want %v", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestProjectConfigReadAccess(t *testing.T) {
+	cfg := loadTestConfig(t, `
+read_access:
+  allow: ["~/src/common"]
+  deny: ["*.pem"]
+`, `
+read_access:
+  allow: ["/", "~/.ssh"]
+  deny: ["secrets/"]
+`)
+	if want := []string{"~/src/common"}; !slices.Equal(cfg.ReadAccess.Allow, want) {
+		t.Errorf("allow = %q, want %q", cfg.ReadAccess.Allow, want)
+	}
+	if want := []string{"*.pem", "secrets/"}; !slices.Equal(cfg.ReadAccess.Deny, want) {
+		t.Errorf("deny = %q, want %q", cfg.ReadAccess.Deny, want)
+	}
+}
+
+func TestProjectConfigSecrets(t *testing.T) {
+	cfg := loadTestConfig(t, `
+secrets:
+  files: ["*.keystore"]
+`, `
+secrets:
+  disabled: true
+  allow: ["*.pem"]
+  files: ["*.vault"]
+`)
+	if cfg.Secrets.Disabled {
+		t.Error("secret protection disabled by the project config")
+	}
+	if len(cfg.Secrets.Allow) != 0 {
+		t.Errorf("allow = %q, want none", cfg.Secrets.Allow)
+	}
+	if want := []string{"*.keystore", "*.vault"}; !slices.Equal(cfg.Secrets.Files, want) {
+		t.Errorf("files = %q, want %q", cfg.Secrets.Files, want)
+	}
+}
+
+func TestProjectConfigLogging(t *testing.T) {
+	cfg := loadTestConfig(t, `
+log_file: /var/log/codemcp.log
+`, `
+audit: false
+log_file: /tmp/codemcp.log
+`)
+	if cfg.Audit != nil {
+		t.Errorf("audit = %v, want unset", *cfg.Audit)
+	}
+	if cfg.LogFile != "/var/log/codemcp.log" {
+		t.Errorf("log file = %q, want the global one", cfg.LogFile)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ConfirmWrites makes write_file, edit_file and apply_patch ask the user to
+// approve their changes before writing anything: through MCP elicitation
+// when the client supports it, otherwise the call must carry confirm: true.
+// Set via the --confirm flag or CODEMCP_CONFIRM.
+var ConfirmWrites = true
+
+// confirmSchema is the form of the elicitation: a single approval checkbox.
+var confirmSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"confirm": map[string]any{
+			"type":        "boolean",
+			"title":       "Apply the changes",
+			"description": "Check to write the changes listed above",
+		},
+	},
+	"required": []string{"confirm"},
+}
+
+// confirmChanges asks the user to approve the changes of the tool call
+// request, listed by changes. It returns nil when approved, the error result
+// of the call otherwise.
+func confirmChanges(ctx context.Context, request mcp.CallToolRequest, changes string) *mcp.CallToolResult {
+	if !ConfirmWrites {
+		return nil
+	}
+	tool := request.Params.Name
+
+	session := server.ClientSessionFromContext(ctx)
+	elicitation, ok := session.(server.SessionWithElicitation)
+	if info, isInfo := session.(server.SessionWithClientInfo); !isInfo || info.GetClientCapabilities().Elicitation == nil {
+		ok = false
+	}
+	if !ok {
+		// The agent must ask the user itself
+		if request.GetBool("confirm", false) {
+			return nil
+		}
+		return mcp.NewToolResultError(fmt.Sprintf("Confirmation required: %s would apply these changes:\n%s\nShow them to the user and, once approved, call %s again with confirm=true.", tool, changes, tool))
+	}
+
+	result, err := elicitation.RequestElicitation(ctx, mcp.ElicitationRequest{
+		Params: mcp.ElicitationParams{
+			Message:         fmt.Sprintf("%s wants to apply these changes:\n%s", tool, changes),
+			RequestedSchema: confirmSchema,
+		},
+	})
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Confirmation failed: %v", err))
+	}
+	if content, _ := result.Content.(map[string]any); result.Action != mcp.ElicitationResponseActionAccept || content["confirm"] != true {
+		return mcp.NewToolResultError("Cancelled: the user did not approve the changes, nothing was written")
+	}
+	return nil
+}
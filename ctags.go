@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CtagsSkipExtensions are never handed to ctags: data and prose files whose
+// "symbols" (JSON keys, headings...) would only add noise.
+var CtagsSkipExtensions = map[string]bool{
+	".json": true, ".md": true, ".txt": true, ".lock": true, ".yaml": true,
+	".yml": true, ".toml": true, ".csv": true, ".svg": true, ".xml": true,
+	".html": true, ".css": true, ".sum": true, ".mod": true,
+}
+
+var (
+	ctagsOnce      sync.Once
+	ctagsAvailable bool
+)
+
+// ctagsTable caches the ctags symbols of every indexed file, keyed by absolute path.
+var ctagsTable = struct {
+	sync.Mutex
+	entries map[string]symbolCacheEntry
+}{entries: make(map[string]symbolCacheEntry)}
+
+// CtagsAvailable reports whether universal-ctags (with JSON output) is installed.
+func CtagsAvailable() bool {
+	ctagsOnce.Do(func() {
+		out, err := exec.Command("ctags", "--version").Output()
+		ctagsAvailable = err == nil && strings.Contains(string(out), "Universal Ctags")
+	})
+	return ctagsAvailable
+}
+
+// needsCtags reports whether ctags should provide the symbols of relPath:
+// files without a built-in extractor that are not data or prose.
+func needsCtags(relPath string) bool {
+	ext := strings.ToLower(filepath.Ext(relPath))
+	if ext == "" || CtagsSkipExtensions[ext] {
+		return false
+	}
+	_, ok := SymbolExtractors[ext]
+	return !ok
+}
+
+// IndexCtags runs ctags once over the files (relative to root) that need it
+// and changed since they were last indexed, refreshing the symbol table
+// used by ExtractCtagsSymbols. It does nothing if ctags is not installed.
+func IndexCtags(ctx context.Context, root string, files []string) {
+	if !CtagsAvailable() {
+		return
+	}
+
+	var stale []string
+	fingerprints := make(map[string]symbolCacheEntry)
+	ctagsTable.Lock()
+	for _, f := range files {
+		if !needsCtags(f) {
+			continue
+		}
+		abs := filepath.Join(root, f)
+		info, err := os.Stat(abs)
+		if err != nil {
+			continue
+		}
+		entry, ok := ctagsTable.entries[abs]
+		if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+			continue
+		}
+		stale = append(stale, f)
+		fingerprints[abs] = symbolCacheEntry{modTime: info.ModTime(), size: info.Size()}
+	}
+	ctagsTable.Unlock()
+	if len(stale) == 0 {
+		return
+	}
+
+	symbols, err := runCtags(ctx, root, stale)
+	if err != nil {
+		return
+	}
+
+	ctagsTable.Lock()
+	defer ctagsTable.Unlock()
+	for abs, entry := range fingerprints {
+		entry.symbols = symbols[abs]
+		ctagsTable.entries[abs] = entry
+	}
+}
+
+// runCtags indexes files (relative to root) with a single ctags process and
+// returns their symbols keyed by absolute path.
+func runCtags(ctx context.Context, root string, files []string) (map[string][]Symbol, error) {
+	cmd := exec.CommandContext(ctx, "ctags", "--output-format=json", "--fields=+K", "--sort=no", "-f", "-", "-L", "-")
+	cmd.Dir = root
+	cmd.Stdin = strings.NewReader(strings.Join(files, "\n") + "\n")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	symbols := make(map[string][]Symbol)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var tag struct {
+			Type string `json:"_type"`
+			Name string `json:"name"`
+			Path string `json:"path"`
+			Kind string `json:"kind"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &tag); err != nil || tag.Type != "tag" {
+			continue
+		}
+		abs := filepath.Join(root, tag.Path)
+		symbols[abs] = append(symbols[abs], Symbol{Kind: tag.Kind, Name: tag.Name})
+	}
+	return symbols, cmd.Wait()
+}
+
+// ExtractCtagsSymbols returns the symbols ctags found in absPath during the
+// last IndexCtags run.
+func ExtractCtagsSymbols(ctx context.Context, absPath string) []Symbol {
+	ctagsTable.Lock()
+	defer ctagsTable.Unlock()
+	return ctagsTable.entries[absPath].symbols
+}
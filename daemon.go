@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// DaemonRequest is sent by the CLI to a running daemon, one JSON object per line.
+type DaemonRequest struct {
+	Query   string        `json:"query"`
+	Options SearchOptions `json:"options"`
+	// Settings are the CacheSettings of the client: a daemon started with
+	// other flags or config refuses the request.
+	Settings string `json:"settings"`
+}
+
+// DaemonResponse is the daemon answer to a DaemonRequest.
+type DaemonResponse struct {
+	Output  CLIOutput `json:"output"`
+	Servers []string  `json:"servers"` // Language servers running in the daemon
+	Error   string    `json:"error,omitempty"`
+}
+
+// DefaultSocketPath returns the Unix socket used by the daemon serving root,
+// in $XDG_RUNTIME_DIR or else a directory of the user under the temporary
+// directory. The name derives from a hash of root so every project gets its
+// own daemon.
+func DefaultSocketPath(root string) string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("codemcp-%d", os.Getuid()))
+	}
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(dir, "codemcp-"+hex.EncodeToString(sum[:8])+".sock")
+}
+
+// runDaemon keeps gopls and the shard caches warm, answering search requests
+// made with the same settings on the Unix socket until interrupted.
+func runDaemon(rootPath string, socketPath string, settings string) {
+	if daemonAvailable(socketPath) {
+		slog.Error("a daemon is already listening", "socket", socketPath)
+		os.Exit(ExitFailure)
+	}
+	// Only the user may reach the socket: a directory of another user
+	// could swap it
+	dir := filepath.Dir(socketPath)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		slog.Error("cannot create the socket directory", "dir", dir, "err", err)
+		os.Exit(ExitFailure)
+	}
+	if err := checkOwner(dir); err != nil {
+		slog.Error("unsafe socket directory", "dir", dir, "err", err)
+		os.Exit(ExitFailure)
+	}
+	// Remove a stale socket left by a daemon that did not exit cleanly
+	_ = os.Remove(socketPath)
+
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		slog.Error("daemon listen failed", "socket", socketPath, "err", err)
+		os.Exit(ExitFailure)
+	}
+	if err := os.Chmod(socketPath, 0o600); err != nil {
+		slog.Error("cannot restrict the socket", "socket", socketPath, "err", err)
+		_ = ln.Close()
+		os.Exit(ExitFailure)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+
+	slog.Info("daemon serving", "root", rootPath, "socket", socketPath)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				break
+			}
+			continue
+		}
+		go handleDaemonConn(ctx, conn, rootPath, settings)
+	}
+	_ = os.Remove(socketPath)
+}
+
+// handleDaemonConn answers the requests of one client connection.
+func handleDaemonConn(ctx context.Context, conn net.Conn, rootPath string, settings string) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req DaemonRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			_ = enc.Encode(DaemonResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+			continue
+		}
+		if req.Settings != settings {
+			_ = enc.Encode(DaemonResponse{Error: "the daemon runs with other flags or config, restart it"})
+			continue
+		}
+
+		start := time.Now()
+		results, err := Search(ctx, rootPath, req.Query, req.Options, nil)
+		resp := DaemonResponse{
+			Output: CLIOutput{
+				Query:    req.Query,
+				Ref:      req.Options.Ref,
+				Worktree: FindWorktree(ctx, rootPath),
+				Duration: time.Since(start).String(),
+				Count:    len(results),
+				Files:    results,
+			},
+			Servers: LSP.Running(),
+		}
+		if isBackendError(err) {
+			resp.Output.Error = err.Error()
+		} else if err != nil {
+			resp.Error = err.Error()
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// daemonAvailable reports whether a daemon of the user accepts connections
+// on socketPath.
+func daemonAvailable(socketPath string) bool {
+	if checkOwner(socketPath) != nil {
+		return false
+	}
+	conn, err := net.DialTimeout("unix", socketPath, 200*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// RemoteSearch sends query to the daemon listening on socketPath, which
+// must belong to the user and run with the same settings.
+func RemoteSearch(socketPath string, query string, opts SearchOptions, settings string) (*DaemonResponse, error) {
+	if err := checkOwner(socketPath); err != nil {
+		return nil, fmt.Errorf("no daemon on %s: %w", socketPath, err)
+	}
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("no daemon on %s: %w", socketPath, err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(DaemonRequest{Query: query, Options: opts, Settings: settings}); err != nil {
+		return nil, err
+	}
+
+	var resp DaemonResponse
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("reading daemon response: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// checkOwner only checks that path exists: file owners are Unix only.
+func checkOwner(path string) error {
+	_, err := os.Lstat(path)
+	return err
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DeadCode is the result of the find_dead_code tool.
+type DeadCode struct {
+	Candidates []DeadDecl `json:"candidates"`
+}
+
+// DeadDecl is a top-level declaration no root reaches.
+type DeadDecl struct {
+	Path string `json:"path"` // Relative to the project root
+	Line int    `json:"line"`
+	Kind string `json:"kind"` // func, method, type, var or const
+	Name string `json:"name"` // Type.Method for methods
+}
+
+// declNode is a top-level declaration of the project, with the
+// declarations its source refers to.
+type declNode struct {
+	DeadDecl
+	pos      token.Pos
+	root     bool
+	refs     []token.Pos
+	methods  []*declNode // Of a type
+	exported bool
+	test     bool // Declared in a test file
+	reported bool // Not generated, under the requested directory
+}
+
+// FindDeadCode returns the top-level declarations of the module at root
+// that no root reaches through the references of the source: main, init,
+// and the tests with tests. Unless withExported, the exported declarations
+// of non-main packages are roots too, as the API of the module. A method is
+// reached with its type when exported or named like a method of an
+// interface of the project, as it may be called through interfaces. Only
+// the declarations under dir (relative to root, "." for all) are returned.
+func FindDeadCode(ctx context.Context, root string, dir string, tests bool, withExported bool) ([]DeadDecl, error) {
+	fset, pkgs, err := LoadTypedPackages(ctx, root, tests)
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.ToSlash(filepath.Clean(dir))
+
+	nodes := make(map[token.Pos]*declNode)
+	ifaceMethods := make(map[string]bool)
+	node := func(pkg *TypedPackage, file *ast.File, name *ast.Ident, kind string) *declNode {
+		if n, ok := nodes[name.Pos()]; ok {
+			return n
+		}
+		pos := fset.Position(name.Pos())
+		rel, _ := filepath.Rel(root, pos.Filename)
+		rel = filepath.ToSlash(rel)
+		n := &declNode{
+			DeadDecl: DeadDecl{Path: rel, Line: pos.Line, Kind: kind, Name: name.Name},
+			pos:      name.Pos(),
+			exported: name.IsExported(),
+			test:     strings.HasSuffix(rel, "_test.go"),
+			reported: !ast.IsGenerated(file) && (dir == "." || rel == dir || strings.HasPrefix(rel, dir+"/")),
+		}
+		n.root = name.Name == "_" || !withExported && n.exported && pkg.Types != nil && pkg.Types.Name() != "main"
+		nodes[name.Pos()] = n
+		return n
+	}
+	// refs records the project objects the idents of n refer to
+	refs := func(pkg *TypedPackage, n *declNode, within ast.Node) {
+		ast.Inspect(within, func(x ast.Node) bool {
+			id, ok := x.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if obj := pkg.Info.Uses[id]; obj != nil {
+				n.refs = append(n.refs, originPos(obj))
+			}
+			return true
+		})
+	}
+
+	recvs := make(map[*declNode]token.Pos) // Methods to the declaration of their type
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				switch d := decl.(type) {
+				case *ast.FuncDecl:
+					kind := "func"
+					if d.Recv != nil {
+						kind = "method"
+					}
+					n := node(pkg, file, d.Name, kind)
+					if fn, ok := pkg.Info.Defs[d.Name].(*types.Func); ok && d.Recv != nil {
+						if named := receiverNamed(fn); named != nil {
+							n.Name = named.Obj().Name() + "." + d.Name.Name
+							recvs[n] = named.Obj().Pos()
+						}
+					}
+					n.root = n.root && d.Recv == nil || rootFunc(pkg, d, n.test)
+					refs(pkg, n, d)
+				case *ast.GenDecl:
+					for _, spec := range d.Specs {
+						switch s := spec.(type) {
+						case *ast.TypeSpec:
+							refs(pkg, node(pkg, file, s.Name, "type"), s)
+							if iface, ok := s.Type.(*ast.InterfaceType); ok {
+								for _, m := range iface.Methods.List {
+									for _, name := range m.Names {
+										ifaceMethods[name.Name] = true
+									}
+								}
+							}
+						case *ast.ValueSpec:
+							for _, name := range s.Names {
+								refs(pkg, node(pkg, file, name, d.Tok.String()), s)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	for m, typePos := range recvs {
+		if t, ok := nodes[typePos]; ok {
+			t.methods = append(t.methods, m)
+		}
+	}
+
+	// Mark the declarations reachable from the roots
+	reached := make(map[*declNode]bool)
+	var queue []*declNode
+	reach := func(n *declNode) {
+		if n != nil && !reached[n] {
+			reached[n] = true
+			queue = append(queue, n)
+		}
+	}
+	for _, n := range nodes {
+		if n.root {
+			reach(n)
+		}
+	}
+	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n := queue[0]
+		queue = queue[1:]
+		for _, pos := range n.refs {
+			reach(nodes[pos])
+		}
+		for _, m := range n.methods {
+			if name := m.Name[strings.LastIndex(m.Name, ".")+1:]; m.exported || ifaceMethods[name] {
+				reach(m)
+			}
+		}
+	}
+
+	dead := []DeadDecl{}
+	for _, n := range nodes {
+		if !reached[n] && !n.test && n.reported {
+			dead = append(dead, n.DeadDecl)
+		}
+	}
+	sort.Slice(dead, func(i, j int) bool {
+		if dead[i].Path != dead[j].Path {
+			return dead[i].Path < dead[j].Path
+		}
+		return dead[i].Line < dead[j].Line
+	})
+	return dead, nil
+}
+
+// rootFunc reports whether d is a root of its own: main, init, tests and
+// functions exported to C or linked by name.
+func rootFunc(pkg *TypedPackage, d *ast.FuncDecl, test bool) bool {
+	if d.Recv != nil {
+		return false
+	}
+	name := d.Name.Name
+	if name == "init" || name == "main" && pkg.Types != nil && pkg.Types.Name() == "main" {
+		return true
+	}
+	if test {
+		for _, prefix := range []string{"Test", "Benchmark", "Example", "Fuzz"} {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		}
+	}
+	if d.Doc != nil {
+		for _, c := range d.Doc.List {
+			if strings.HasPrefix(c.Text, "//export ") || strings.HasPrefix(c.Text, "//go:linkname ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// receiverNamed returns the named type of the receiver of method fn.
+func receiverNamed(fn *types.Func) *types.Named {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	t := recv.Type()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, _ := t.(*types.Named)
+	return named
+}
+
+// originPos returns the declaration position of obj, that of the generic
+// function or variable for instantiated ones.
+func originPos(obj types.Object) token.Pos {
+	switch o := obj.(type) {
+	case *types.Func:
+		return o.Origin().Pos()
+	case *types.Var:
+		return o.Origin().Pos()
+	}
+	return obj.Pos()
+}
+
+// deadCodeTool returns the declarations of the project no root reaches.
+func deadCodeTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("find_dead_code",
+		mcp.WithDescription("Find the Go declarations (functions, methods, types, variables, constants) of the project unreachable from main, init and the tests, type-checking the whole module. Returns candidates with their location, to confirm before removing: code reached by reflection or build tags other than the current ones is not seen."),
+		mcp.WithString("path", mcp.Description("Only return the candidates under this directory (absolute or relative to project root); the whole module is analyzed")),
+		mcp.WithBoolean("exported", mcp.Description("Also report the exported declarations of library packages no code of the module uses (by default they are the API of the module)")),
+		mcp.WithBoolean("tests", mcp.Description("Count the tests as roots, true by default: code only tests use is then alive")),
+		mcp.WithOutputSchema[DeadCode](),
+		readOnlyAnnotations("Find dead code"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		pathArg := request.GetString("path", ".")
+		rel, err := filepath.Rel(rootPath, resolvePath(rootPath, pathArg))
+		if err != nil || !filepath.IsLocal(rel) {
+			return mcp.NewToolResultError(fmt.Sprintf("%s is outside the project root %s", pathArg, rootPath)), nil
+		}
+		dead, err := FindDeadCode(ctx, rootPath, rel, request.GetBool("tests", true), request.GetBool("exported", false))
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("dead code analysis failed: %v", err)), nil
+		}
+		result := DeadCode{Candidates: dead}
+		return jsonToolResult(result, result), nil
+	}
+}
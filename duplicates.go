@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Limits of find_duplicates: the size of the smallest body compared and the
+// number of groups returned.
+const (
+	defaultCloneLines  = 6
+	defaultCloneGroups = 20
+	maxCloneGroups     = 200
+)
+
+// CloneFunc is a function of a clone group.
+type CloneFunc struct {
+	Path    string `json:"path"` // Relative to the project root
+	Line    int    `json:"line"`
+	EndLine int    `json:"end_line"`
+	Name    string `json:"name"` // Type.Method for methods
+}
+
+// CloneGroup is a set of functions with the same body, up to the names and
+// literal values.
+type CloneGroup struct {
+	Lines     int         `json:"lines"` // Of the largest body
+	Functions []CloneFunc `json:"functions"`
+}
+
+// DuplicateReport is the result of the find_duplicates tool.
+type DuplicateReport struct {
+	Groups []CloneGroup `json:"groups"`
+	Total  int          `json:"total"` // Groups found, before the limit
+}
+
+// FindDuplicates returns the groups of Go functions of root, under dir
+// (relative to root, "." for all), whose bodies of at least minLines lines
+// are identical once their identifiers are renamed in order of appearance
+// and their literals reduced to their kind: copies with renamed variables
+// or changed constants. Groups with the most duplicated lines come first.
+func FindDuplicates(ctx context.Context, root string, dir string, minLines int, tests bool) ([]CloneGroup, error) {
+	files, err := CollectFiles(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.ToSlash(filepath.Clean(dir))
+
+	byHash := make(map[[sha256.Size]byte]*CloneGroup)
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rel := filepath.ToSlash(f)
+		if filepath.Ext(rel) != ".go" || !tests && strings.HasSuffix(rel, "_test.go") ||
+			dir != "." && rel != dir && !strings.HasPrefix(rel, dir+"/") {
+			continue
+		}
+		src, err := os.ReadFile(filepath.Join(root, f))
+		if err != nil {
+			continue
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, rel, src, parser.SkipObjectResolution)
+		if err != nil || ast.IsGenerated(file) {
+			continue
+		}
+		for _, decl := range file.Decls {
+			d, ok := decl.(*ast.FuncDecl)
+			if !ok || d.Body == nil {
+				continue
+			}
+			start, end := fset.Position(d.Body.Lbrace), fset.Position(d.Body.Rbrace)
+			lines := end.Line - start.Line - 1
+			if lines < minLines {
+				continue
+			}
+			hash := bodyFingerprint(src[start.Offset : end.Offset+1])
+			g, ok := byHash[hash]
+			if !ok {
+				g = &CloneGroup{}
+				byHash[hash] = g
+			}
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				name = receiverName(d.Recv.List[0].Type) + "." + name
+			}
+			g.Lines = max(g.Lines, lines)
+			g.Functions = append(g.Functions, CloneFunc{
+				Path:    rel,
+				Line:    fset.Position(d.Pos()).Line,
+				EndLine: end.Line,
+				Name:    name,
+			})
+		}
+	}
+
+	var groups []CloneGroup
+	for _, g := range byHash {
+		if len(g.Functions) > 1 {
+			groups = append(groups, *g)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if a.Lines*len(a.Functions) != b.Lines*len(b.Functions) {
+			return a.Lines*len(a.Functions) > b.Lines*len(b.Functions)
+		}
+		return a.Functions[0].Path+":"+strconv.Itoa(a.Functions[0].Line) < b.Functions[0].Path+":"+strconv.Itoa(b.Functions[0].Line)
+	})
+	return groups, nil
+}
+
+// bodyFingerprint hashes the tokens of the Go source body, comments aside,
+// each identifier replaced by its rank of first appearance and each literal
+// by its kind.
+func bodyFingerprint(body []byte) [sha256.Size]byte {
+	fset := token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", -1, len(body)), body, nil, 0)
+	ids := make(map[string]int)
+	var b strings.Builder
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		switch {
+		case tok == token.IDENT:
+			n, ok := ids[lit]
+			if !ok {
+				n = len(ids)
+				ids[lit] = n
+			}
+			fmt.Fprintf(&b, "$%d ", n)
+		case tok.IsLiteral():
+			b.WriteString(tok.String() + " ")
+		case tok == token.SEMICOLON && lit == "\n":
+			// Automatic semicolons follow the line breaks, not the code
+			b.WriteString("; ")
+		default:
+			b.WriteString(tok.String() + " ")
+		}
+	}
+	return sha256.Sum256([]byte(b.String()))
+}
+
+// duplicatesTool returns the groups of duplicated Go functions.
+func duplicatesTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("find_duplicates",
+		mcp.WithDescription("Find the Go functions of the project with duplicated bodies: identical up to the names of identifiers and the values of literals, e.g. a function copied with renamed variables. Returns clone groups with their locations, the most duplicated lines first. Use it to find code to consolidate."),
+		mcp.WithString("path", mcp.Description("Limit the search to this directory (absolute or relative to project root)")),
+		mcp.WithNumber("min_lines", mcp.Description(fmt.Sprintf("Smallest body compared, in lines, %d by default", defaultCloneLines))),
+		mcp.WithBoolean("tests", mcp.Description("Include the _test.go files")),
+		mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("Number of groups, %d by default, at most %d", defaultCloneGroups, maxCloneGroups))),
+		mcp.WithOutputSchema[DuplicateReport](),
+		readOnlyAnnotations("Find duplicates"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		pathArg := request.GetString("path", ".")
+		rel, err := filepath.Rel(rootPath, resolvePath(rootPath, pathArg))
+		if err != nil || !filepath.IsLocal(rel) {
+			return mcp.NewToolResultError(fmt.Sprintf("%s is outside the project root %s", pathArg, rootPath)), nil
+		}
+
+		minLines := max(request.GetInt("min_lines", defaultCloneLines), 1)
+		groups, err := FindDuplicates(ctx, rootPath, rel, minLines, request.GetBool("tests", false))
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("find duplicates failed: %v", err)), nil
+		}
+		result := DuplicateReport{Groups: []CloneGroup{}, Total: len(groups)}
+		limit := min(max(request.GetInt("limit", defaultCloneGroups), 1), maxCloneGroups)
+		if len(groups) > limit {
+			groups = groups[:limit]
+		}
+		result.Groups = append(result.Groups, groups...)
+		return jsonToolResult(result, result), nil
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix prefixes the environment variables configuring codemcp, for MCP
+// clients that can set the environment of the server but not its flags.
+//
+// Precedence, highest first: command line flags, config file, environment
+// variables, built-in defaults.
+const EnvPrefix = "CODEMCP_"
+
+// envString returns the value of CODEMCP_<name>, or def when unset.
+func envString(name string, def string) string {
+	if v, ok := os.LookupEnv(EnvPrefix + name); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// envBool returns the boolean value of CODEMCP_<name> (1, true, 0, false...),
+// or def when unset or invalid.
+func envBool(name string, def bool) bool {
+	v := envString(name, "")
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		envInvalid(name, v)
+		return def
+	}
+	return b
+}
+
+// envInt returns the positive integer value of CODEMCP_<name>, or def when
+// unset or invalid.
+func envInt(name string, def int) int {
+	v := envString(name, "")
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		envInvalid(name, v)
+		return def
+	}
+	return n
+}
+
+// envDuration returns the duration value of CODEMCP_<name> (e.g. "30s"), or
+// def when unset or invalid.
+func envDuration(name string, def time.Duration) time.Duration {
+	v := envString(name, "")
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		envInvalid(name, v)
+		return def
+	}
+	return d
+}
+
+// envLevel returns the log level of CODEMCP_<name> (debug, info, warn,
+// error), or def when unset or invalid.
+func envLevel(name string, def slog.Level) slog.Level {
+	v := envString(name, "")
+	if v == "" {
+		return def
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(v)); err != nil {
+		envInvalid(name, v)
+		return def
+	}
+	return level
+}
+
+// envInvalid warns about an environment variable that cannot be parsed.
+func envInvalid(name string, value string) {
+	slog.Warn("ignoring invalid environment variable", "name", EnvPrefix+name, "value", value)
+}
+
+// applyEnv installs the settings of the environment that have no flag of
+// their own, or whose config entry must win over them. Called before the
+// flags are defined and the config is applied.
+func applyEnv() {
+	Workers = envInt("WORKERS", Workers)
+	MaxResults = envInt("MAX_RESULTS", MaxResults)
+	MaxResultBytes = envInt("MAX_RESULT_BYTES", MaxResultBytes)
+	CallsPerMinute = envInt("CALLS_PER_MINUTE", CallsPerMinute)
+	BytesPerMinute = envInt("BYTES_PER_MINUTE", BytesPerMinute)
+	GrepBackend = envString("GREP_BACKEND", GrepBackend)
+	Linter = envString("LINTER", Linter)
+	CallTimeout = envDuration("LSP_TIMEOUT", CallTimeout)
+	MaxConcurrentCalls = envInt("LSP_CONCURRENCY", MaxConcurrentCalls)
+	NodeModules = envBool("NODE_MODULES", NodeModules)
+	LogLevel.Set(envLevel("LOG_LEVEL", LogLevel.Level()))
+	LogFile = envString("LOG_FILE", LogFile)
+}
+
+// splitList returns the trimmed, non-empty items of a comma-separated list.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
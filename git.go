@@ -0,0 +1,581 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Limits of the number of commits returned by git_history.
+const (
+	defaultHistoryCommits = 10
+	maxHistoryCommits     = 100
+)
+
+// Commit is a commit of the history of a path.
+type Commit struct {
+	Hash    string   `json:"hash"`
+	Author  string   `json:"author"`
+	Email   string   `json:"email"`
+	Date    string   `json:"date"` // Author date, RFC 3339
+	Subject string   `json:"subject"`
+	Body    string   `json:"body,omitempty"`   // Rest of the message
+	Files   []string `json:"files,omitempty"`  // Changed files, relative to the repository, for search_commits
+	Change  string   `json:"change,omitempty"` // Symbol occurrences added, removed or moved, for symbol_history
+	Patch   string   `json:"patch,omitempty"`  // Changes of the commit to the path, with patch: true
+}
+
+// CommitSearch is the result of the search_commits tool.
+type CommitSearch struct {
+	Query   string   `json:"query,omitempty"`
+	Author  string   `json:"author,omitempty"`
+	Commits []Commit `json:"commits"`
+}
+
+// Modes of symbol_history.
+const (
+	SymbolPickaxe  = "pickaxe"  // git log -S: commits changing the number of occurrences
+	SymbolFunction = "function" // git log -L: commits changing the function body
+)
+
+// SymbolHistory is the result of the symbol_history tool.
+type SymbolHistory struct {
+	Symbol  string   `json:"symbol"`
+	Path    string   `json:"path,omitempty"`
+	Mode    string   `json:"mode"` // SymbolPickaxe or SymbolFunction
+	Commits []Commit `json:"commits"`
+}
+
+// LineHistory is the result of the line_history tool.
+type LineHistory struct {
+	Path    string   `json:"path"`
+	Start   int      `json:"start"`
+	End     int      `json:"end"`
+	Commits []Commit `json:"commits"`
+}
+
+// GitHistory is the result of the git_history tool.
+type GitHistory struct {
+	Path    string   `json:"path"`
+	Commits []Commit `json:"commits"`
+}
+
+// runGit runs git with args in dir and returns its standard output, or an
+// error holding its standard error.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// secretPathspecs returns the git pathspecs excluding the secret files, so
+// patches do not reveal them.
+func secretPathspecs() []string {
+	var specs []string
+	for _, pattern := range slices.Concat(SecretFilePatterns, Secrets.Files) {
+		specs = append(specs, ":(exclude,glob)**/"+pattern)
+	}
+	return specs
+}
+
+// GitLog returns the last n commits touching absPath (a file or a
+// directory), newest first, following the renames of a file. With patch,
+// each commit holds its diff of absPath, secret files excluded.
+func GitLog(ctx context.Context, absPath string, n int, patch bool) ([]Commit, error) {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, err
+	}
+	dir := absPath
+	args := []string{"log", fmt.Sprintf("-n%d", n), "--format=" + logFormat}
+	if !info.IsDir() {
+		dir = filepath.Dir(absPath)
+		args = append(args, "--follow")
+	}
+	if patch {
+		args = append(args, "-p", "--no-color", "--no-ext-diff")
+	}
+	args = append(args, "--", absPath)
+	if patch && info.IsDir() {
+		args = append(args, secretPathspecs()...)
+	}
+
+	out, err := runGit(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	commits, rests := parseLog(out)
+	for i := range commits {
+		if patch {
+			commits[i].Patch, _ = RedactSecrets(absPath, strings.TrimSpace(rests[i])+"\n")
+		}
+	}
+	if commits == nil {
+		commits = []Commit{}
+	}
+	return commits, nil
+}
+
+// logFormat is the git log format read by parseLog: fields and commits are
+// separated by the ASCII unit and record separators, the message body ends
+// with a group separator.
+const logFormat = "%x1e%H%x1f%an%x1f%ae%x1f%aI%x1f%s%x1f%b%x1d"
+
+// parseLog returns the commits of the output of git log with logFormat, and
+// the output following each (patch, file names...).
+func parseLog(out string) ([]Commit, []string) {
+	var commits []Commit
+	var rests []string
+	for _, record := range strings.Split(out, "\x1e")[1:] {
+		header, rest, _ := strings.Cut(record, "\x1d")
+		fields := strings.Split(header, "\x1f")
+		if len(fields) != 6 {
+			continue
+		}
+		commits = append(commits, Commit{Hash: fields[0], Author: fields[1], Email: fields[2], Date: fields[3], Subject: fields[4], Body: strings.TrimSpace(fields[5])})
+		rests = append(rests, rest)
+	}
+	return commits, rests
+}
+
+// SearchCommits returns the last n commits of the repository of root whose
+// message matches the regular expression query and whose author matches
+// author (either may be empty), case-insensitively, newest first, with their
+// changed files under absPath when set, secret files excluded.
+func SearchCommits(ctx context.Context, root string, query string, author string, since string, absPath string, n int) ([]Commit, error) {
+	if query == "" && author == "" {
+		return nil, errors.New("query or author is required")
+	}
+	args := []string{"log", fmt.Sprintf("-n%d", n), "--format=" + logFormat, "--name-only", "--no-renames", "-i", "-E"}
+	if query != "" {
+		args = append(args, "--grep="+query)
+	}
+	if author != "" {
+		args = append(args, "--author="+author)
+	}
+	if since != "" {
+		args = append(args, "--since="+since)
+	}
+	args = append(args, "--")
+	if absPath != "" {
+		args = append(args, absPath)
+	}
+
+	out, err := runGit(ctx, root, args...)
+	if err != nil {
+		return nil, err
+	}
+	commits, rests := parseLog(out)
+	for i := range commits {
+		commits[i].Body, _ = RedactSecrets(root, commits[i].Body)
+		for _, f := range strings.Split(rests[i], "\n") {
+			if f = strings.TrimSpace(f); f == "" {
+				continue
+			}
+			if _, ok := IsSecretFile(f); !ok {
+				commits[i].Files = append(commits[i].Files, f)
+			}
+		}
+	}
+	if commits == nil {
+		commits = []Commit{}
+	}
+	return commits, nil
+}
+
+// gitHistoryTool returns the recent commits touching a path.
+func gitHistoryTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("git_history",
+		mcp.WithDescription("Return the recent commits touching a file or directory (hash, author, date, subject, optionally the patch), newest first, following file renames. Use it to learn why and when code you found changed."),
+		mcp.WithString("path", mcp.Description("Absolute path to the file or directory (or relative to project root), the project root by default")),
+		mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("Number of commits, %d by default, at most %d", defaultHistoryCommits, maxHistoryCommits))),
+		mcp.WithBoolean("patch", mcp.Description("Include the changes of each commit to the path")),
+		mcp.WithOutputSchema[GitHistory](),
+		readOnlyAnnotations("Git history"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		pathArg := request.GetString("path", ".")
+		targetPath := resolvePath(rootPath, pathArg)
+
+		// Security Check
+		if !isAllowedPath(ctx, targetPath) {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Reading the history of %s is not allowed.", pathArg)), nil
+		}
+		patch := request.GetBool("patch", false)
+		if pattern, ok := IsSecretFile(targetPath); ok && patch {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: %s looks like a secret file (matches %q), its patches are not shown.", pathArg, pattern)), nil
+		}
+
+		limit := min(max(request.GetInt("limit", defaultHistoryCommits), 1), maxHistoryCommits)
+		commits, err := GitLog(ctx, targetPath, limit, patch)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("git log failed: %v", err)), nil
+		}
+		history := GitHistory{Path: pathArg, Commits: commits}
+		return jsonToolResult(history, history), nil
+	}
+}
+
+// GitDiff returns the diff of the repository of root, limited to absPath
+// when set, secret files excluded: staged changes with staged, the changes
+// between the from and to refs with both, the working tree against from
+// (HEAD by default) otherwise.
+func GitDiff(ctx context.Context, root string, from string, to string, staged bool, absPath string) (string, error) {
+	for _, ref := range []string{from, to} {
+		if strings.HasPrefix(ref, "-") {
+			return "", fmt.Errorf("invalid ref %q", ref)
+		}
+	}
+	if to != "" && from == "" {
+		return "", errors.New("to requires from")
+	}
+	if staged && to != "" {
+		return "", errors.New("staged compares the index, it cannot be used with to")
+	}
+
+	args := []string{"diff", "--no-color", "--no-ext-diff"}
+	switch {
+	case staged:
+		args = append(args, "--cached")
+		if from != "" {
+			args = append(args, from)
+		}
+	case to != "":
+		args = append(args, from, to)
+	case from != "":
+		args = append(args, from)
+	default:
+		args = append(args, "HEAD")
+	}
+	args = append(args, "--")
+	if absPath != "" {
+		args = append(args, absPath)
+	}
+	args = append(args, secretPathspecs()...)
+	return runGit(ctx, root, args...)
+}
+
+// gitDiffTool returns the pending changes of the project, or the changes
+// between two refs.
+func gitDiffTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("git_diff",
+		mcp.WithDescription("Return the unified diff of the project: the working tree against HEAD by default (staged and unstaged changes, untracked files excluded), the staged changes only with staged, or the changes between two refs with from and to. Use it to review or continue in-progress work."),
+		mcp.WithString("path", mcp.Description("Limit the diff to this file or directory (absolute or relative to project root)")),
+		mcp.WithBoolean("staged", mcp.Description("Only the staged changes, against HEAD or from")),
+		mcp.WithString("from", mcp.Description("Ref to compare from (branch, tag, commit, e.g. main or HEAD~3), HEAD by default")),
+		mcp.WithString("to", mcp.Description("Ref to compare to, the working tree by default; requires from")),
+		readOnlyAnnotations("Git diff"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		var targetPath string
+		if pathArg := request.GetString("path", ""); pathArg != "" {
+			targetPath = resolvePath(rootPath, pathArg)
+			// Security Check
+			if !isAllowedPath(ctx, targetPath) {
+				return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Reading the changes of %s is not allowed.", pathArg)), nil
+			}
+			if pattern, ok := IsSecretFile(targetPath); ok {
+				return mcp.NewToolResultError(fmt.Sprintf("Access Denied: %s looks like a secret file (matches %q), its changes are not shown.", pathArg, pattern)), nil
+			}
+		}
+
+		diff, err := GitDiff(ctx, rootPath, request.GetString("from", ""), request.GetString("to", ""), request.GetBool("staged", false), targetPath)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("git diff failed: %v", err)), nil
+		}
+		if diff == "" {
+			return mcp.NewToolResultText("No changes"), nil
+		}
+		diff, _ = RedactSecrets(rootPath, diff)
+		return mcp.NewToolResultText(diff), nil
+	}
+}
+
+// searchCommitsTool returns the commits whose message or author matches a
+// query, with the files they changed.
+func searchCommitsTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("search_commits",
+		mcp.WithDescription("Search the commit messages (git log --grep) and authors of the project, and return the matching commits, newest first, with their full message and changed files. Use it to find the design decisions and rationale behind code, then read the files they touched."),
+		mcp.WithString("query", mcp.Description("Regular expression matched case-insensitively against the commit messages, e.g. \"retry|backoff\"")),
+		mcp.WithString("author", mcp.Description("Only the commits whose author name or email matches this pattern")),
+		mcp.WithString("since", mcp.Description("Only the commits more recent than this date, e.g. 2024-01-31 or \"3 months ago\"")),
+		mcp.WithString("path", mcp.Description("Only the commits touching this file or directory (absolute or relative to project root)")),
+		mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("Number of commits, %d by default, at most %d", defaultHistoryCommits, maxHistoryCommits))),
+		mcp.WithOutputSchema[CommitSearch](),
+		readOnlyAnnotations("Search commits"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		var targetPath string
+		if pathArg := request.GetString("path", ""); pathArg != "" {
+			targetPath = resolvePath(rootPath, pathArg)
+			// Security Check
+			if !isAllowedPath(ctx, targetPath) {
+				return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Reading the history of %s is not allowed.", pathArg)), nil
+			}
+		}
+
+		search := CommitSearch{Query: request.GetString("query", ""), Author: request.GetString("author", "")}
+		if search.Query == "" && search.Author == "" {
+			return mcp.NewToolResultError("query or author is required"), nil
+		}
+		limit := min(max(request.GetInt("limit", defaultHistoryCommits), 1), maxHistoryCommits)
+		commits, err := SearchCommits(ctx, rootPath, search.Query, search.Author, request.GetString("since", ""), targetPath, limit)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("git log failed: %v", err)), nil
+		}
+		search.Commits = commits
+		return jsonToolResult(search, search), nil
+	}
+}
+
+// lineLog returns the last n commits changing the lines of the file at
+// absPath selected by the git log -L range (e.g. "10,20" or ":funcname"),
+// newest first. With patch, each commit holds its changes to the lines.
+func lineLog(ctx context.Context, root string, absPath string, lines string, n int, patch bool) ([]Commit, error) {
+	args := []string{"log", fmt.Sprintf("-n%d", n), "--format=" + logFormat, "--no-color", "-L" + lines + ":" + absPath}
+	if !patch {
+		args = append(args, "--no-patch")
+	}
+	out, err := runGit(ctx, root, args...)
+	if err != nil {
+		return nil, err
+	}
+	commits, rests := parseLog(out)
+	rel, _ := filepath.Rel(root, absPath)
+	for i := range commits {
+		commits[i].Files = []string{filepath.ToSlash(rel)}
+		if patch {
+			commits[i].Patch, _ = RedactSecrets(absPath, strings.TrimSpace(rests[i])+"\n")
+		}
+	}
+	if commits == nil {
+		commits = []Commit{}
+	}
+	return commits, nil
+}
+
+// SymbolLog returns the last n commits of the repository of root changing
+// symbol, newest first, and the mode used. When absPath is a file defining
+// symbol as a function, they are the commits changing its body (git log -L),
+// otherwise those adding or removing occurrences of symbol under absPath
+// (git log -S), secret files excluded. With patch, each commit holds its
+// changes.
+func SymbolLog(ctx context.Context, root string, symbol string, absPath string, n int, patch bool) (string, []Commit, error) {
+	if strings.TrimSpace(symbol) == "" {
+		return "", nil, errors.New("symbol is required")
+	}
+	if info, err := os.Stat(absPath); err == nil && !info.IsDir() && !strings.Contains(symbol, ":") {
+		// Without such a function, git fails and the pickaxe applies
+		if commits, err := lineLog(ctx, root, absPath, ":"+symbol, n, patch); err == nil {
+			return SymbolFunction, commits, nil
+		}
+	}
+
+	// The patches count the occurrences of each commit
+	args := []string{"log", fmt.Sprintf("-n%d", n), "--format=" + logFormat, "-S" + symbol, "-p", "--no-color", "--no-ext-diff", "--no-renames", "--"}
+	if absPath != "" {
+		args = append(args, absPath)
+	}
+	args = append(args, secretPathspecs()...)
+	out, err := runGit(ctx, root, args...)
+	if err != nil {
+		return "", nil, err
+	}
+	commits, rests := parseLog(out)
+	for i := range commits {
+		added, removed := 0, 0
+		for _, line := range strings.Split(rests[i], "\n") {
+			switch {
+			case strings.HasPrefix(line, "diff --git a/"):
+				// diff --git a/<path> b/<path>
+				if _, f, ok := strings.Cut(line, " b/"); ok {
+					commits[i].Files = append(commits[i].Files, f)
+				}
+			case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+			case strings.HasPrefix(line, "+"):
+				added += strings.Count(line, symbol)
+			case strings.HasPrefix(line, "-"):
+				removed += strings.Count(line, symbol)
+			}
+		}
+		switch {
+		case added > removed:
+			commits[i].Change = "added"
+		case added < removed:
+			commits[i].Change = "removed"
+		default:
+			commits[i].Change = "moved"
+		}
+		if patch {
+			commits[i].Patch, _ = RedactSecrets(root, strings.TrimSpace(rests[i])+"\n")
+		}
+	}
+	if commits == nil {
+		commits = []Commit{}
+	}
+	return SymbolPickaxe, commits, nil
+}
+
+// symbolHistoryTool returns the commits introducing, changing or removing a
+// symbol.
+func symbolHistoryTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("symbol_history",
+		mcp.WithDescription("Return the commits that introduced, modified or removed a symbol, newest first. With the path of the file defining a function, follows the changes of its body (git log -L); otherwise lists the commits adding or removing occurrences of the name (git log -S), each marked added, removed or moved. Use it to answer why code behaves the way it does."),
+		mcp.WithString("symbol", mcp.Required(), mcp.Description("Function, type or any identifier, matched literally, e.g. ParseConfig")),
+		mcp.WithString("path", mcp.Description("File defining the function, or directory to limit the search to (absolute or relative to project root), the whole repository by default")),
+		mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("Number of commits, %d by default, at most %d", defaultHistoryCommits, maxHistoryCommits))),
+		mcp.WithBoolean("patch", mcp.Description("Include the changes of each commit")),
+		mcp.WithOutputSchema[SymbolHistory](),
+		readOnlyAnnotations("Symbol history"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		symbol, err := request.RequireString("symbol")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		rootPath := projectRoot(ctx, rootPath)
+		pathArg := request.GetString("path", "")
+		var targetPath string
+		if pathArg != "" {
+			targetPath = resolvePath(rootPath, pathArg)
+			// Security Check
+			if !isAllowedPath(ctx, targetPath) {
+				return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Reading the history of %s is not allowed.", pathArg)), nil
+			}
+			if pattern, ok := IsSecretFile(targetPath); ok {
+				return mcp.NewToolResultError(fmt.Sprintf("Access Denied: %s looks like a secret file (matches %q), its history is not shown.", pathArg, pattern)), nil
+			}
+		}
+
+		limit := min(max(request.GetInt("limit", defaultHistoryCommits), 1), maxHistoryCommits)
+		mode, commits, err := SymbolLog(ctx, rootPath, symbol, targetPath, limit, request.GetBool("patch", false))
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("git log failed: %v", err)), nil
+		}
+		history := SymbolHistory{Symbol: symbol, Path: pathArg, Mode: mode, Commits: commits}
+		return jsonToolResult(history, history), nil
+	}
+}
+
+// diffFileRefsTool returns the changes of a file between two refs.
+func diffFileRefsTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("diff_file_refs",
+		mcp.WithDescription("Return the unified diff of one file between two refs (branches, tags or commits), e.g. what changed in auth.go between v2.1 and main. Use it to explain a regression or a behavior change between versions."),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Absolute path to the file (or relative to project root)")),
+		mcp.WithString("from", mcp.Required(), mcp.Description("Older ref, e.g. v2.1")),
+		mcp.WithString("to", mcp.Required(), mcp.Description("Newer ref, e.g. main or HEAD")),
+		readOnlyAnnotations("Diff file between refs"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		pathArg, err := request.RequireString("path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		from, err := request.RequireString("from")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		to, err := request.RequireString("to")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		rootPath := projectRoot(ctx, rootPath)
+		targetPath := resolvePath(rootPath, pathArg)
+		// Security Check
+		if !isAllowedPath(ctx, targetPath) {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Reading the changes of %s is not allowed.", pathArg)), nil
+		}
+		if pattern, ok := IsSecretFile(targetPath); ok {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: %s looks like a secret file (matches %q), its changes are not shown.", pathArg, pattern)), nil
+		}
+		for _, ref := range []string{from, to} {
+			if _, err := ResolveRef(ctx, rootPath, ref); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+
+		diff, err := GitDiff(ctx, rootPath, from, to, false, targetPath)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("git diff failed: %v", err)), nil
+		}
+		if diff == "" {
+			return mcp.NewToolResultText(fmt.Sprintf("No changes to %s between %s and %s", pathArg, from, to)), nil
+		}
+		diff, _ = RedactSecrets(targetPath, diff)
+		return mcp.NewToolResultText(diff), nil
+	}
+}
+
+// lineHistoryTool returns the commits that changed a range of lines of a
+// file, with their patches.
+func lineHistoryTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("line_history",
+		mcp.WithDescription("Return the evolution of a block of lines of a file (git log -L start,end:file): the commits that changed it, newest first, each with its date and its patch to the block, following the lines as they moved. Use it on the function or block a search hit points into."),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Absolute path to the file (or relative to project root)")),
+		mcp.WithNumber("start", mcp.Required(), mcp.Description("First line of the block, 1-based, in the last committed version of the file (HEAD)")),
+		mcp.WithNumber("end", mcp.Description("Last line of the block, start by default")),
+		mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("Number of commits, %d by default, at most %d", defaultHistoryCommits, maxHistoryCommits))),
+		mcp.WithOutputSchema[LineHistory](),
+		readOnlyAnnotations("Line history"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		pathArg, err := request.RequireString("path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		start, err := request.RequireInt("start")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		end := request.GetInt("end", start)
+		if start < 1 || end < start {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid line range %d-%d: start must be at least 1 and end at least start", start, end)), nil
+		}
+
+		rootPath := projectRoot(ctx, rootPath)
+		targetPath := resolvePath(rootPath, pathArg)
+		// Security Check
+		if !isAllowedPath(ctx, targetPath) {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Reading the history of %s is not allowed.", pathArg)), nil
+		}
+		if pattern, ok := IsSecretFile(targetPath); ok {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: %s looks like a secret file (matches %q), its history is not shown.", pathArg, pattern)), nil
+		}
+
+		limit := min(max(request.GetInt("limit", defaultHistoryCommits), 1), maxHistoryCommits)
+		commits, err := lineLog(ctx, rootPath, targetPath, fmt.Sprintf("%d,%d", start, end), limit, true)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("git log failed: %v", err)), nil
+		}
+		history := LineHistory{Path: pathArg, Start: start, End: end, Commits: commits}
+		return jsonToolResult(history, history), nil
+	}
+}
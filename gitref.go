@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RefsDir holds the snapshots of the git refs searched with
+// SearchOptions.Ref, one directory per commit, relative to the project root.
+const RefsDir = ".codemcp/refs"
+
+// maxRefSnapshots bounds the number of commit snapshots kept on disk.
+const maxRefSnapshots = 5
+
+// ResolveRef returns the commit hash of ref (a branch, tag or commit) in the
+// repository of root.
+func ResolveRef(ctx context.Context, root string, ref string) (string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("invalid ref %q", ref)
+	}
+	out, err := runGit(ctx, root, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil || strings.TrimSpace(out) == "" {
+		return "", fmt.Errorf("unknown ref %q", ref)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// RefFiles returns the files of the tree of commit, relative to root, minus
+// the ignored ones, and the hashes of their blobs.
+func RefFiles(ctx context.Context, root string, commit string) ([]string, map[string]string, error) {
+	// Run in root, the paths are relative to it and limited to its files
+	out, err := runGit(ctx, root, "ls-tree", "-r", "-z", commit)
+	if err != nil {
+		return nil, nil, err
+	}
+	blobs := make(map[string]string)
+	var files []string
+	for _, entry := range strings.Split(out, "\x00") {
+		// <mode> SP <type> SP <object> TAB <path>
+		meta, path, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(meta)
+		// Submodules (commit) and symbolic links (120000) have no content to score
+		if !ok || len(fields) != 3 || fields[1] != "blob" || fields[0] == "120000" {
+			continue
+		}
+		blobs[path] = fields[2]
+		files = append(files, path)
+	}
+	return FilterIgnored(root, files), blobs, nil
+}
+
+// refSnapshot returns the directory holding the files of commit that have a
+// symbol extractor, written on first use with git cat-file, so the scoring
+// reads them like working tree files.
+func refSnapshot(ctx context.Context, root string, commit string, files []string, blobs map[string]string) (string, error) {
+	refsDir := filepath.Join(root, RefsDir)
+	dir := filepath.Join(refsDir, commit)
+	if _, err := os.Stat(dir); err == nil {
+		// Touched so pruning keeps the snapshots in use
+		now := time.Now()
+		_ = os.Chtimes(dir, now, now)
+		return dir, nil
+	}
+	if err := os.MkdirAll(refsDir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(refsDir, "tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	var wanted []string
+	for _, f := range files {
+		if _, ok := FileExtractor(f); ok {
+			wanted = append(wanted, f)
+		}
+	}
+	if err := writeBlobs(ctx, root, tmp, wanted, blobs); err != nil {
+		return "", err
+	}
+	// Another search may have written it meanwhile
+	if err := os.Rename(tmp, dir); err != nil {
+		if _, statErr := os.Stat(dir); statErr != nil {
+			return "", err
+		}
+	}
+	pruneSnapshots(refsDir)
+	return dir, nil
+}
+
+// writeBlobs writes the blobs of files under dir, read from a single
+// git cat-file --batch.
+func writeBlobs(ctx context.Context, root string, dir string, files []string, blobs map[string]string) error {
+	var stdin bytes.Buffer
+	for _, f := range files {
+		stdin.WriteString(blobs[f] + "\n")
+	}
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "--batch")
+	cmd.Dir = root
+	cmd.Stdin = &stdin
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer func() {
+		// Stops it when a write failed, before reading everything
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	r := bufio.NewReader(stdout)
+	for _, f := range files {
+		// <object> SP <type> SP <size> LF <contents> LF
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		var object, kind string
+		var size int64
+		if _, err := fmt.Sscanf(header, "%s %s %d", &object, &kind, &size); err != nil {
+			return fmt.Errorf("git cat-file: unexpected %q", strings.TrimSpace(header))
+		}
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(out, r, size)
+		out.Close()
+		if err != nil {
+			return err
+		}
+		if _, err := r.Discard(1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneSnapshots removes the least recently used snapshots beyond
+// maxRefSnapshots.
+func pruneSnapshots(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) <= maxRefSnapshots {
+		return
+	}
+	type aged struct {
+		name  string
+		mtime int64
+	}
+	var snapshots []aged
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && e.IsDir() && !strings.HasPrefix(e.Name(), "tmp-") {
+			snapshots = append(snapshots, aged{e.Name(), info.ModTime().UnixNano()})
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].mtime > snapshots[j].mtime })
+	for _, s := range snapshots[min(maxRefSnapshots, len(snapshots)):] {
+		_ = os.RemoveAll(filepath.Join(dir, s.name))
+		dropShards(filepath.Join(dir, s.name))
+	}
+}
+
+// RefSearch scores the files of the project at root as of ref, instead of
+// the working tree. The language servers only know the working tree, so
+// dependencies are not searched.
+func RefSearch(ctx context.Context, root string, ref string, terms []string, queryLower string) ([]FileScore, error) {
+	commit, err := ResolveRef(ctx, root, ref)
+	if err != nil {
+		return nil, err
+	}
+	files, blobs, err := RefFiles(ctx, root, commit)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := refSnapshot(ctx, root, commit, files, blobs)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot of %s: %w", ref, err)
+	}
+	return scoreFiles(ctx, dir, files, terms, queryLower)
+}
+
+// ReadRefFile returns the content of the project file at absPath as of ref.
+func ReadRefFile(ctx context.Context, root string, ref string, absPath string) ([]byte, error) {
+	rel, err := filepath.Rel(root, absPath)
+	if err != nil || !filepath.IsLocal(rel) {
+		return nil, fmt.Errorf("%s is outside the project root %s", absPath, root)
+	}
+	commit, err := ResolveRef(ctx, root, ref)
+	if err != nil {
+		return nil, err
+	}
+	// ./ makes the path relative to root rather than to the repository
+	content, err := runGit(ctx, root, "show", commit+":./"+filepath.ToSlash(rel))
+	return []byte(content), err
+}
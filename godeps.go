@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// goDepsReason prefixes the reasons of the module cache fallback results,
+// e.g. "deps:Unmarshal".
+const goDepsReason = "deps"
+
+// goDepsCache keeps the direct dependency directories of each project root,
+// invalidated when its go.mod changes.
+var goDepsCache = struct {
+	sync.Mutex
+	entries map[string]goDepsEntry
+}{entries: make(map[string]goDepsEntry)}
+
+type goDepsEntry struct {
+	modTime time.Time
+	size    int64
+	dirs    []string
+}
+
+// NeedsGoFallback reports whether Go is a detected language without a
+// running gopls (not installed, or failed to start), in which case
+// GoDepSearch stands in for dependency search. Disabling gopls with
+// -gopls=false disables the fallback too.
+func (m *LSPManager) NeedsGoFallback(ctx context.Context) bool {
+	if m == nil {
+		return false
+	}
+	for _, lang := range m.Detected(ctx) {
+		if lang.Name == "go" {
+			return m.Client("go") == nil
+		}
+	}
+	return false
+}
+
+// GoDepSearch approximates gopls workspace/symbol over the dependencies:
+// it scans the exported functions and types of the direct dependencies
+// (from go list -m all) in the module cache, matching query as a substring.
+func GoDepSearch(ctx context.Context, root string, query string) ([]FileScore, error) {
+	dirs, err := goDepDirs(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, dir := range dirs {
+		files = append(files, goPackageFiles(ctx, dir)...)
+	}
+
+	queryLower := strings.ToLower(strings.TrimSpace(query))
+	sh := getShard(root, "@"+goDepsReason) // Module cache files never change, keep their symbols
+	sem := make(chan struct{}, max(1, Workers))
+	results := make([]FileScore, len(files))
+	var wg sync.WaitGroup
+	for i, f := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, f string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = scoreGoDepFile(sh.Symbols(ctx, f, ExtractGoSymbols), f, query, queryLower)
+		}(i, f)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	matched := results[:0]
+	for _, r := range results {
+		if r.Score > 0 {
+			matched = append(matched, r)
+		}
+	}
+	return matched, nil
+}
+
+// scoreGoDepFile scores the exported symbols of a dependency file the way
+// SymbolSearch scores gopls hits, keeping the best one.
+func scoreGoDepFile(symbols []Symbol, path string, query string, queryLower string) FileScore {
+	best := FileScore{Path: path, IsDep: true}
+	for _, sym := range symbols {
+		r := []rune(sym.Name)
+		if len(r) == 0 || !unicode.IsUpper(r[0]) {
+			continue
+		}
+		nameLower := strings.ToLower(sym.Name)
+		if !strings.Contains(nameLower, queryLower) {
+			continue
+		}
+		// Base score, function/type boost and dependency penalty of SymbolSearch
+		score := Weights.LSPMatch + Weights.LSPKind - Weights.DependencyPenalty
+		if strings.EqualFold(sym.Name, query) {
+			score += Weights.LSPExact
+		} else if strings.HasPrefix(nameLower, queryLower) {
+			score += Weights.LSPPrefix
+		}
+		best.Score = max(best.Score, score)
+		best.Reasons = append(best.Reasons, goDepsReason+":"+sym.Name)
+	}
+	return best
+}
+
+// goDepDirs returns the module cache directories of the direct
+// dependencies of the module at root.
+func goDepDirs(ctx context.Context, root string) ([]string, error) {
+	info, err := os.Stat(filepath.Join(root, "go.mod"))
+	if os.IsNotExist(err) {
+		// No module (e.g. GOPATH mode): no module dependencies to list
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	goDepsCache.Lock()
+	entry, ok := goDepsCache.entries[root]
+	goDepsCache.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.dirs, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	// -e keeps listing the modules when some cannot be resolved (e.g. offline)
+	cmd := exec.CommandContext(ctx, "go", "list", "-m", "-e", "-json", "all")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+
+	var dirs []string
+	dec := json.NewDecoder(strings.NewReader(string(out)))
+	for dec.More() {
+		var mod struct {
+			Main     bool
+			Indirect bool
+			Dir      string
+			Replace  *struct {
+				Dir string
+			}
+		}
+		if err := dec.Decode(&mod); err != nil {
+			return nil, err
+		}
+		if mod.Main || mod.Indirect {
+			continue
+		}
+		dir := mod.Dir
+		if mod.Replace != nil && mod.Replace.Dir != "" {
+			dir = mod.Replace.Dir
+		}
+		// Not downloaded yet
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	goDepsCache.Lock()
+	goDepsCache.entries[root] = goDepsEntry{modTime: info.ModTime(), size: info.Size(), dirs: dirs}
+	goDepsCache.Unlock()
+	return dirs, nil
+}
+
+// goPackageFiles returns the absolute paths of the non-test Go files of the
+// module in dir, skipping testdata, vendor and nested modules.
+func goPackageFiles(ctx context.Context, dir string) []string {
+	var files []string
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || ctx.Err() != nil {
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != dir {
+				if name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+					return filepath.SkipDir
+				}
+				if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
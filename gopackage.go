@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// PackageSummary is the result of the package_summary tool: the doc, files,
+// imports and exported API of a Go package, like go doc -all.
+type PackageSummary struct {
+	ImportPath string        `json:"import_path"`
+	Name       string        `json:"name"`
+	Dir        string        `json:"dir"` // Relative to the project root when inside it
+	Doc        string        `json:"doc,omitempty"`
+	Files      []string      `json:"files"`   // Non-test Go files of the build configuration
+	Imports    []string      `json:"imports"` // Direct imports of these files
+	API        []PackageDecl `json:"api"`
+}
+
+// PackageDecl is an exported declaration of a package.
+type PackageDecl struct {
+	Kind      string `json:"kind"` // const, var, func, type or method
+	Name      string `json:"name"` // Type.Method for methods
+	Signature string `json:"signature"`
+	Doc       string `json:"doc,omitempty"` // First sentence of the doc comment
+}
+
+// SummarizePackage returns the summary of pkg, a directory relative to root
+// or an import path resolved by go list (project, module cache or standard
+// library), for the build configuration of Build.
+func SummarizePackage(ctx context.Context, root string, pkg string) (*PackageSummary, error) {
+	if !filepath.IsAbs(pkg) {
+		if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(pkg))); err == nil && info.IsDir() {
+			// go list reads "name" as an import path, "./name" as a directory
+			pkg = "./" + filepath.ToSlash(filepath.Clean(pkg))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	// -e reports the package even when some imports cannot be resolved
+	cmd := exec.CommandContext(ctx, "go", "list", "-e", "-json", "--", pkg)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), Build.Env()...)
+	out, err := cmd.Output()
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("package %s not found", pkg)
+	}
+	var listed struct {
+		ImportPath string
+		Name       string
+		Dir        string
+		GoFiles    []string
+		CgoFiles   []string
+		Imports    []string
+		Error      *struct{ Err string }
+	}
+	if err := json.Unmarshal(out, &listed); err != nil {
+		return nil, err
+	}
+	if listed.Dir == "" || len(listed.GoFiles)+len(listed.CgoFiles) == 0 {
+		if listed.Error != nil {
+			return nil, fmt.Errorf("package %s not found: %s", pkg, listed.Error.Err)
+		}
+		return nil, fmt.Errorf("package %s not found", pkg)
+	}
+
+	summary := &PackageSummary{
+		ImportPath: listed.ImportPath,
+		Name:       listed.Name,
+		Dir:        listed.Dir,
+		Files:      append(listed.GoFiles, listed.CgoFiles...),
+		Imports:    listed.Imports,
+		API:        []PackageDecl{},
+	}
+	if summary.Imports == nil {
+		summary.Imports = []string{}
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range summary.Files {
+		f, err := parser.ParseFile(fset, filepath.Join(listed.Dir, name), nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	p, err := doc.NewFromFiles(fset, files, listed.ImportPath)
+	if err != nil {
+		return nil, err
+	}
+	summary.Doc = strings.TrimSpace(p.Doc)
+	summary.API = packageAPI(fset, p)
+	return summary, nil
+}
+
+// packageAPI lists the exported declarations of p in go doc order: constants,
+// variables, functions, then each type with its constants, variables,
+// constructors and methods.
+func packageAPI(fset *token.FileSet, p *doc.Package) []PackageDecl {
+	api := []PackageDecl{}
+	values := func(values []*doc.Value) {
+		for _, v := range values {
+			api = append(api, PackageDecl{
+				Kind:      v.Decl.Tok.String(),
+				Name:      strings.Join(v.Names, ", "),
+				Signature: declSignature(fset, v.Decl),
+				Doc:       p.Synopsis(v.Doc),
+			})
+		}
+	}
+	funcs := func(funcs []*doc.Func, kind string) {
+		for _, f := range funcs {
+			name := f.Name
+			if f.Recv != "" {
+				name = strings.TrimPrefix(f.Recv, "*") + "." + f.Name
+			}
+			api = append(api, PackageDecl{Kind: kind, Name: name, Signature: declSignature(fset, f.Decl), Doc: p.Synopsis(f.Doc)})
+		}
+	}
+
+	values(p.Consts)
+	values(p.Vars)
+	funcs(p.Funcs, "func")
+	for _, t := range p.Types {
+		api = append(api, PackageDecl{Kind: "type", Name: t.Name, Signature: declSignature(fset, t.Decl), Doc: p.Synopsis(t.Doc)})
+		values(t.Consts)
+		values(t.Vars)
+		funcs(t.Funcs, "func")
+		funcs(t.Methods, "method")
+	}
+	return api
+}
+
+// declSignature prints decl without its doc comment, and functions without
+// their body. go/doc already stripped the unexported fields and methods.
+func declSignature(fset *token.FileSet, decl ast.Decl) string {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		sig := *d
+		sig.Body, sig.Doc = nil, nil
+		decl = &sig
+	case *ast.GenDecl:
+		short := *d
+		short.Doc = nil
+		decl = &short
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, decl); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// packageSummaryTool returns the summary of a Go package.
+func packageSummaryTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("package_summary",
+		mcp.WithDescription("Return the summary of a Go package of the project or of its dependencies: package doc, files, direct imports and exported API (constants, variables, functions, types and methods with their signature and first doc sentence), like go doc -all. Use it to learn a package before reading its files."),
+		mcp.WithString("package", mcp.Required(), mcp.Description("Import path (e.g. net/http, github.com/org/mod/pkg) or directory relative to project root (e.g. internal/auth, . for the root package)")),
+		mcp.WithOutputSchema[PackageSummary](),
+		readOnlyAnnotations("Go package summary"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		pkg, err := request.RequireString("package")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		rootPath := projectRoot(ctx, rootPath)
+		summary, err := SummarizePackage(ctx, rootPath, pkg)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if !isAllowedPath(ctx, summary.Dir) {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: package %s is outside the project and its dependencies", pkg)), nil
+		}
+		if rel, err := filepath.Rel(rootPath, summary.Dir); err == nil && filepath.IsLocal(rel) {
+			summary.Dir = filepath.ToSlash(rel)
+		}
+		return jsonToolResult(summary, summary), nil
+	}
+}
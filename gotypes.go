@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TypedPackage is a Go package of the project, parsed and type-checked.
+// With tests, a package with test files is checked twice: alone, as its
+// importers see it, then with its in-package tests (Test set), and its
+// external tests (package x_test) are a package of their own.
+type TypedPackage struct {
+	ImportPath string
+	Dir        string
+	Test       bool
+	Files      []*ast.File
+	Types      *types.Package
+	Info       *types.Info
+}
+
+// listedPackage is a package reported by go list -json.
+type listedPackage struct {
+	ImportPath   string
+	Dir          string
+	Export       string
+	ForTest      string
+	DepOnly      bool
+	GoFiles      []string
+	CgoFiles     []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	ImportMap    map[string]string
+	Module       *struct{ Main bool }
+}
+
+// typeLoader type-checks the project packages from source, and reads the
+// other packages from the export data go list -export compiled.
+type typeLoader struct {
+	fset    *token.FileSet
+	project map[string]*listedPackage
+	export  map[string]string // Export data file by import path
+	gc      types.Importer
+	checked map[string]*TypedPackage
+	parsed  map[string]*ast.File
+	loading map[string]bool
+}
+
+// LoadTypedPackages parses and type-checks the packages of the module at
+// root, for the build configuration of Build. Type errors are ignored: the
+// packages are checked as far as possible.
+func LoadTypedPackages(ctx context.Context, root string, tests bool) (*token.FileSet, []*TypedPackage, error) {
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		return nil, nil, errors.New("no go.mod in the project root")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	args := []string{"list", "-e", "-export", "-deps", "-json"}
+	if tests {
+		// The dependencies of the tests need export data too
+		args = append(args, "-test")
+	}
+	out, err := goCommand(ctx, root, append(args, "./...")...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l := &typeLoader{
+		fset:    token.NewFileSet(),
+		project: make(map[string]*listedPackage),
+		export:  make(map[string]string),
+		checked: make(map[string]*TypedPackage),
+		parsed:  make(map[string]*ast.File),
+		loading: make(map[string]bool),
+	}
+	l.gc = importer.ForCompiler(l.fset, "gc", func(path string) (io.ReadCloser, error) {
+		file, ok := l.export[path]
+		if !ok || file == "" {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(file)
+	})
+	var order []string
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		p := &listedPackage{}
+		if err := dec.Decode(p); err != nil {
+			return nil, nil, err
+		}
+		// Test variants ("p [p.test]") and test mains ("p.test") are
+		// rebuilt from the files of p
+		if p.ForTest != "" || strings.HasSuffix(p.ImportPath, ".test") {
+			continue
+		}
+		if p.Module != nil && p.Module.Main && !p.DepOnly {
+			l.project[p.ImportPath] = p
+			order = append(order, p.ImportPath)
+		} else {
+			l.export[p.ImportPath] = p.Export
+		}
+	}
+
+	var pkgs []*TypedPackage
+	for _, path := range order {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		p := l.project[path]
+		if len(p.GoFiles)+len(p.CgoFiles) > 0 {
+			if tp, err := l.load(path); err == nil {
+				pkgs = append(pkgs, tp)
+			}
+		}
+		if !tests || len(p.TestGoFiles)+len(p.XTestGoFiles) == 0 {
+			continue
+		}
+		inTest := l.check(p, append(append(append([]string{}, p.GoFiles...), p.CgoFiles...), p.TestGoFiles...), p.ImportPath, nil)
+		inTest.Test = true
+		if len(p.TestGoFiles) > 0 {
+			pkgs = append(pkgs, inTest)
+		}
+		if len(p.XTestGoFiles) > 0 {
+			// package x_test imports the package with its in-package tests
+			xTest := l.check(p, p.XTestGoFiles, p.ImportPath+"_test", map[string]*types.Package{p.ImportPath: inTest.Types})
+			xTest.Test = true
+			pkgs = append(pkgs, xTest)
+		}
+	}
+	return l.fset, pkgs, nil
+}
+
+// load returns the project package path checked without its tests.
+func (l *typeLoader) load(path string) (*TypedPackage, error) {
+	if tp, ok := l.checked[path]; ok {
+		return tp, nil
+	}
+	if l.loading[path] {
+		return nil, fmt.Errorf("import cycle through %s", path)
+	}
+	l.loading[path] = true
+	defer delete(l.loading, path)
+	p := l.project[path]
+	tp := l.check(p, append(append([]string{}, p.GoFiles...), p.CgoFiles...), path, nil)
+	l.checked[path] = tp
+	return tp, nil
+}
+
+// check type-checks the files of p (names relative to its directory) as the
+// package path, importing the packages of override in place of the others.
+func (l *typeLoader) check(p *listedPackage, names []string, path string, override map[string]*types.Package) *TypedPackage {
+	tp := &TypedPackage{ImportPath: path, Dir: p.Dir}
+	for _, name := range names {
+		absPath := filepath.Join(p.Dir, name)
+		f, ok := l.parsed[absPath]
+		if !ok {
+			// Keep what parsed, as the type checker goes on past errors
+			if f, _ = parser.ParseFile(l.fset, absPath, nil, parser.ParseComments|parser.SkipObjectResolution); f == nil {
+				continue
+			}
+			l.parsed[absPath] = f
+		}
+		tp.Files = append(tp.Files, f)
+	}
+	tp.Info = &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := types.Config{
+		Importer: importerFunc(func(imp string) (*types.Package, error) {
+			if mapped, ok := p.ImportMap[imp]; ok {
+				imp = mapped
+			}
+			if pkg, ok := override[imp]; ok {
+				return pkg, nil
+			}
+			if _, ok := l.project[imp]; ok {
+				dep, err := l.load(imp)
+				if err != nil {
+					return nil, err
+				}
+				return dep.Types, nil
+			}
+			return l.gc.Import(imp)
+		}),
+		Error:       func(error) {}, // Keep checking past type errors
+		FakeImportC: true,
+	}
+	tp.Types, _ = conf.Check(path, l.fset, tp.Files, tp.Info)
+	return tp
+}
+
+// importerFunc adapts a function to types.Importer.
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Content search backends of grep_files.
+const (
+	GrepAuto = "auto" // git in git repositories, go otherwise
+	GrepGit  = "git"  // git grep, on the files of CollectFiles
+	GrepGo   = "go"   // Scans the files of CollectFiles
+)
+
+// GrepBackend selects how grep_files searches the file contents: GrepAuto,
+// GrepGit or GrepGo.
+// Set via grep_backend in the config, the --grep-backend flag or
+// CODEMCP_GREP_BACKEND.
+var GrepBackend = GrepAuto
+
+// Limits of the number of lines returned by grep_files.
+const (
+	defaultGrepMatches = 100
+	maxGrepMatches     = 1000
+)
+
+// GrepMatch is a line matching a grep_files pattern.
+type GrepMatch struct {
+	Path string // Relative to the root
+	Line int
+	Text string
+}
+
+// grepBackend returns the backend of root selected by GrepBackend.
+func grepBackend(root string) string {
+	if GrepBackend != GrepAuto {
+		return GrepBackend
+	}
+	if !inGitWorkTree(root) {
+		return GrepGo
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return GrepGo
+	}
+	return GrepGit
+}
+
+// Grep returns the first limit lines of the files of root, under absPath
+// when set, matching the extended regular expression pattern, secret files
+// excluded.
+func Grep(ctx context.Context, root string, pattern string, ignoreCase bool, absPath string, limit int) ([]GrepMatch, error) {
+	expr := pattern
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	rel := "."
+	if absPath != "" {
+		if rel, err = filepath.Rel(root, absPath); err != nil || !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("%s is outside the project root %s", absPath, root)
+		}
+	}
+
+	switch backend := grepBackend(root); backend {
+	case GrepGit:
+		return gitGrep(ctx, root, pattern, ignoreCase, rel, limit)
+	case GrepGo:
+		return goGrep(ctx, root, re, rel, limit)
+	default:
+		return nil, fmt.Errorf("invalid grep backend %q", backend)
+	}
+}
+
+// gitGrep runs git grep in root on the files under rel, the untracked and
+// ignored ones according to IncludeUntracked and IncludeIgnored, reading its
+// output until limit lines matched.
+func gitGrep(ctx context.Context, root string, pattern string, ignoreCase bool, rel string, limit int) ([]GrepMatch, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	args := []string{"grep", "-n", "-I", "-z", "--no-color", "-E", "-e", pattern}
+	if ignoreCase {
+		args = append(args, "-i")
+	}
+	if IncludeUntracked || IncludeIgnored {
+		args = append(args, "--untracked")
+	}
+	if IncludeIgnored {
+		args = append(args, "--no-exclude-standard")
+	}
+	args = append(args, "--", filepath.ToSlash(rel))
+	args = append(args, secretPathspecs()...)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	var matches []GrepMatch
+	kept := make(map[string]bool) // Paths passing FilterIgnored
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for len(matches) < limit && scanner.Scan() {
+		// <path> NUL <line> NUL <text>
+		fields := strings.SplitN(scanner.Text(), "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		line, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		path := fields[0]
+		ok, seen := kept[path]
+		if !seen {
+			ok = len(FilterIgnored(root, []string{path})) == 1
+			kept[path] = ok
+		}
+		if ok {
+			matches = append(matches, GrepMatch{Path: path, Line: line, Text: fields[2]})
+		}
+	}
+	if len(matches) >= limit {
+		// Enough lines, stop git
+		cancel()
+		_ = cmd.Wait()
+		return matches, nil
+	}
+	if err := cmd.Wait(); err != nil {
+		// Exit status 1 without message: nothing matched
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && stderr.Len() == 0 {
+			return matches, nil
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return matches, scanner.Err()
+}
+
+// goGrep scans the files of CollectFiles under rel, skipping binary and
+// secret files, until limit lines matched re.
+func goGrep(ctx context.Context, root string, re *regexp.Regexp, rel string, limit int) ([]GrepMatch, error) {
+	files, err := CollectFiles(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.ToSlash(rel) + "/"
+	var matches []GrepMatch
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return matches, err
+		}
+		slash := filepath.ToSlash(f)
+		if f == "" || rel != "." && slash != filepath.ToSlash(rel) && !strings.HasPrefix(slash, prefix) {
+			continue
+		}
+		absPath := filepath.Join(root, f)
+		if _, ok := IsSecretFile(absPath); ok {
+			continue
+		}
+		content, err := os.ReadFile(absPath)
+		// Binary files hold a NUL byte early on, like git and grep assume
+		if err != nil || bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0 {
+			continue
+		}
+		for i, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSuffix(line, "\r")
+			if re.MatchString(line) {
+				matches = append(matches, GrepMatch{Path: slash, Line: i + 1, Text: line})
+				if len(matches) >= limit {
+					return matches, nil
+				}
+			}
+		}
+	}
+	return matches, nil
+}
+
+// grepTool searches the contents of the project files with a regular
+// expression.
+func grepTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("grep_files",
+		mcp.WithDescription("Search the contents of the project files for a regular expression and return the matching lines as path:line:text. Use it for text search_files cannot find: strings, comments, config keys, call sites. Binary and secret files are skipped."),
+		mcp.WithString("pattern", mcp.Required(), mcp.Description("Extended regular expression, e.g. \"TODO|FIXME\" or \"func \\(s \\*Server\\)\"")),
+		mcp.WithString("path", mcp.Description("Limit the search to this file or directory (absolute or relative to project root)")),
+		mcp.WithBoolean("ignore_case", mcp.Description("Match case-insensitively")),
+		mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("Number of lines, %d by default, at most %d", defaultGrepMatches, maxGrepMatches))),
+		mcp.WithNumber("offset", mcp.Description("Number of matching lines to skip, given at the end of a previous result to get the next lines")),
+		readOnlyAnnotations("Grep files"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		pattern, err := request.RequireString("pattern")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		rootPath := projectRoot(ctx, rootPath)
+		var targetPath string
+		if pathArg := request.GetString("path", ""); pathArg != "" {
+			targetPath = resolvePath(rootPath, pathArg)
+			// Security Check
+			if !isAllowedPath(ctx, targetPath) {
+				return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Searching %s is not allowed.", pathArg)), nil
+			}
+			if pattern, ok := IsSecretFile(targetPath); ok {
+				return mcp.NewToolResultError(fmt.Sprintf("Access Denied: %s looks like a secret file (matches %q), it is not searched.", pathArg, pattern)), nil
+			}
+		}
+
+		limit := min(max(request.GetInt("limit", defaultGrepMatches), 1), maxGrepMatches)
+		offset := max(request.GetInt("offset", 0), 0)
+		matches, err := Grep(ctx, rootPath, pattern, request.GetBool("ignore_case", false), targetPath, offset+limit)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("grep failed: %v", err)), nil
+		}
+		more := len(matches) == offset+limit
+		matches = matches[min(offset, len(matches)):]
+		if len(matches) == 0 {
+			return mcp.NewToolResultText("No matches"), nil
+		}
+		lines := make([]string, len(matches))
+		for i, m := range matches {
+			text, _ := RedactSecrets(m.Path, m.Text)
+			lines[i] = fmt.Sprintf("%s:%d:%s\n", m.Path, m.Line, text)
+		}
+		// The lines over MaxResultBytes come with the next page
+		n := fitLines(lines)
+		text := strings.Join(lines[:n], "")
+		switch {
+		case n < len(lines):
+			text = truncationMarker(text, "lines %d-%d shown, over the %d bytes result limit; call grep_files with offset=%d for the rest", offset+1, offset+n, MaxResultBytes, offset+n)
+		case more:
+			text += fmt.Sprintf("[limit of %d lines reached; call grep_files with offset=%d for the next lines, or narrow the pattern or path]\n", limit, offset+n)
+		}
+		return mcp.NewToolResultText(text), nil
+	}
+}
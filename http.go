@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+var (
+	// ListenAddr is the address the MCP server listens on for HTTP clients,
+	// e.g. ":8080". Empty serves stdio.
+	// Set via the --listen flag or CODEMCP_LISTEN.
+	ListenAddr string
+
+	// AuthToken, when set, is the bearer token HTTP clients must send in
+	// their Authorization header.
+	// Set via the --auth-token flag or CODEMCP_AUTH_TOKEN.
+	AuthToken string
+
+	// TLSCert and TLSKey are the certificate and key files serving HTTPS.
+	// Set via the --tls-cert and --tls-key flags.
+	TLSCert, TLSKey string
+)
+
+// shutdownTimeout bounds the wait for the HTTP sessions to close on exit.
+const shutdownTimeout = 5 * time.Second
+
+// HTTP endpoints of the MCP server.
+const (
+	StreamableEndpoint = "/mcp"     // Streamable HTTP transport
+	SSEEndpoint        = "/sse"     // SSE transport event stream
+	MessageEndpoint    = "/message" // SSE transport client messages
+)
+
+// serveHTTP serves s on addr until ctx is cancelled, with both the
+// streamable HTTP transport (at /mcp) and the SSE transport (event stream at
+// /sse, messages posted to /message), completing arguments in the project at
+// root with completions. Requests must carry AuthToken when set,
+// and a valid RootHeader if any, and are served over TLS with TLSCert and
+// TLSKey. With AdminToken, AdminEndpoint changes the tools of s.
+func serveHTTP(ctx context.Context, s *server.MCPServer, completions *completer, addr string, root string, tools *ToolSet) error {
+	if (TLSCert == "") != (TLSKey == "") {
+		return errors.New("-tls-cert and -tls-key must be set together")
+	}
+	if AuthToken == "" {
+		slog.Warn("MCP server accepts HTTP requests without authentication, set -auth-token to require one")
+	}
+
+	httpServer := &http.Server{Addr: addr, ReadHeaderTimeout: 10 * time.Second}
+	sse := server.NewSSEServer(s,
+		server.WithHTTPServer(httpServer),
+		server.WithSSEEndpoint(SSEEndpoint),
+		server.WithMessageEndpoint(MessageEndpoint),
+		server.WithKeepAlive(true),
+	)
+	streamable := server.NewStreamableHTTPServer(s, server.WithEndpointPath(StreamableEndpoint))
+
+	mux := http.NewServeMux()
+	mux.Handle(StreamableEndpoint, completeHTTP(completions, root, streamable))
+	mux.Handle(SSEEndpoint, sse)
+	mux.Handle(MessageEndpoint, sse)
+	httpServer.Handler = requireToken(AuthToken, checkSessionRoot(mux))
+	if AdminToken != "" {
+		admin := http.NewServeMux()
+		admin.Handle(AdminEndpoint, requireToken(AdminToken, adminHandler(tools)))
+		admin.Handle("/", httpServer.Handler)
+		httpServer.Handler = admin
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		// Closes the SSE sessions, then the HTTP server
+		_ = sse.Shutdown(shutdownCtx)
+	}()
+
+	var err error
+	if TLSCert != "" {
+		slog.Info("MCP server listening", "addr", addr, "tls", true, "streamable", StreamableEndpoint, "sse", SSEEndpoint)
+		err = httpServer.ListenAndServeTLS(TLSCert, TLSKey)
+	} else {
+		slog.Info("MCP server listening", "addr", addr, "streamable", StreamableEndpoint, "sse", SSEEndpoint)
+		err = httpServer.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// requireToken wraps next to reject the requests without the bearer token.
+// An empty token lets every request through.
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="codemcp"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkSessionRoot wraps next to reject the requests whose RootHeader is not
+// a directory of SessionRootDirs, before they initialize a session.
+func checkSessionRoot(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dir := r.Header.Get(RootHeader); dir != "" {
+			if _, err := resolveSessionRoot(dir); err != nil {
+				http.Error(w, "invalid "+RootHeader+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile holds codemcp specific ignore patterns (fixtures, dist...), in
+// gitignore syntax. Like .gitignore it may appear in any directory.
+const IgnoreFile = ".codemcpignore"
+
+// IgnoreFiles lists the per-directory files read for ignore patterns
+// when walking a tree that is not handled by git.
+var IgnoreFiles = []string{".gitignore", ".ignore", IgnoreFile}
+
+var (
+	// IncludeUntracked keeps the files git does not track yet (and does not
+	// ignore) in git repositories.
+	// Set via untracked in the config, the --untracked flag or
+	// CODEMCP_UNTRACKED.
+	IncludeUntracked = true
+
+	// IncludeIgnored keeps the files git ignores (e.g. generated code under
+	// build/), and skips the .gitignore and .ignore files outside git
+	// repositories. IgnoreDirs and .codemcpignore files still apply.
+	// Set via ignored in the config, the --ignored flag or CODEMCP_IGNORED.
+	IncludeIgnored = false
+)
+
+// ignoreRule is a single compiled .gitignore pattern.
+type ignoreRule struct {
+	base    string // Directory (relative to root, slash separated) holding the ignore file
+	re      *regexp.Regexp
+	negate  bool // Pattern started with '!'
+	dirOnly bool // Pattern ended with '/'
+}
+
+// IgnoreMatcher evaluates gitignore-style rules collected while walking a tree.
+// Rules from deeper directories are appended last and therefore win,
+// mirroring git precedence.
+type IgnoreMatcher struct {
+	rules []ignoreRule
+}
+
+// LoadDir reads the ignore files of dir (relative to root) and appends their
+// rules. With IncludeIgnored, only IgnoreFile is read.
+func (m *IgnoreMatcher) LoadDir(root string, dir string) {
+	names := IgnoreFiles
+	if IncludeIgnored {
+		names = []string{IgnoreFile}
+	}
+	for _, name := range names {
+		m.loadFile(filepath.Join(root, dir, name), filepath.ToSlash(dir))
+	}
+}
+
+func (m *IgnoreMatcher) loadFile(path string, base string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if base == "." {
+		base = ""
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text(), base); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+}
+
+// parseIgnoreLine compiles one line of a .gitignore file.
+// It returns false for blank lines and comments.
+func parseIgnoreLine(line string, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A pattern containing a slash (other than a trailing one) is anchored
+	// to the directory of the ignore file, otherwise it matches at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globToRegexp translates gitignore glob syntax (*, ?, [...], **) to a regexp.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// "**/" matches zero or more directories
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			j := strings.IndexByte(glob[i:], ']')
+			if j < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += j
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// Match reports whether relPath (relative to root, slash separated) is ignored.
+func (m *IgnoreMatcher) Match(relPath string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		p := relPath
+		if r.base != "" {
+			if !strings.HasPrefix(p, r.base+"/") {
+				continue
+			}
+			p = strings.TrimPrefix(p, r.base+"/")
+		}
+		if r.re.MatchString(p) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// FilterIgnored drops the files listed by git (relative to root) that git
+// does not ignore but codemcp does: those under IgnoreDirs or excluded by
+// .codemcpignore files.
+func FilterIgnored(root string, files []string) []string {
+	matcher := &IgnoreMatcher{}
+	loaded := make(map[string]bool)
+	load := func(dir string) {
+		if !loaded[dir] {
+			loaded[dir] = true
+			matcher.loadFile(filepath.Join(root, filepath.FromSlash(dir), IgnoreFile), dir)
+		}
+	}
+
+	// Directory verdicts, each directory is evaluated once
+	ignoredDirs := make(map[string]bool)
+	var dirIgnored func(dir string) bool
+	dirIgnored = func(dir string) bool {
+		if ignored, ok := ignoredDirs[dir]; ok {
+			return ignored
+		}
+		parent := path.Dir(dir)
+		// Patterns of the parents are loaded before those of dir, as in WalkFiles
+		ignored := parent != "." && dirIgnored(parent)
+		if !ignored {
+			load(parent)
+			ignored = IgnoreDirs[path.Base(dir)] || matcher.Match(dir, true)
+		}
+		ignoredDirs[dir] = ignored
+		return ignored
+	}
+
+	kept := files[:0]
+	for _, f := range files {
+		if f == "" {
+			continue
+		}
+		relSlash := filepath.ToSlash(f)
+		dir := path.Dir(relSlash)
+		if dir != "." && dirIgnored(dir) {
+			continue
+		}
+		load(dir)
+		if matcher.Match(relSlash, false) {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// WalkFiles lists files under root with filepath.WalkDir, skipping IgnoreDirs
+// and anything excluded by .gitignore/.ignore/.codemcpignore files found
+// along the way.
+// Returned paths are relative to root.
+func WalkFiles(ctx context.Context, root string) ([]string, error) {
+	matcher := &IgnoreMatcher{}
+	var files []string
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable entries are skipped, not fatal
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		relSlash := filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel != "." {
+				if IgnoreDirs[d.Name()] || matcher.Match(relSlash, true) {
+					return filepath.SkipDir
+				}
+			}
+			// Patterns of a directory apply to everything below it
+			matcher.LoadDir(root, rel)
+			return nil
+		}
+
+		if !d.Type().IsRegular() || matcher.Match(relSlash, false) {
+			return nil
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files, err
+}
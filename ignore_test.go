@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIgnoreMatcherMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string // Lines of a root ignore file
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{"plain at root", []string{"*.log"}, "debug.log", false, true},
+		{"plain at any depth", []string{"*.log"}, "a/b/debug.log", false, true},
+		{"plain no match", []string{"*.log"}, "debug.go", false, false},
+		{"star stays in a segment", []string{"a*c"}, "ab/c", false, false},
+		{"question mark", []string{"file?.txt"}, "file1.txt", false, true},
+		{"character class", []string{"file[0-9].txt"}, "file7.txt", false, true},
+		{"negated class", []string{"file[!0-9].txt"}, "file7.txt", false, false},
+		{"comment", []string{"# debug.log"}, "# debug.log", false, false},
+		{"escaped hash", []string{`\#notes`}, "#notes", false, true},
+
+		{"negation", []string{"*.log", "!keep.log"}, "keep.log", false, false},
+		{"negation keeps others", []string{"*.log", "!keep.log"}, "other.log", false, true},
+		{"negation order", []string{"!keep.log", "*.log"}, "keep.log", false, true},
+		{"negation nested", []string{"*.log", "!keep.log"}, "a/keep.log", false, false},
+
+		{"anchored at root", []string{"/build"}, "build", true, true},
+		{"anchored not deeper", []string{"/build"}, "src/build", true, false},
+		{"slash anchors", []string{"doc/*.txt"}, "doc/a.txt", false, true},
+		{"slash anchors to one level", []string{"doc/*.txt"}, "doc/x/a.txt", false, false},
+		{"slash anchors not deeper", []string{"doc/*.txt"}, "src/doc/a.txt", false, false},
+
+		{"dir only matches dirs", []string{"out/"}, "out", true, true},
+		{"dir only skips files", []string{"out/"}, "out", false, false},
+		{"dir only at any depth", []string{"out/"}, "a/out", true, true},
+
+		{"leading double star", []string{"**/foo"}, "foo", false, true},
+		{"leading double star deep", []string{"**/foo"}, "a/b/foo", false, true},
+		{"trailing double star", []string{"foo/**"}, "foo/a/b.go", false, true},
+		{"trailing double star not the dir", []string{"foo/**"}, "foo", true, false},
+		{"middle double star none", []string{"a/**/b"}, "a/b", false, true},
+		{"middle double star many", []string{"a/**/b"}, "a/x/y/b", false, true},
+		{"middle double star anchored", []string{"a/**/b"}, "z/a/x/b", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &IgnoreMatcher{}
+			for _, line := range tt.patterns {
+				if rule, ok := parseIgnoreLine(line, ""); ok {
+					m.rules = append(m.rules, rule)
+				}
+			}
+			if got := m.Match(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Match(%q, %v) with %q = %v, want %v", tt.path, tt.isDir, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreMatcherBase(t *testing.T) {
+	m := &IgnoreMatcher{}
+	for _, r := range []struct{ line, base string }{
+		{"*.gen.go", ""},
+		{"!keep.gen.go", "sub"},
+		{"/local", "sub"},
+	} {
+		rule, ok := parseIgnoreLine(r.line, r.base)
+		if !ok {
+			t.Fatalf("parseIgnoreLine(%q) failed", r.line)
+		}
+		m.rules = append(m.rules, rule)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"a.gen.go", true},
+		{"sub/a.gen.go", true},
+		{"sub/keep.gen.go", false},
+		{"keep.gen.go", true},
+		{"sub/local", true},
+		{"local", false},
+		{"sub/x/local", false},
+	}
+	for _, tt := range tests {
+		if got := m.Match(tt.path, false); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestWalkFilesNestedIgnores(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":              "*.log\n/dist/\n",
+		"main.go":                 "",
+		"debug.log":               "",
+		"dist/bundle.js":          "",
+		"web/dist/app.js":         "",
+		"web/.gitignore":          "!keep.log\ngen/\n",
+		"web/keep.log":            "",
+		"web/other.log":           "",
+		"web/gen/types.ts":        "",
+		"web/src/gen/types.ts":    "",
+		"web/.codemcpignore":      "fixtures/**\n",
+		"web/fixtures/a/b.json":   "",
+		"node_modules/x/index.js": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := WalkFiles(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range got {
+		got[i] = filepath.ToSlash(got[i])
+	}
+	slices.Sort(got)
+	want := []string{
+		".gitignore",
+		"main.go",
+		"web/.codemcpignore",
+		"web/.gitignore",
+		"web/dist/app.js",
+		"web/keep.log",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("WalkFiles =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ImportGraph is the result of the import_graph tool: the packages of the
+// project and the imports between them.
+type ImportGraph struct {
+	Module   string         `json:"module"`
+	Packages []GraphPackage `json:"packages"`
+	Cycles   [][]string     `json:"cycles,omitempty"` // Packages importing each other, in cycle order
+}
+
+// GraphPackage is a package of the project. Packages are named by their
+// directory relative to the project root, "." for the root package.
+type GraphPackage struct {
+	Path       string   `json:"path"`
+	ImportPath string   `json:"import_path"`
+	Imports    []string `json:"imports,omitempty"`     // Project packages imported
+	ImportedBy []string `json:"imported_by,omitempty"` // Project packages importing it
+	InCycle    bool     `json:"in_cycle,omitempty"`
+}
+
+// PackageImports returns the import graph of the packages of the module at
+// root, from go list ./..., their tests' imports included with tests. With
+// pkg (a directory relative to root or an import path), only pkg and the
+// packages depending on it, directly or not, are returned.
+func PackageImports(ctx context.Context, root string, pkg string, tests bool) (*ImportGraph, error) {
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		return nil, errors.New("no go.mod in the project root")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	// -e lists the packages with errors too, such as import cycles
+	out, err := goCommand(ctx, root, "list", "-e", "-json", "./...")
+	if err != nil {
+		return nil, err
+	}
+
+	type listed struct {
+		ImportPath  string
+		Dir         string
+		Module      *struct{ Path string }
+		Imports     []string
+		TestImports []string
+	}
+	var pkgs []listed
+	graph := &ImportGraph{Packages: []GraphPackage{}}
+	names := make(map[string]string) // Import path to directory
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var p listed
+		if err := dec.Decode(&p); err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(root, p.Dir)
+		if err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		if graph.Module == "" && p.Module != nil {
+			graph.Module = p.Module.Path
+		}
+		names[p.ImportPath] = filepath.ToSlash(rel)
+		pkgs = append(pkgs, p)
+	}
+
+	imports := make(map[string][]string)
+	for _, p := range pkgs {
+		from := names[p.ImportPath]
+		all := p.Imports
+		if tests {
+			all = append(slices.Clone(all), p.TestImports...)
+		}
+		for _, imp := range all {
+			if to, ok := names[imp]; ok && to != from && !slices.Contains(imports[from], to) {
+				imports[from] = append(imports[from], to)
+			}
+		}
+		sort.Strings(imports[from])
+	}
+	importedBy := make(map[string][]string)
+	for from, tos := range imports {
+		for _, to := range tos {
+			importedBy[to] = append(importedBy[to], from)
+		}
+	}
+
+	keep := func(string) bool { return true }
+	if pkg != "" {
+		target, ok := names[pkg]
+		if !ok {
+			target = filepath.ToSlash(filepath.Clean(pkg))
+			if !slices.ContainsFunc(pkgs, func(p listed) bool { return names[p.ImportPath] == target }) {
+				return nil, fmt.Errorf("package %s not found in the project", pkg)
+			}
+		}
+		dependents := map[string]bool{target: true}
+		queue := []string{target}
+		for len(queue) > 0 {
+			for _, d := range importedBy[queue[0]] {
+				if !dependents[d] {
+					dependents[d] = true
+					queue = append(queue, d)
+				}
+			}
+			queue = queue[1:]
+		}
+		keep = func(p string) bool { return dependents[p] }
+	}
+
+	cycles := importCycles(imports)
+	inCycle := make(map[string]bool)
+	for _, c := range cycles {
+		for _, p := range c {
+			inCycle[p] = true
+		}
+		if slices.ContainsFunc(c, keep) {
+			graph.Cycles = append(graph.Cycles, c)
+		}
+	}
+	for _, p := range pkgs {
+		path := names[p.ImportPath]
+		if !keep(path) {
+			continue
+		}
+		by := importedBy[path]
+		sort.Strings(by)
+		graph.Packages = append(graph.Packages, GraphPackage{
+			Path:       path,
+			ImportPath: p.ImportPath,
+			Imports:    imports[path],
+			ImportedBy: by,
+			InCycle:    inCycle[path],
+		})
+	}
+	sort.Slice(graph.Packages, func(i, j int) bool { return graph.Packages[i].Path < graph.Packages[j].Path })
+	return graph, nil
+}
+
+// importCycles returns the strongly connected components of more than one
+// package of the graph of imports (Tarjan's algorithm), each ordered along a
+// cycle from its smallest package.
+func importCycles(imports map[string][]string) [][]string {
+	nodes := make([]string, 0, len(imports))
+	for n := range imports {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+	var visit func(n string)
+	visit = func(n string) {
+		index[n], low[n] = len(index), len(index)
+		stack = append(stack, n)
+		onStack[n] = true
+		for _, m := range imports[n] {
+			if _, seen := index[m]; !seen {
+				visit(m)
+				low[n] = min(low[n], low[m])
+			} else if onStack[m] {
+				low[n] = min(low[n], index[m])
+			}
+		}
+		if low[n] != index[n] {
+			return
+		}
+		var scc []string
+		for {
+			m := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[m] = false
+			scc = append(scc, m)
+			if m == n {
+				break
+			}
+		}
+		if len(scc) > 1 {
+			cycles = append(cycles, cycleOrder(scc, imports))
+		}
+	}
+	for _, n := range nodes {
+		if _, seen := index[n]; !seen {
+			visit(n)
+		}
+	}
+	return cycles
+}
+
+// cycleOrder orders the packages of scc by following their imports from the
+// smallest one, so that each imports the next, as far as a single path goes.
+func cycleOrder(scc []string, imports map[string][]string) []string {
+	sort.Strings(scc)
+	ordered := []string{scc[0]}
+	seen := map[string]bool{scc[0]: true}
+	for len(ordered) < len(scc) {
+		next := ""
+		for _, m := range imports[ordered[len(ordered)-1]] {
+			if slices.Contains(scc, m) && !seen[m] {
+				next = m
+				break
+			}
+		}
+		if next == "" {
+			// No single path covers the component: append the rest
+			for _, m := range scc {
+				if !seen[m] {
+					ordered = append(ordered, m)
+				}
+			}
+			break
+		}
+		seen[next] = true
+		ordered = append(ordered, next)
+	}
+	return ordered
+}
+
+// importGraphTool returns the import graph of the project packages.
+func importGraphTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("import_graph",
+		mcp.WithDescription("Return the import graph of the Go packages of the project: each package with the project packages it imports and those importing it, and the import cycles. With package, only that package and everything depending on it, directly or not. Use it for architecture questions like \"what depends on internal/storage\"."),
+		mcp.WithString("package", mcp.Description("Directory relative to project root (e.g. internal/storage) or import path: only return it and its dependents")),
+		mcp.WithBoolean("tests", mcp.Description("Include the imports of the test files")),
+		mcp.WithOutputSchema[ImportGraph](),
+		readOnlyAnnotations("Package import graph"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		pkg := strings.TrimPrefix(request.GetString("package", ""), "./")
+		graph, err := PackageImports(ctx, rootPath, pkg, request.GetBool("tests", false))
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("import graph failed: %v", err)), nil
+		}
+		return jsonToolResult(graph, graph), nil
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// InterfaceMatrix is the result of the interface_matrix tool.
+type InterfaceMatrix struct {
+	Package    string          `json:"package"` // Import path
+	Interfaces []InterfaceImpl `json:"interfaces"`
+}
+
+// InterfaceImpl is an interface and the project types implementing it.
+type InterfaceImpl struct {
+	Name            string           `json:"name"`
+	Path            string           `json:"path,omitempty"` // Relative to the project root when inside it
+	Line            int              `json:"line,omitempty"`
+	Methods         []string         `json:"methods"`
+	Implementations []Implementation `json:"implementations"`
+}
+
+// Implementation is a concrete type implementing an interface.
+type Implementation struct {
+	Type    string `json:"type"`    // Qualified by its package name, *T when only the pointer implements it
+	Package string `json:"package"` // Import path
+	Path    string `json:"path"`    // Relative to the project root
+	Line    int    `json:"line"`
+}
+
+// InterfaceImplementations returns the interfaces of pkg (a directory
+// relative to root or an import path the module imports, directly or not),
+// name only when set, each with the named types of the module implementing
+// it, by value or by pointer. Empty interfaces and type constraints are left
+// out, as are generic types.
+func InterfaceImplementations(ctx context.Context, root string, pkg string, name string) (*InterfaceMatrix, error) {
+	fset, pkgs, err := LoadTypedPackages(ctx, root, false)
+	if err != nil {
+		return nil, err
+	}
+	target := findTypesPackage(root, pkgs, pkg)
+	if target == nil {
+		return nil, fmt.Errorf("package %s not found in the module or its imports", pkg)
+	}
+
+	position := func(pos token.Pos) (string, int) {
+		p := fset.Position(pos)
+		path := p.Filename
+		if rel, err := filepath.Rel(root, path); err == nil && filepath.IsLocal(rel) {
+			path = filepath.ToSlash(rel)
+		}
+		return path, p.Line
+	}
+
+	// The concrete named types of the module
+	var concrete []*types.TypeName
+	for _, p := range pkgs {
+		if p.Types == nil {
+			continue
+		}
+		scope := p.Types.Scope()
+		for _, n := range scope.Names() {
+			tn, ok := scope.Lookup(n).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			named, ok := tn.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 || types.IsInterface(named) {
+				continue
+			}
+			concrete = append(concrete, tn)
+		}
+	}
+
+	inProject := slices.ContainsFunc(pkgs, func(p *TypedPackage) bool { return p.Types == target })
+	matrix := &InterfaceMatrix{Package: target.Path(), Interfaces: []InterfaceImpl{}}
+	scope := target.Scope()
+	for _, n := range scope.Names() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		tn, ok := scope.Lookup(n).(*types.TypeName)
+		// The unexported interfaces of dependencies are theirs only
+		if !ok || name != "" && n != name || !inProject && !tn.Exported() {
+			continue
+		}
+		iface, ok := tn.Type().Underlying().(*types.Interface)
+		if !ok || iface.NumMethods() == 0 || !iface.IsMethodSet() {
+			continue
+		}
+		impl := InterfaceImpl{Name: n, Methods: []string{}, Implementations: []Implementation{}}
+		impl.Path, impl.Line = position(tn.Pos())
+		for i := range iface.NumMethods() {
+			m := iface.Method(i)
+			impl.Methods = append(impl.Methods, m.Name()+strings.TrimPrefix(types.TypeString(m.Type(), types.RelativeTo(target)), "func"))
+		}
+		for _, c := range concrete {
+			typ := c.Name()
+			switch {
+			case types.Implements(c.Type(), iface):
+			case types.Implements(types.NewPointer(c.Type()), iface):
+				typ = "*" + typ
+			default:
+				continue
+			}
+			path, line := position(c.Pos())
+			impl.Implementations = append(impl.Implementations, Implementation{
+				Type:    strings.Replace(typ, c.Name(), c.Pkg().Name()+"."+c.Name(), 1),
+				Package: c.Pkg().Path(),
+				Path:    path,
+				Line:    line,
+			})
+		}
+		sort.Slice(impl.Implementations, func(i, j int) bool {
+			a, b := impl.Implementations[i], impl.Implementations[j]
+			if a.Package != b.Package {
+				return a.Package < b.Package
+			}
+			return strings.TrimPrefix(a.Type, "*") < strings.TrimPrefix(b.Type, "*")
+		})
+		matrix.Interfaces = append(matrix.Interfaces, impl)
+	}
+	if name != "" && len(matrix.Interfaces) == 0 {
+		return nil, fmt.Errorf("no interface %s with methods in package %s", name, target.Path())
+	}
+	return matrix, nil
+}
+
+// findTypesPackage returns the package pkg: a package of pkgs by directory
+// relative to root or import path, or a package they import.
+func findTypesPackage(root string, pkgs []*TypedPackage, pkg string) *types.Package {
+	dir := filepath.Join(root, filepath.FromSlash(pkg))
+	for _, p := range pkgs {
+		if p.Types != nil && (p.ImportPath == pkg || p.Dir == dir) {
+			return p.Types
+		}
+	}
+	seen := make(map[*types.Package]bool)
+	var find func(ps []*types.Package) *types.Package
+	find = func(ps []*types.Package) *types.Package {
+		for _, p := range ps {
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+			if p.Path() == pkg {
+				return p
+			}
+			if found := find(p.Imports()); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	for _, p := range pkgs {
+		if p.Types != nil {
+			if found := find(p.Types.Imports()); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// interfaceMatrixTool returns the implementations of the interfaces of a
+// package.
+func interfaceMatrixTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("interface_matrix",
+		mcp.WithDescription("List the interfaces of a Go package with, for each, the concrete types of the module implementing it (by value, or by pointer as *T), type-checking the whole module. One-shot answer to \"what implements Store\". The package may be one of the project or one it imports, e.g. io."),
+		mcp.WithString("package", mcp.Required(), mcp.Description("Directory relative to project root (e.g. internal/storage, . for the root package) or import path")),
+		mcp.WithString("interface", mcp.Description("Only this interface of the package, e.g. Store")),
+		mcp.WithOutputSchema[InterfaceMatrix](),
+		readOnlyAnnotations("Interface implementations"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		pkg, err := request.RequireString("package")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		rootPath := projectRoot(ctx, rootPath)
+		matrix, err := InterfaceImplementations(ctx, rootPath, strings.TrimPrefix(pkg, "./"), request.GetString("interface", ""))
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("interface matrix failed: %v", err)), nil
+		}
+		return jsonToolResult(matrix, matrix), nil
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const javaModifiers = `(?:(?:public|protected|private|abstract|final|static|sealed|non-sealed|strictfp|synchronized|native|default)\s+|@\w+(?:\([^)]*\))?\s+)*`
+
+var (
+	javaTypeRe   = regexp.MustCompile(`^\s*` + javaModifiers + `(class|interface|enum|record|@interface)\s+(\w+)`)
+	javaMethodRe = regexp.MustCompile(`^\s*` + javaModifiers + `(?:<[^>]*>\s*)?([\w.$]+(?:<.*>)?(?:\[\])*)\s+(\w+)\s*\(`)
+	javaCtorRe   = regexp.MustCompile(`^\s*(?:public|protected|private)\s+(\w+)\s*\(`)
+)
+
+// javaNotTypes are words that can precede "name(" without being a return type.
+var javaNotTypes = map[string]bool{
+	"return": true, "new": true, "else": true, "throw": true, "case": true, "yield": true,
+}
+
+// ExtractJavaSymbols returns the classes, interfaces, enums, records and
+// methods declared in a Java file. Like ExtractJSSymbols it works on lines
+// with comments and string contents blanked out.
+func ExtractJavaSymbols(ctx context.Context, absPath string) []Symbol {
+	if ctx.Err() != nil {
+		return nil
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil
+	}
+
+	var symbols []Symbol
+	for _, line := range strings.Split(stripCStyleComments(string(content)), "\n") {
+		if m := javaTypeRe.FindStringSubmatch(line); m != nil {
+			kind := m[1]
+			if kind == "@interface" {
+				kind = "annotation"
+			}
+			symbols = append(symbols, Symbol{Kind: kind, Name: m[2]})
+		} else if m := javaMethodRe.FindStringSubmatch(line); m != nil && !javaNotTypes[m[1]] {
+			symbols = append(symbols, Symbol{Kind: "method", Name: m[2]})
+		} else if m := javaCtorRe.FindStringSubmatch(line); m != nil {
+			symbols = append(symbols, Symbol{Kind: "method", Name: m[1]})
+		}
+	}
+	return symbols
+}
+
+// javaLanguage describes jdtls (Eclipse JDT language server), used for
+// Maven and Gradle projects when it is installed.
+func javaLanguage() Language {
+	return Language{
+		Name:    "java",
+		Command: []string{"jdtls"},
+		Markers: []string{"pom.xml", "build.gradle", "build.gradle.kts", "settings.gradle", "settings.gradle.kts"},
+		Classify: func(root string, path string) (bool, bool) {
+			// Library classes are returned as jdt:// URIs, not readable files.
+			if strings.HasPrefix(path, "jdt://") {
+				return true, false
+			}
+			return false, strings.Contains(path, "/.m2/repository/") || strings.Contains(path, "/.gradle/caches/")
+		},
+	}
+}
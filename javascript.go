@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// jsDeclPatterns match top-level TypeScript/JavaScript declarations once
+// comments and string contents have been blanked out.
+// The last submatch of each pattern is the declared name.
+var jsDeclPatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"func", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`)},
+	{"class", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][\w$]*)`)},
+	{"type", regexp.MustCompile(`^\s*(?:export\s+)?(?:declare\s+)?(?:interface|type|enum)\s+([A-Za-z_$][\w$]*)`)},
+	// Arrow functions and function expressions bound to a name
+	{"func", regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|[A-Za-z_$][\w$]*\s*=>)`)},
+	{"const", regexp.MustCompile(`^\s*export\s+(?:const|let|var)\s+([A-Za-z_$][\w$]*)`)},
+}
+
+// ExtractJSSymbols returns the functions, classes, TypeScript types and
+// exported constants declared in a .ts/.tsx/.js/.jsx file.
+// It relies on a lightweight lexer rather than a full parser: comments and
+// string literals are blanked out, then each line is matched against
+// jsDeclPatterns.
+func ExtractJSSymbols(ctx context.Context, absPath string) []Symbol {
+	if ctx.Err() != nil {
+		return nil
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil
+	}
+
+	var symbols []Symbol
+	for _, line := range strings.Split(stripCStyleComments(string(content)), "\n") {
+		for _, p := range jsDeclPatterns {
+			if m := p.re.FindStringSubmatch(line); m != nil {
+				symbols = append(symbols, Symbol{Kind: p.kind, Name: m[len(m)-1]})
+				// One declaration per line, first pattern wins
+				break
+			}
+		}
+	}
+	return symbols
+}
+
+// stripCStyleComments replaces // and /* */ comments and the contents of
+// string and template literals with spaces, keeping newlines so line
+// structure is preserved. It is shared by the C-like languages (JS, Java).
+func stripCStyleComments(src string) string {
+	out := []byte(src)
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			out[i], out[i+1] = ' ', ' '
+			for i += 2; i < len(out) && !(out[i] == '*' && i+1 < len(out) && out[i+1] == '/'); i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			if i+1 < len(out) {
+				out[i], out[i+1] = ' ', ' '
+				i++
+			}
+		case c == '"' || c == '\'' || c == '`':
+			// Keep the quotes, blank the contents
+			for i++; i < len(out) && out[i] != c; i++ {
+				if out[i] == '\n' {
+					if c != '`' {
+						// Unterminated single-line string
+						break
+					}
+					continue
+				}
+				if out[i] == '\\' && i+1 < len(out) && out[i+1] != '\n' {
+					out[i] = ' '
+					i++
+				}
+				out[i] = ' '
+			}
+		}
+	}
+	return string(out)
+}
+
+// NodeModules enables searching and reading the node_modules dependencies
+// of TS/JS projects. Set via the --node-modules flag.
+var NodeModules bool
+
+// typescriptLanguage describes typescript-language-server, used for TS/JS
+// projects.
+func typescriptLanguage() Language {
+	return Language{
+		Name:    "typescript",
+		Command: []string{"typescript-language-server", "--stdio"},
+		Markers: []string{"tsconfig.json", "jsconfig.json", "package.json"},
+		Classify: func(root string, path string) (bool, bool) {
+			// Filter out the built-in lib.*.d.ts shipped with TypeScript.
+			if strings.Contains(path, "/node_modules/typescript/lib/") {
+				return true, false
+			}
+			if strings.Contains(path, "/node_modules/") {
+				return !NodeModules, true
+			}
+			return classifyOutside(root, path)
+		},
+		ReadPaths: func(root string) []string {
+			if !NodeModules {
+				return nil
+			}
+			// Node resolves packages from every parent node_modules
+			// (e.g. hoisted by a monorepo workspace).
+			var paths []string
+			for dir := root; ; dir = filepath.Dir(dir) {
+				nm := filepath.Join(dir, "node_modules")
+				if info, err := os.Stat(nm); err == nil && info.IsDir() {
+					paths = append(paths, nm)
+				}
+				if filepath.Dir(dir) == dir {
+					break
+				}
+			}
+			return paths
+		},
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Language describes a language server codemcp can spawn for a project.
+type Language struct {
+	Name        string
+	Command     []string       // Server command line, e.g. ["gopls"]
+	Extensions  []string       // Source file extensions, used for detection
+	Markers     []string       // Root files marking a project, e.g. "go.mod"
+	InitOptions map[string]any // Sent as initializationOptions
+	VersionArgs []string       // Arguments printing the server version, e.g. ["version"]
+	Env         []string       // Extra "KEY=value" environment, e.g. "GOFLAGS=-tags=integration"
+
+	// Classify tells whether a symbol location should be skipped as noise,
+	// and whether it belongs to a dependency. Defaults to classifyOutside.
+	Classify func(root string, path string) (skip bool, isDep bool)
+	// Stdlib tells whether a location skipped by Classify belongs to the
+	// standard library, kept when a search opts in.
+	Stdlib func(path string) bool
+	// Detect optionally recognizes projects the Markers and Extensions miss.
+	Detect func(root string) bool
+	// RootEnv returns extra environment depending on the project, added
+	// after Env (e.g. GO111MODULE=off for GOPATH projects).
+	RootEnv func(root string) []string
+	// WorkspaceFolders returns extra absolute directories sent as
+	// workspaceFolders along with the root (e.g. go.work modules).
+	WorkspaceFolders func(root string) []string
+	// ReadPaths returns the extra directories read_file may access when the
+	// language is detected (e.g. the Go module cache).
+	ReadPaths func(root string) []string
+}
+
+// DefaultLanguages are the language servers known without any configuration.
+// The language_servers section of the config file overrides or extends them.
+var DefaultLanguages = []Language{
+	goLanguage(),
+	rustLanguage(),
+	pythonLanguage(),
+	clangLanguage(),
+	typescriptLanguage(),
+	javaLanguage(),
+	zigLanguage(),
+}
+
+// LSP is the language server manager of the current project,
+// created by main before searching.
+var LSP *LSPManager
+
+// LSPManager spawns the language servers of the languages detected in a
+// project, lazily on first use, and fans out queries to them.
+type LSPManager struct {
+	root      string
+	languages []Language
+	disabled  map[string]bool
+
+	detectOnce sync.Once
+	detected   []*Language
+
+	mu      sync.Mutex
+	servers map[string]*lspServer
+}
+
+// errServerNotFound is the start error of a server whose command is not
+// installed.
+var errServerNotFound = errors.New("not found")
+
+// lspServer tracks the start of one language server.
+type lspServer struct {
+	once   sync.Once
+	client *LSPClient
+	err    error // Why the server is not running, once start gave up
+}
+
+// NewLSPManager returns a manager for the project at root.
+// Languages named in disabled are never started.
+func NewLSPManager(root string, languages []Language, disabled map[string]bool) *LSPManager {
+	return &LSPManager{
+		root:      root,
+		languages: languages,
+		disabled:  disabled,
+		servers:   make(map[string]*lspServer),
+	}
+}
+
+// Detected returns the enabled languages used by the project: those with a
+// marker file at the root or accepted by their Detect hook, or with at least
+// one source file otherwise.
+// Detection runs once.
+func (m *LSPManager) Detected(ctx context.Context) []*Language {
+	m.detectOnce.Do(func() {
+		var files []string
+		filesLoaded := false
+		for i := range m.languages {
+			lang := &m.languages[i]
+			if m.disabled[lang.Name] || len(lang.Command) == 0 {
+				continue
+			}
+			found := lang.Detect != nil && lang.Detect(m.root)
+			for _, marker := range lang.Markers {
+				if _, err := os.Stat(filepath.Join(m.root, marker)); err == nil {
+					found = true
+					break
+				}
+			}
+			if !found && len(lang.Extensions) > 0 {
+				if !filesLoaded {
+					files, _ = CollectFiles(ctx, m.root)
+					filesLoaded = true
+				}
+				found = hasExtension(files, lang.Extensions)
+			}
+			if found {
+				m.detected = append(m.detected, lang)
+			}
+		}
+	})
+	return m.detected
+}
+
+// hasExtension reports whether one of files ends with one of exts.
+func hasExtension(files []string, exts []string) bool {
+	for _, f := range files {
+		ext := filepath.Ext(f)
+		for _, e := range exts {
+			if ext == e {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Clients returns the language servers of the detected languages, starting
+// them concurrently if they are not running yet. Servers that fail to start
+// are skipped (and not retried).
+func (m *LSPManager) Clients(ctx context.Context) []*LSPClient {
+	if m == nil {
+		return nil
+	}
+	langs := m.Detected(ctx)
+	clients := make([]*LSPClient, len(langs))
+
+	var wg sync.WaitGroup
+	for i, lang := range langs {
+		wg.Add(1)
+		go func(i int, lang *Language) {
+			defer wg.Done()
+			clients[i] = m.start(lang)
+		}(i, lang)
+	}
+	wg.Wait()
+
+	running := clients[:0]
+	for _, c := range clients {
+		if c != nil {
+			running = append(running, c)
+		}
+	}
+	return running
+}
+
+// start spawns the server of lang once and returns its client.
+func (m *LSPManager) start(lang *Language) *LSPClient {
+	m.mu.Lock()
+	srv, ok := m.servers[lang.Name]
+	if !ok {
+		srv = &lspServer{}
+		m.servers[lang.Name] = srv
+	}
+	m.mu.Unlock()
+
+	srv.once.Do(func() {
+		// Check if binary exists in PATH
+		if _, err := exec.LookPath(lang.Command[0]); err != nil {
+			slog.Warn("language server not found, skipping dependency search", "command", lang.Command[0], "language", lang.Name)
+			m.mu.Lock()
+			srv.err = fmt.Errorf("%s %w", lang.Command[0], errServerNotFound)
+			m.mu.Unlock()
+			return
+		}
+
+		classify := lang.Classify
+		if classify == nil {
+			classify = classifyOutside
+		}
+		root := m.root
+		var initOptions any
+		if lang.InitOptions != nil {
+			initOptions = lang.InitOptions
+		}
+
+		env := lang.Env
+		if lang.RootEnv != nil {
+			env = append(slices.Clone(env), lang.RootEnv(root)...)
+		}
+		var folders []string
+		if lang.WorkspaceFolders != nil {
+			folders = lang.WorkspaceFolders(root)
+		}
+
+		client, err := StartLSPClient(filepath.Base(lang.Command[0]), lang.Command, env, root, folders, initOptions, func(path string) (bool, bool) {
+			return classify(root, path)
+		})
+		if err != nil {
+			slog.Error("language server init failed", "command", lang.Command[0], "err", err)
+			m.mu.Lock()
+			srv.err = fmt.Errorf("init failed: %w", err)
+			m.mu.Unlock()
+			return
+		}
+		client.stdlib = lang.Stdlib
+		if len(lang.VersionArgs) > 0 {
+			if v := serverVersion(lang.Command[0], lang.VersionArgs); v != "" {
+				client.mu.Lock()
+				client.version = v
+				client.mu.Unlock()
+			}
+		}
+		m.mu.Lock()
+		srv.client = client
+		m.mu.Unlock()
+	})
+	return srv.client
+}
+
+// serverVersion runs command with args and extracts the version from the
+// first line of its output, e.g. "v0.16.1" from
+// "golang.org/x/tools/gopls v0.16.1".
+func serverVersion(command string, args []string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, command, args...).Output()
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	for _, field := range strings.Fields(line) {
+		v := strings.TrimPrefix(field, "v")
+		if v != "" && v[0] >= '0' && v[0] <= '9' {
+			return field
+		}
+	}
+	return ""
+}
+
+// Client returns the running server of the language called name, or nil.
+func (m *LSPManager) Client(name string) *LSPClient {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if srv, ok := m.servers[name]; ok {
+		return srv.client
+	}
+	return nil
+}
+
+// Running returns the names of the running servers, with their version
+// when known, e.g. "gopls v0.16.1".
+func (m *LSPManager) Running() []string {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for _, lang := range m.languages {
+		if srv, ok := m.servers[lang.Name]; ok && srv.client != nil {
+			name := srv.client.Name()
+			if v := srv.client.Version(); v != "" {
+				name += " " + v
+			}
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Failed returns why the installed servers that failed to start did,
+// prefixed by their language, e.g. "go: init failed: EOF".
+func (m *LSPManager) Failed() []string {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for _, lang := range m.languages {
+		if srv, ok := m.servers[lang.Name]; ok && srv.err != nil && !errors.Is(srv.err, errServerNotFound) {
+			names = append(names, lang.Name+": "+srv.err.Error())
+		}
+	}
+	return names
+}
+
+// languageIDs are the LSP language identifiers that differ from the
+// language name, by extension.
+var languageIDs = map[string]string{
+	".cc": "cpp", ".cpp": "cpp", ".cxx": "cpp", ".hpp": "cpp", ".hh": "cpp",
+	".js": "javascript", ".jsx": "javascriptreact", ".tsx": "typescriptreact",
+}
+
+// FileChanged forwards a change of absPath (edited, created or deleted) to
+// the running servers handling its extension. Servers are not started for it.
+func (m *LSPManager) FileChanged(absPath string) {
+	if m == nil {
+		return
+	}
+	ext := filepath.Ext(absPath)
+	for _, lang := range m.languages {
+		if !slices.Contains(lang.Extensions, ext) {
+			continue
+		}
+		client := m.Client(lang.Name)
+		if client == nil {
+			continue
+		}
+		languageID := lang.Name
+		if id, ok := languageIDs[ext]; ok {
+			languageID = id
+		}
+		_ = client.SyncFile(absPath, languageID)
+	}
+}
+
+// ReadPaths returns the extra directories read_file may access for the
+// detected languages.
+func (m *LSPManager) ReadPaths(ctx context.Context) []string {
+	if m == nil {
+		return nil
+	}
+	var paths []string
+	for _, lang := range m.Detected(ctx) {
+		if lang.ReadPaths != nil {
+			paths = append(paths, lang.ReadPaths(m.root)...)
+		}
+	}
+	return paths
+}
+
+// Shutdown kills every running language server.
+func (m *LSPManager) Shutdown() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, srv := range m.servers {
+		if srv.client != nil {
+			srv.client.Shutdown()
+		}
+	}
+}
+
+// classifyOutside is the default Classify: nothing is noise, and any file
+// outside the project root is a dependency.
+func classifyOutside(root string, path string) (bool, bool) {
+	return false, !strings.HasPrefix(path, root+string(os.PathSeparator))
+}
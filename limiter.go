@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// MaxConcurrentCalls bounds the requests in flight to each language server.
+// Set via the --lsp-concurrency flag or lsp_concurrency in the config.
+var MaxConcurrentCalls = 4
+
+// methodPriority orders the requests waiting for a slot: interactive
+// lookups go before bulk queries. Unlisted methods have priority 0.
+var methodPriority = map[string]int{
+	"initialize":              2,
+	"workspace/symbol":        1,
+	"textDocument/definition": 1,
+	"textDocument/hover":      1,
+}
+
+// callLimiter is a semaphore whose waiters are served by decreasing
+// priority, then in arrival order.
+type callLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	inUse   int
+	waiters []*callWaiter
+}
+
+type callWaiter struct {
+	priority int
+	ready    chan struct{}
+}
+
+func newCallLimiter(limit int) *callLimiter {
+	return &callLimiter{limit: max(1, limit)}
+}
+
+// acquire blocks until a slot is free or ctx is done.
+func (l *callLimiter) acquire(ctx context.Context, priority int) error {
+	l.mu.Lock()
+	if l.inUse < l.limit {
+		l.inUse++
+		l.mu.Unlock()
+		return nil
+	}
+	w := &callWaiter{priority: priority, ready: make(chan struct{})}
+	// After the waiters of the same or higher priority
+	i := sort.Search(len(l.waiters), func(i int) bool { return l.waiters[i].priority < priority })
+	l.waiters = slices.Insert(l.waiters, i, w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		if i := slices.Index(l.waiters, w); i >= 0 {
+			l.waiters = slices.Delete(l.waiters, i, i+1)
+			l.mu.Unlock()
+			return ctx.Err()
+		}
+		l.mu.Unlock()
+		// The slot was handed over meanwhile, pass it on
+		l.release()
+		return ctx.Err()
+	}
+}
+
+// release frees a slot, handing it over to the first waiter if any.
+func (l *callLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.waiters) > 0 {
+		w := l.waiters[0]
+		l.waiters = l.waiters[1:]
+		close(w.ready)
+		return
+	}
+	l.inUse--
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// linguistAttributes are the .gitattributes GitHub reads to tell generated
+// and vendored files from the code of a repository, with their reasons.
+var linguistAttributes = map[string]string{
+	"linguist-generated": "generated",
+	"linguist-vendored":  "vendored",
+}
+
+// linguistPenalty divides the score of generated and vendored files.
+const linguistPenalty = 10
+
+// LinguistFiles returns the files of root, among files, marked
+// linguist-generated or linguist-vendored by the .gitattributes files, and
+// the reason of each ("generated" or "vendored"). git resolves the
+// attributes, so it returns nil outside git repositories.
+func LinguistFiles(ctx context.Context, root string, files []string) map[string]string {
+	if len(files) == 0 {
+		return nil
+	}
+	if !inGitWorkTree(root) {
+		return nil
+	}
+	var stdin bytes.Buffer
+	for _, f := range files {
+		stdin.WriteString(filepath.ToSlash(f) + "\x00")
+	}
+	cmd := exec.CommandContext(ctx, "git", "check-attr", "-z", "--stdin", "linguist-generated", "linguist-vendored")
+	cmd.Dir = root
+	cmd.Stdin = &stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	marked := make(map[string]string)
+	// <path> NUL <attribute> NUL <value> NUL, per path and attribute
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		path, attr, value := fields[i], fields[i+1], fields[i+2]
+		// "set" for a bare attribute, "true" for attribute=true
+		if value == "set" || value == "true" {
+			if _, ok := marked[path]; !ok {
+				marked[path] = linguistAttributes[attr]
+			}
+		}
+	}
+	return marked
+}
+
+// PenalizeLinguist divides the score of the results of LocalSearch in root
+// marked linguist-generated or linguist-vendored by linguistPenalty, with a
+// "generated" or "vendored" reason, as GitHub leaves them out of its
+// language statistics and diffs.
+func PenalizeLinguist(ctx context.Context, root string, results []FileScore) []FileScore {
+	paths := make([]string, len(results))
+	for i, r := range results {
+		paths[i] = r.Path
+	}
+	marked := LinguistFiles(ctx, root, paths)
+	for i, r := range results {
+		if reason, ok := marked[filepath.ToSlash(r.Path)]; ok && r.Score > 0 {
+			results[i].Score = max(1, r.Score/linguistPenalty)
+			results[i].Reasons = append(results[i].Reasons, reason)
+		}
+	}
+	return results
+}
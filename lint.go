@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Linters of the lint tool.
+const (
+	LinterAuto        = "auto"          // golangci-lint with a config in the project, staticcheck otherwise
+	LinterStaticcheck = "staticcheck"   // honnef.co/go/tools/cmd/staticcheck
+	LinterGolangci    = "golangci-lint" // github.com/golangci/golangci-lint
+)
+
+// Linter selects the linter the lint tool runs: LinterAuto,
+// LinterStaticcheck or LinterGolangci.
+// Set via linter in the config, the --linter flag or CODEMCP_LINTER.
+var Linter = LinterAuto
+
+// golangciConfigs are the config files of golangci-lint, in the project root.
+var golangciConfigs = []string{".golangci.yml", ".golangci.yaml", ".golangci.toml", ".golangci.json"}
+
+// golangciMajor extracts the major version from golangci-lint --version.
+var golangciMajor = regexp.MustCompile(`version v?(\d+)\.`)
+
+// LintReport is the result of the lint tool.
+type LintReport struct {
+	Linter   string    `json:"linter"`
+	Findings []Finding `json:"findings"`
+}
+
+// selectLinter returns the installed linter of root selected by Linter.
+func selectLinter(root string) (string, error) {
+	installed := func(name string) bool {
+		_, err := exec.LookPath(name)
+		return err == nil
+	}
+	if Linter != LinterAuto {
+		if !installed(Linter) {
+			return "", fmt.Errorf("%s is not installed", Linter)
+		}
+		return Linter, nil
+	}
+	if installed(LinterGolangci) {
+		for _, name := range golangciConfigs {
+			if _, err := os.Stat(filepath.Join(root, name)); err == nil {
+				return LinterGolangci, nil
+			}
+		}
+	}
+	for _, name := range []string{LinterStaticcheck, LinterGolangci} {
+		if installed(name) {
+			return name, nil
+		}
+	}
+	return "", errors.New("no linter found: install staticcheck or golangci-lint")
+}
+
+// Lint runs the linter of root selected by Linter on the package patterns
+// and returns its findings sorted by position.
+func Lint(ctx context.Context, root string, patterns []string) (*LintReport, error) {
+	linter, err := selectLinter(root)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	var args []string
+	switch linter {
+	case LinterStaticcheck:
+		args = []string{"-f", "json"}
+	case LinterGolangci:
+		out, err := exec.CommandContext(ctx, linter, "--version").Output()
+		if err != nil {
+			return nil, err
+		}
+		// The JSON output flag changed in golangci-lint v2
+		if m := golangciMajor.FindSubmatch(out); m != nil && string(m[1]) == "1" {
+			args = []string{"run", "--out-format=json", "--issues-exit-code=0"}
+		} else {
+			args = []string{"run", "--output.json.path=stdout", "--show-stats=false", "--issues-exit-code=0"}
+		}
+	}
+	cmd := exec.CommandContext(ctx, linter, append(args, patterns...)...)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), Build.Env()...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	runErr := cmd.Run()
+
+	report := &LintReport{Linter: linter, Findings: []Finding{}}
+	if linter == LinterStaticcheck {
+		report.Findings = staticcheckFindings(root, stdout.Bytes())
+	} else {
+		report.Findings = golangciFindings(root, stdout.Bytes())
+	}
+	// staticcheck exits with 1 on findings
+	if runErr != nil && len(report.Findings) == 0 {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", linter, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", linter, runErr)
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return report, nil
+}
+
+// staticcheckFindings parses the output of staticcheck -f json, an object
+// per line.
+func staticcheckFindings(root string, out []byte) []Finding {
+	var findings []Finding
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var issue struct {
+			Code     string `json:"code"`
+			Message  string `json:"message"`
+			Location struct {
+				File   string `json:"file"`
+				Line   int    `json:"line"`
+				Column int    `json:"column"`
+			} `json:"location"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &issue); err != nil {
+			continue
+		}
+		findings = append(findings, Finding{
+			Path:     projectRelative(root, issue.Location.File),
+			Line:     issue.Location.Line,
+			Column:   issue.Location.Column,
+			Analyzer: issue.Code,
+			Message:  issue.Message,
+		})
+	}
+	return findings
+}
+
+// golangciFindings parses the JSON output of golangci-lint run.
+func golangciFindings(root string, out []byte) []Finding {
+	var result struct {
+		Issues []struct {
+			FromLinter string
+			Text       string
+			Pos        struct {
+				Filename string
+				Line     int
+				Column   int
+			}
+		}
+	}
+	// v2 may follow the JSON with a text summary
+	if err := json.NewDecoder(bytes.NewReader(out)).Decode(&result); err != nil {
+		return nil
+	}
+	var findings []Finding
+	for _, issue := range result.Issues {
+		findings = append(findings, Finding{
+			Path:     projectRelative(root, issue.Pos.Filename),
+			Line:     issue.Pos.Line,
+			Column:   issue.Pos.Column,
+			Analyzer: issue.FromLinter,
+			Message:  issue.Text,
+		})
+	}
+	return findings
+}
+
+// projectRelative returns file, absolute or relative to root, relative to
+// root and slash separated when inside it.
+func projectRelative(root string, file string) string {
+	if !filepath.IsAbs(file) {
+		return filepath.ToSlash(file)
+	}
+	if rel, err := filepath.Rel(root, file); err == nil && filepath.IsLocal(rel) {
+		return filepath.ToSlash(rel)
+	}
+	return file
+}
+
+// changedPackages returns the patterns of the packages of root holding Go
+// files changed on the current branch (see BranchChanges), and these files.
+func changedPackages(ctx context.Context, root string) ([]string, map[string]bool) {
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for f := range BranchChanges(ctx, root) {
+		f = filepath.ToSlash(f)
+		if path.Ext(f) != ".go" {
+			continue
+		}
+		// Deleted files leave nothing to lint
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(f))); err != nil {
+			continue
+		}
+		files[f] = true
+		dirs[path.Dir(f)] = true
+	}
+	var patterns []string
+	for d := range dirs {
+		patterns = append(patterns, "./"+d)
+	}
+	sort.Strings(patterns)
+	return patterns, files
+}
+
+// lintTool runs staticcheck or golangci-lint on the project.
+func lintTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("lint",
+		mcp.WithDescription("Run the Go linter of the project (golangci-lint with a config in the project, staticcheck otherwise, when installed) on a package, the whole module or the files changed on the current branch, and return its findings (path, line, check, message). Use it after editing Go code to verify the fix."),
+		mcp.WithString("package", mcp.Description("Directory relative to project root (e.g. internal/auth, internal/... for the packages under it), import path or pattern; the whole module by default")),
+		mcp.WithBoolean("changed", mcp.Description("Lint the packages of the Go files changed on the current branch (committed or not, untracked included) instead, and only report findings in these files")),
+		mcp.WithOutputSchema[LintReport](),
+		readOnlyAnnotations("Lint"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		patterns := []string{packagePattern(rootPath, request.GetString("package", ""))}
+		var changed map[string]bool
+		if request.GetBool("changed", false) {
+			if patterns, changed = changedPackages(ctx, rootPath); len(patterns) == 0 {
+				return mcp.NewToolResultText("No changed Go files"), nil
+			}
+		}
+
+		report, err := Lint(ctx, rootPath, patterns)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("lint failed: %v", err)), nil
+		}
+		if changed != nil {
+			kept := report.Findings[:0]
+			for _, f := range report.Findings {
+				if changed[f.Path] {
+					kept = append(kept, f)
+				}
+			}
+			report.Findings = kept
+		}
+		return jsonToolResult(report, report), nil
+	}
+}
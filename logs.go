@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LogDir holds the language server logs, relative to the project root.
+const LogDir = ".codemcp/logs"
+
+const (
+	maxLogSize    = 5 << 20 // Rotate the log past 5 MiB
+	maxLogBackups = 3       // Keep name.log.1 ... name.log.3
+	logTailLines  = 20      // Lines kept in memory for error messages
+)
+
+// LogLevel is the minimum level of the log messages.
+// Set via the --log-level flag, log_level in the config or CODEMCP_LOG_LEVEL.
+var LogLevel = new(slog.LevelVar)
+
+// LogFile receives the log messages as JSON lines instead of stderr.
+// Set via the --log-file flag, log_file in the config or CODEMCP_LOG_FILE.
+var LogFile string
+
+// newStderrLogger returns the default logger: text on stderr, without the
+// time that matters little interactively. Stdout is never used, it carries
+// the results, or the MCP stream in server mode.
+func newStderrLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: LogLevel,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// SetupLogging installs the default logger once the settings are known:
+// JSON lines appended to LogFile if set, stderr otherwise. The returned
+// function closes the log file.
+func SetupLogging() func() {
+	slog.SetDefault(newStderrLogger())
+	if LogFile == "" {
+		return func() {}
+	}
+	if err := os.MkdirAll(filepath.Dir(LogFile), 0o755); err != nil {
+		slog.Warn("cannot create the log file directory, logging to stderr", "err", err)
+		return func() {}
+	}
+	f, err := os.OpenFile(LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		slog.Warn("cannot open the log file, logging to stderr", "err", err)
+		return func() {}
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(f, &slog.HandlerOptions{Level: LogLevel})))
+	return func() { _ = f.Close() }
+}
+
+// RotatingLog is an io.Writer appending to a log file, rotated once it grows
+// past maxLogSize. It remembers the last lines written, for error messages.
+// If the file cannot be opened only the tail is kept.
+type RotatingLog struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	size    int64
+	tail    []string
+	partial []byte // Last line, not terminated yet
+}
+
+// NewRotatingLog opens (or creates) the log at path, creating its directory.
+func NewRotatingLog(path string) *RotatingLog {
+	l := &RotatingLog{path: path}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+		l.open()
+	}
+	return l
+}
+
+// open opens the log file for appending.
+func (l *RotatingLog) open() {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return
+	}
+	l.file = f
+	l.size = 0
+	if info, err := f.Stat(); err == nil {
+		l.size = info.Size()
+	}
+}
+
+// rotate shifts name.log to name.log.1, name.log.1 to name.log.2, etc.
+func (l *RotatingLog) rotate() {
+	_ = l.file.Close()
+	l.file = nil
+	for i := maxLogBackups - 1; i > 0; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	_ = os.Rename(l.path, l.path+".1")
+	l.open()
+}
+
+// Write implements io.Writer.
+func (l *RotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.partial = append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i < 0 {
+			break
+		}
+		l.tail = append(l.tail, string(l.partial[:i]))
+		l.partial = l.partial[i+1:]
+	}
+	if len(l.tail) > logTailLines {
+		l.tail = l.tail[len(l.tail)-logTailLines:]
+	}
+
+	if l.file != nil {
+		if l.size+int64(len(p)) > maxLogSize {
+			l.rotate()
+		}
+		if l.file != nil {
+			n, _ := l.file.Write(p)
+			l.size += int64(n)
+		}
+	}
+	// Logging never fails the writer (the server's stderr)
+	return len(p), nil
+}
+
+// Tail returns the last lines written.
+func (l *RotatingLog) Tail() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lines := l.tail
+	if len(l.partial) > 0 {
+		lines = append(lines[:len(lines):len(lines)], string(l.partial))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Close closes the log file.
+func (l *RotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
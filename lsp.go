@@ -0,0 +1,941 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/textproto"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+var (
+	// CallTimeout bounds the wait for a language server response.
+	// Set via the --lsp-timeout flag or lsp_timeout in the config.
+	CallTimeout = 15 * time.Second
+
+	// InitTimeout bounds the initialize handshake, which can take a while
+	// on large workspaces.
+	InitTimeout = 2 * time.Minute
+
+	// LoadTimeout bounds the wait for a server to finish loading the
+	// workspace (reported through $/progress) before a symbol query.
+	LoadTimeout = 30 * time.Second
+)
+
+// Servers announce their initial workspace load shortly after the
+// handshake: until startupGrace elapses or the server reports progress, the
+// pseudo startupToken keeps the client busy.
+const (
+	startupToken = "codemcp/startup"
+	startupGrace = 500 * time.Millisecond
+)
+
+// Restart policy of a crashing language server: at most maxRestarts
+// restarts within restartWindow, after which the client gives up.
+const (
+	maxRestarts   = 5
+	restartWindow = time.Minute
+)
+
+// LSPClient manages the lifecycle and communication with a language server
+// subprocess (gopls, rust-analyzer...).
+// It implements a basic JSON-RPC 2.0 client over Stdio.
+// If the process exits unexpectedly, in-flight calls fail and the server is
+// restarted with the same initialize handshake.
+type LSPClient struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	seq     int64 // Atomic sequence counter for request IDs
+	pending map[int64]chan JsonRpcResp
+	limiter *callLimiter // Bounds the requests in flight
+	mu      sync.Mutex
+
+	// name prefixes the reasons of symbol results, e.g. "gopls:Unmarshal".
+	name string
+	// classify tells whether a symbol location should be skipped as noise,
+	// and whether it belongs to a dependency.
+	classify func(path string) (skip bool, isDep bool)
+	// stdlib tells whether a location belongs to the standard library,
+	// returned by SymbolSearch only with SearchOptions.IncludeStdlib.
+	stdlib func(path string) bool
+
+	// Needed to restart the server
+	command     []string
+	env         []string // Extra "KEY=value" environment of the process
+	rootPath    string
+	folders     []string // Extra workspace folders, absolute
+	initOptions any
+
+	// capabilities are the ServerCapabilities of the initialize response,
+	// version the server version. Both guarded by mu.
+	capabilities map[string]json.RawMessage
+	version      string
+
+	// stderr receives the server logs, in .codemcp/logs/<name>.log
+	stderr *RotatingLog
+
+	// progress holds the work-done progress tokens in flight (e.g. gopls
+	// loading packages); idle is closed when it is empty. Both guarded by mu.
+	progress map[string]*WorkProgress
+	idle     chan struct{}
+
+	// docs maps the URIs opened with didOpen to their version, guarded by mu
+	docs map[string]int
+
+	gen      int         // Incremented on every (re)start, guarded by mu
+	started  bool        // Set once the first handshake succeeded, guarded by mu
+	closing  bool        // Set by Shutdown, guarded by mu
+	restarts []time.Time // Recent restarts, guarded by mu
+}
+
+// StartLSPClient spawns command in a background process, with env added to
+// the environment, and performs the LSP handshake (initialize -> initialized).
+// rootPath is the absolute path to the project root, used to set the workspace
+// context; folders are extra workspace folders (e.g. go.work modules).
+func StartLSPClient(name string, command []string, env []string, rootPath string, folders []string, initOptions any, classify func(string) (bool, bool)) (*LSPClient, error) {
+	client := &LSPClient{
+		pending:     make(map[int64]chan JsonRpcResp),
+		limiter:     newCallLimiter(MaxConcurrentCalls),
+		name:        name,
+		classify:    classify,
+		command:     command,
+		env:         env,
+		rootPath:    rootPath,
+		folders:     folders,
+		initOptions: initOptions,
+		stderr:      NewRotatingLog(filepath.Join(rootPath, LogDir, name+".log")),
+	}
+	if err := client.spawn(); err != nil {
+		client.Shutdown()
+		if tail := client.stderr.Tail(); tail != "" {
+			err = fmt.Errorf("%w, last %s logs:\n%s", err, name, tail)
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// spawn starts the server process, its read loop, and performs the handshake.
+func (c *LSPClient) spawn() error {
+	// Start the subprocess
+	cmd := exec.Command(c.command[0], c.command[1:]...)
+	if len(c.env) > 0 {
+		cmd.Env = append(os.Environ(), c.env...)
+	}
+	stdin, _ := cmd.StdinPipe()
+	stdout, _ := cmd.StdoutPipe()
+	// stderr goes to a log file rather than polluting our CLI output
+	cmd.Stderr = c.stderr
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.cmd = cmd
+	c.stdin = stdin
+	c.gen++
+	gen := c.gen
+	c.docs = make(map[string]int) // A new process has no open documents
+	if len(c.progress) > 0 {
+		close(c.idle) // Release the waiters of the previous process
+	}
+	c.progress = make(map[string]*WorkProgress)
+	c.idle = make(chan struct{})
+	close(c.idle)
+	c.mu.Unlock()
+
+	// Start the async reader loop to handle responses, and watch for exits
+	go func() {
+		c.ReadLoop(stdout)
+		c.handleExit(gen)
+	}()
+
+	// Send the LSP 'initialize' request
+	initParams := map[string]any{
+		"processId":        os.Getpid(),
+		"rootUri":          PathToURI(c.rootPath),
+		"workspaceFolders": c.workspaceFolders(),
+		"capabilities": map[string]any{
+			// Requests answered by handleServerRequest
+			"workspace": map[string]any{"configuration": true, "workspaceFolders": true},
+			"window":    map[string]any{"workDoneProgress": true},
+		},
+	}
+	if c.initOptions != nil {
+		initParams["initializationOptions"] = c.initOptions
+	}
+
+	// Block until initialization is acknowledged
+	resp, err := c.call(context.Background(), "initialize", initParams, InitTimeout)
+	if err == nil && resp == nil {
+		err = fmt.Errorf("empty initialize response")
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return err
+	}
+
+	var result struct {
+		Capabilities map[string]json.RawMessage `json:"capabilities"`
+		ServerInfo   struct {
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	_ = json.Unmarshal(resp, &result)
+	c.mu.Lock()
+	c.capabilities = result.Capabilities
+	if c.version == "" {
+		c.version = result.ServerInfo.Version
+	}
+	c.mu.Unlock()
+
+	// Notify the server that we are initialized
+	// Note: 'notify' does not expect a response.
+	if err := c.Notify("initialized", map[string]any{}); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.started = true
+	c.mu.Unlock()
+	c.setProgress(startupToken, &WorkProgress{Title: "Starting"})
+	time.AfterFunc(startupGrace, func() { c.setProgress(startupToken, nil) })
+	slog.Debug("language server started", "server", c.name, "version", c.version, "pid", cmd.Process.Pid)
+	return nil
+}
+
+// handleExit is called when the read loop of process generation gen stops:
+// the pipe closed, most likely because the server exited. Pending calls are
+// failed, then the server is restarted unless it is shutting down or crashed
+// too often.
+func (c *LSPClient) handleExit(gen int) {
+	c.mu.Lock()
+	if gen != c.gen {
+		// A newer process already replaced this one
+		c.mu.Unlock()
+		return
+	}
+	pending := c.pending
+	c.pending = make(map[int64]chan JsonRpcResp)
+	cmd := c.cmd
+	// A server that never started is not restarted: StartLSPClient reports it
+	closing := c.closing || !c.started
+	c.mu.Unlock()
+
+	// Closed channels make the waiting Calls return an error
+	for _, ch := range pending {
+		close(ch)
+	}
+	_ = cmd.Wait()
+
+	if closing {
+		return
+	}
+	if !c.allowRestart() {
+		slog.Warn("language server exited too often, giving up", "server", c.name, "exits", maxRestarts, "window", restartWindow)
+		return
+	}
+	slog.Warn("language server exited, restarting", "server", c.name)
+	if err := c.spawn(); err != nil {
+		slog.Error("language server restart failed", "server", c.name, "err", err)
+	}
+}
+
+// allowRestart records a restart and reports whether the restart policy permits it.
+func (c *LSPClient) allowRestart() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	recent := c.restarts[:0]
+	for _, t := range c.restarts {
+		if now.Sub(t) < restartWindow {
+			recent = append(recent, t)
+		}
+	}
+	c.restarts = recent
+	if len(c.restarts) >= maxRestarts {
+		return false
+	}
+	c.restarts = append(c.restarts, now)
+	return true
+}
+
+// workspaceFolders returns the root and the extra folders as LSP
+// WorkspaceFolder objects.
+func (c *LSPClient) workspaceFolders() []map[string]string {
+	folders := []map[string]string{{"uri": PathToURI(c.rootPath), "name": filepath.Base(c.rootPath)}}
+	for _, f := range c.folders {
+		if f != c.rootPath {
+			folders = append(folders, map[string]string{"uri": PathToURI(f), "name": filepath.Base(f)})
+		}
+	}
+	return folders
+}
+
+// Name returns the name of the language server, e.g. "gopls".
+func (c *LSPClient) Name() string {
+	return c.name
+}
+
+// Version returns the server version, from its version command or its
+// initialize response, or "" if unknown.
+func (c *LSPClient) Version() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// HasCapability reports whether the server advertised the capability
+// (e.g. "callHierarchyProvider", "typeHierarchyProvider"), which may be a
+// boolean or an options object.
+func (c *LSPClient) HasCapability(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.capabilities[name]
+	return ok && string(v) != "false" && string(v) != "null"
+}
+
+// HasCommand reports whether the server supports the workspace/executeCommand
+// command (e.g. "gopls.list_known_packages").
+func (c *LSPClient) HasCommand(command string) bool {
+	c.mu.Lock()
+	raw := c.capabilities["executeCommandProvider"]
+	c.mu.Unlock()
+	var provider struct {
+		Commands []string `json:"commands"`
+	}
+	if json.Unmarshal(raw, &provider) != nil {
+		return false
+	}
+	return slices.Contains(provider.Commands, command)
+}
+
+// Shutdown kills the underlying language server process, for good.
+func (c *LSPClient) Shutdown() {
+	c.mu.Lock()
+	c.closing = true
+	cmd := c.cmd
+	c.mu.Unlock()
+	if cmd != nil && cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+	_ = c.stderr.Close()
+}
+
+// JsonRpcReq represents an outgoing request.
+type JsonRpcReq struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int64  `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// JsonRpcNotification represents an outgoing notification (no ID).
+type JsonRpcNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// JsonRpcResp represents an incoming response. The ID is kept raw: it is a
+// number for our requests, but some servers echo it as a string.
+type JsonRpcResp struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is the error of a JSON-RPC response.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// LSP error codes of requests worth retrying.
+const (
+	codeServerNotInitialized = -32002
+	codeServerCancelled      = -32802
+	codeContentModified      = -32801
+)
+
+// transientError marks failures that may succeed when retried: timeouts, a
+// server exiting (it is restarted), or a server asking to retry.
+type transientError struct{ error }
+
+func (e transientError) Unwrap() error { return e.error }
+
+// isTransient reports whether err is worth retrying.
+func isTransient(err error) bool {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.Code {
+		case codeServerNotInitialized, codeServerCancelled, codeContentModified:
+			return true
+		}
+		return false
+	}
+	var t transientError
+	return errors.As(err, &t)
+}
+
+// JsonRpcServerReq represents an incoming request or notification sent by
+// the server. IDs may be numbers or strings and are echoed back verbatim.
+type JsonRpcServerReq struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// JsonRpcReply represents an outgoing response to a server request.
+type JsonRpcReply struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+// JsonRpcErrorReply represents an outgoing error response to a server request.
+type JsonRpcErrorReply struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   RPCError        `json:"error"`
+}
+
+// Notify sends a JSON-RPC notification (fire and forget).
+func (c *LSPClient) Notify(method string, params any) error {
+	msg := JsonRpcNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	}
+	return c.write(msg)
+}
+
+// Retry policy of Call: up to maxCallAttempts attempts, waiting
+// retryBackoff, then twice as long, etc. between them.
+const (
+	maxCallAttempts = 3
+	retryBackoff    = 200 * time.Millisecond
+)
+
+// Call sends a request and blocks waiting for a response, a timeout or the
+// cancellation of ctx. On cancellation gopls is told to drop the request
+// via $/cancelRequest so it does not keep working on it.
+// Transient failures (timeouts, server restarts, "content modified") are
+// retried with an exponential backoff; other errors are returned at once.
+func (c *LSPClient) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		res, err := c.call(ctx, method, params, CallTimeout)
+		if err == nil || !isTransient(err) || attempt == maxCallAttempts {
+			return res, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// call sends a request once, with an explicit timeout, after waiting for a
+// slot of the limiter.
+func (c *LSPClient) call(ctx context.Context, method string, params any, timeout time.Duration) (json.RawMessage, error) {
+	if err := c.limiter.acquire(ctx, methodPriority[method]); err != nil {
+		return nil, err
+	}
+	defer c.limiter.release()
+
+	id := atomic.AddInt64(&c.seq, 1)
+	ch := make(chan JsonRpcResp, 1)
+
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	req := JsonRpcReq{
+		JSONRPC: "2.0",
+		ID:      id,
+		Method:  method,
+		Params:  params,
+	}
+
+	if err := c.write(req); err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		// Most likely the server is gone and being restarted
+		return nil, transientError{err}
+	}
+
+	// Wait for response or timeout
+	select {
+	case res, ok := <-ch:
+		if !ok {
+			return nil, transientError{fmt.Errorf("%s exited while handling %s", c.name, method)}
+		}
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		return res.Result, nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		_ = c.Notify("$/cancelRequest", map[string]any{"id": id})
+		return nil, ctx.Err()
+	case <-time.After(timeout):
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return nil, transientError{fmt.Errorf("timeout waiting for %s response", c.name)}
+	}
+}
+
+// write formats the message with LSP Content-Length headers and writes to stdin.
+func (c *LSPClient) write(msg any) error {
+	body, _ := json.Marshal(msg)
+	// LSP requires Content-Length header followed by \r\n\r\n
+	header := fmt.Sprintf("Content-Length: %d\r\n\r\n", len(body))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.stdin.Write([]byte(header)); err != nil {
+		return err
+	}
+	_, err := c.stdin.Write(body)
+	return err
+}
+
+// ReadLoop runs in a background goroutine. It continuously parses headers
+// and bodies from the gopls stdout stream.
+func (c *LSPClient) ReadLoop(r io.Reader) {
+	reader := bufio.NewReader(r)
+	tp := textproto.NewReader(reader)
+
+	for {
+		// Read MIME Headers (e.g. Content-Length: 123)
+		headers, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return // Pipe closed or error
+		}
+
+		lengthStr := headers.Get("Content-Length")
+		length, _ := strconv.Atoi(lengthStr)
+
+		if length == 0 {
+			continue
+		}
+
+		// Read the exact number of bytes for the Body
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+
+		// Process the message asynchronously to not block reading
+		go c.handleMessage(body)
+	}
+}
+
+// handleMessage dispatches responses to waiting callers via channels.
+func (c *LSPClient) handleMessage(body []byte) {
+	var req JsonRpcServerReq
+	if err := json.Unmarshal(body, &req); err == nil && req.Method != "" {
+		if len(req.ID) > 0 && string(req.ID) != "null" {
+			c.handleServerRequest(req)
+			return
+		}
+		if req.Method == "$/progress" {
+			var params struct {
+				Token json.RawMessage `json:"token"`
+				Value struct {
+					Kind string `json:"kind"`
+					WorkProgress
+				} `json:"value"`
+			}
+			if json.Unmarshal(req.Params, &params) == nil {
+				token := string(params.Token)
+				switch params.Value.Kind {
+				case "begin":
+					c.setProgress(token, &params.Value.WorkProgress)
+				case "report":
+					c.reportProgress(token, params.Value.WorkProgress)
+				case "end":
+					c.setProgress(token, nil)
+				}
+			}
+		}
+		// Note: We currently ignore other server-sent notifications (like diagnostics/publishDiagnostics)
+		return
+	}
+
+	var resp JsonRpcResp
+	// Try to unmarshal. We only care about responses with IDs (0 included).
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return
+	}
+	if id, ok := parseID(resp.ID); ok {
+		c.mu.Lock()
+		ch, ok := c.pending[id]
+		if ok {
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+// parseID reads the ID of a response: a number, or a string holding one.
+// A missing or null ID is not valid.
+func parseID(raw json.RawMessage) (int64, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, false
+	}
+	var n int64
+	if json.Unmarshal(raw, &n) == nil {
+		return n, true
+	}
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		n, err := strconv.ParseInt(str, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// handleServerRequest answers the requests a server sends to its client.
+// Servers may block until they get a reply (e.g. gopls waiting for its
+// configuration), so every request is answered, with sensible defaults.
+func (c *LSPClient) handleServerRequest(req JsonRpcServerReq) {
+	var result any
+	switch req.Method {
+	case "workspace/configuration":
+		// One entry per requested item: the initialization options for the
+		// server's own section, null (defaults) otherwise.
+		var params struct {
+			Items []struct {
+				Section string `json:"section"`
+			} `json:"items"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		items := make([]any, len(params.Items))
+		for i, item := range params.Items {
+			if item.Section == c.name {
+				items[i] = c.initOptions
+			}
+		}
+		result = items
+	case "window/workDoneProgress/create":
+		// The server is about to report some work, consider it busy already
+		var params struct {
+			Token json.RawMessage `json:"token"`
+		}
+		if json.Unmarshal(req.Params, &params) == nil {
+			c.setProgress(string(params.Token), &WorkProgress{})
+		}
+		result = nil
+	case "client/registerCapability", "client/unregisterCapability", "window/showMessageRequest",
+		"workspace/codeLens/refresh", "workspace/semanticTokens/refresh",
+		"workspace/inlayHint/refresh", "workspace/diagnostic/refresh":
+		result = nil
+	case "workspace/workspaceFolders":
+		result = c.workspaceFolders()
+	case "workspace/applyEdit":
+		// codemcp never lets a server edit files
+		result = map[string]any{"applied": false, "failureReason": "codemcp does not apply edits"}
+	default:
+		reply := JsonRpcErrorReply{JSONRPC: "2.0", ID: req.ID}
+		reply.Error.Code = -32601 // Method not found
+		reply.Error.Message = "unsupported method " + req.Method
+		_ = c.write(reply)
+		return
+	}
+	_ = c.write(JsonRpcReply{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+// WorkProgress is a work-done progress reported by a server, e.g. gopls
+// loading packages.
+type WorkProgress struct {
+	Title      string `json:"title"`
+	Message    string `json:"message,omitempty"`
+	Percentage int    `json:"percentage,omitempty"`
+}
+
+// setProgress marks the progress token as in flight with p, or done if p
+// is nil.
+func (c *LSPClient) setProgress(token string, p *WorkProgress) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p != nil {
+		if len(c.progress) == 0 {
+			c.idle = make(chan struct{})
+			if token != startupToken {
+				slog.Info("language server indexing", "server", c.name, "title", p.Title)
+			}
+		}
+		c.progress[token] = p
+		if token != startupToken {
+			// The actual work replaces the startup guess
+			delete(c.progress, startupToken)
+		}
+		return
+	}
+	if _, ok := c.progress[token]; ok {
+		delete(c.progress, token)
+		if len(c.progress) == 0 {
+			close(c.idle)
+			slog.Info("language server ready", "server", c.name)
+		}
+	}
+}
+
+// reportProgress updates the message and percentage of a progress in flight.
+func (c *LSPClient) reportProgress(token string, report WorkProgress) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.progress[token]; ok {
+		if report.Message != "" {
+			p.Message = report.Message
+		}
+		if report.Percentage > 0 {
+			p.Percentage = report.Percentage
+		}
+	}
+}
+
+// Progress returns the work in progress, sorted by title.
+func (c *LSPClient) Progress() []WorkProgress {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	progress := make([]WorkProgress, 0, len(c.progress))
+	for _, p := range c.progress {
+		progress = append(progress, *p)
+	}
+	sort.Slice(progress, func(i, j int) bool { return progress[i].Title < progress[j].Title })
+	return progress
+}
+
+// WaitIdle blocks until the server reports no work in progress, ctx is
+// done or timeout elapses. It reports whether the server is idle.
+func (c *LSPClient) WaitIdle(ctx context.Context, timeout time.Duration) bool {
+	c.mu.Lock()
+	idle := c.idle
+	c.mu.Unlock()
+
+	select {
+	case <-idle:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// SyncFile tells the server that absPath changed on disk, so its view of the
+// workspace matches the files: the first change opens the document
+// (didOpen), later ones send the full content (didChange, didSave), and a
+// deleted file is closed (didClose).
+func (c *LSPClient) SyncFile(absPath string, languageID string) error {
+	uri := PathToURI(absPath)
+	content, readErr := os.ReadFile(absPath)
+
+	c.mu.Lock()
+	version, open := c.docs[uri]
+	switch {
+	case readErr != nil && open:
+		delete(c.docs, uri)
+	case readErr == nil:
+		c.docs[uri] = version + 1
+	}
+	c.mu.Unlock()
+
+	if readErr != nil {
+		if !open {
+			return nil
+		}
+		return c.Notify("textDocument/didClose", map[string]any{
+			"textDocument": map[string]any{"uri": uri},
+		})
+	}
+	if !open {
+		return c.Notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{
+				"uri":        uri,
+				"languageId": languageID,
+				"version":    1,
+				"text":       string(content),
+			},
+		})
+	}
+	if err := c.Notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": version + 1},
+		"contentChanges": []map[string]any{{"text": string(content)}},
+	}); err != nil {
+		return err
+	}
+	return c.Notify("textDocument/didSave", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+	})
+}
+
+// SymbolSearch sends a 'workspace/symbol' request to the language server.
+// It performs aggressive filtering to reduce noise from the standard library
+// and internal dependencies.
+func (c *LSPClient) SymbolSearch(ctx context.Context, query string, opts SearchOptions) ([]FileScore, error) {
+	if !c.HasCapability("workspaceSymbolProvider") {
+		return nil, nil
+	}
+	params := map[string]any{
+		"query": query,
+	}
+
+	// Queries sent while the workspace loads return partial or no results
+	loaded := c.WaitIdle(ctx, LoadTimeout)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	res, err := c.Call(ctx, "workspace/symbol", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var symbols []struct {
+		Name          string `json:"name"`
+		Kind          int    `json:"kind"` // 12=Function, 5=Struct, etc.
+		ContainerName string `json:"containerName"`
+		Location      struct {
+			URI string `json:"uri"`
+		} `json:"location"`
+	}
+
+	if err := json.Unmarshal(res, &symbols); err != nil {
+		return nil, err
+	}
+
+	var results []FileScore
+	queryLower := strings.ToLower(query)
+
+	for _, s := range symbols {
+		// Filter 1: Strict Matching
+		// Gopls fuzzy matching is very loose (e.g. "search" matches "TLS_ECDHE...").
+		// We enforce contiguous substring matching.
+		nameLower := strings.ToLower(s.Name)
+		if !strings.Contains(nameLower, queryLower) {
+			continue
+		}
+
+		// Convert URI (file:///path) to a standard path string
+		pathStr, ok := URIToPath(s.Location.URI)
+		if !ok {
+			pathStr = s.Location.URI // e.g. jdt://, left to classify
+		}
+
+		// Filter 2: Noise Reduction
+		// Each server decides what is noise (e.g. the Go Standard Library).
+		skip, isDep := c.classify(pathStr)
+		if skip && opts.IncludeStdlib && c.stdlib != nil && c.stdlib(pathStr) {
+			// Opted in: the standard library is a dependency like any other
+			skip, isDep = false, true
+		}
+		if skip {
+			continue
+		}
+
+		// Scoring Logic
+		score := Weights.LSPMatch // Base score for a gopls match
+
+		// Boost: Exact Match or Prefix Match
+		if strings.EqualFold(s.Name, query) {
+			score += Weights.LSPExact
+		} else if strings.HasPrefix(nameLower, queryLower) {
+			score += Weights.LSPPrefix
+		}
+
+		// Boost: Significant Types (Structs, Functions, Interfaces)
+		// LSP Kinds: 5=Class, 11=Function, 12=Method
+		if s.Kind == 5 || s.Kind == 11 || s.Kind == 12 {
+			score += Weights.LSPKind
+		}
+
+		// Penalty: Dependencies
+		// We want user code to rank higher than library code usually.
+		if isDep {
+			score -= Weights.DependencyPenalty
+		}
+
+		// Penalty: Dependency Tests
+		// Tests inside dependencies are almost never relevant search results.
+		if isDep && (strings.HasSuffix(pathStr, "_test.go") || strings.Contains(pathStr, "/tests/")) {
+			score -= Weights.DependencyTestPenalty
+		}
+
+		if score <= 0 {
+			continue
+		}
+
+		results = append(results, FileScore{
+			Path:    pathStr,
+			Score:   score,
+			Reasons: []string{fmt.Sprintf("%s:%s", c.name, s.Name)},
+			IsDep:   isDep,
+		})
+	}
+
+	if !loaded && len(results) == 0 {
+		return nil, fmt.Errorf("%s is still loading the workspace after %v, no symbols yet", c.name, LoadTimeout)
+	}
+	return results, nil
+}
+
+// URIToPath converts a file:// URI to a local path, percent-decoding it
+// (spaces, Unicode) and handling Windows drive letters (file:///C:/x) and
+// UNC shares (file://server/share). ok is false for other schemes.
+func URIToPath(uri string) (path string, ok bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	p := u.Path
+	if len(p) >= 3 && p[0] == '/' && p[2] == ':' && unicode.IsLetter(rune(p[1])) {
+		p = p[1:] // /C:/x -> C:/x
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		p = "//" + u.Host + p
+	}
+	return filepath.FromSlash(p), true
+}
+
+// PathToURI converts an absolute path to a percent-encoded file:// URI.
+func PathToURI(path string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // C:/x -> /C:/x
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
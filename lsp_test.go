@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nopWriteCloser is a client stdin recording what is written to it.
+type nopWriteCloser struct{ bytes.Buffer }
+
+func (*nopWriteCloser) Close() error { return nil }
+
+// newTestClient returns a client without a process, writing to stdin.
+func newTestClient(stdin io.WriteCloser) *LSPClient {
+	idle := make(chan struct{})
+	close(idle)
+	return &LSPClient{
+		name:        "testls",
+		stdin:       stdin,
+		pending:     make(map[int64]chan JsonRpcResp),
+		limiter:     newCallLimiter(4),
+		progress:    make(map[string]*WorkProgress),
+		idle:        idle,
+		initOptions: map[string]any{"staticcheck": true},
+	}
+}
+
+// frame returns body with its LSP Content-Length header.
+func frame(body string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// readFrames parses the LSP messages of r.
+func readFrames(t *testing.T, r io.Reader) []map[string]any {
+	t.Helper()
+	reader := bufio.NewReader(r)
+	tp := textproto.NewReader(reader)
+	var msgs []map[string]any
+	for {
+		headers, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return msgs
+		}
+		length, err := strconv.Atoi(headers.Get("Content-Length"))
+		if err != nil {
+			t.Fatalf("invalid Content-Length %q", headers.Get("Content-Length"))
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			t.Fatal(err)
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		raw    string
+		want   int64
+		wantOK bool
+	}{
+		{`7`, 7, true},
+		{`0`, 0, true},
+		{`"12"`, 12, true},
+		{`"abc"`, 0, false},
+		{`null`, 0, false},
+		{``, 0, false},
+		{`{}`, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseID(json.RawMessage(tt.raw))
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseID(%s) = %d, %v, want %d, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestReadLoopDispatchesResponses(t *testing.T) {
+	c := newTestClient(&nopWriteCloser{})
+	chans := make(map[int64]chan JsonRpcResp)
+	for _, id := range []int64{1, 2, 3} {
+		chans[id] = make(chan JsonRpcResp, 1)
+		c.pending[id] = chans[id]
+	}
+
+	stream := frame(`{"jsonrpc":"2.0","id":1,"result":{"n":1}}`) +
+		// Extra headers are allowed, and IDs may be echoed as strings
+		"Content-Type: application/vscode-jsonrpc; charset=utf-8\r\n" + frame(`{"jsonrpc":"2.0","id":"2","result":"two"}`) +
+		frame(`{"jsonrpc":"2.0","id":3,"error":{"code":-32801,"message":"content modified"}}`) +
+		// Unknown and missing IDs are dropped
+		frame(`{"jsonrpc":"2.0","id":99,"result":null}`) +
+		frame(`{"jsonrpc":"2.0","result":null}`)
+	c.ReadLoop(strings.NewReader(stream))
+
+	receive := func(id int64) JsonRpcResp {
+		t.Helper()
+		select {
+		case resp := <-chans[id]:
+			return resp
+		case <-time.After(time.Second):
+			t.Fatalf("no response %d", id)
+			return JsonRpcResp{}
+		}
+	}
+	if resp := receive(1); string(resp.Result) != `{"n":1}` {
+		t.Errorf("response 1 = %s", resp.Result)
+	}
+	if resp := receive(2); string(resp.Result) != `"two"` {
+		t.Errorf("response 2 = %s", resp.Result)
+	}
+	if resp := receive(3); resp.Error == nil || resp.Error.Code != codeContentModified || !isTransient(resp.Error) {
+		t.Errorf("response 3 error = %v", resp.Error)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) != 0 {
+		t.Errorf("%d calls still pending", len(c.pending))
+	}
+}
+
+func TestHandleServerRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		id      any // Echoed verbatim
+		check   func(t *testing.T, reply map[string]any)
+	}{
+		{
+			name:    "configuration with a numeric ID",
+			request: `{"jsonrpc":"2.0","id":7,"method":"workspace/configuration","params":{"items":[{"section":"testls"},{"section":"other"}]}}`,
+			id:      float64(7),
+			check: func(t *testing.T, reply map[string]any) {
+				items, _ := reply["result"].([]any)
+				if len(items) != 2 || items[1] != nil {
+					t.Fatalf("result = %v", reply["result"])
+				}
+				if opts, _ := items[0].(map[string]any); opts["staticcheck"] != true {
+					t.Errorf("own section = %v, want the initialization options", items[0])
+				}
+			},
+		},
+		{
+			name:    "apply edit with a string ID",
+			request: `{"jsonrpc":"2.0","id":"req-1","method":"workspace/applyEdit","params":{}}`,
+			id:      "req-1",
+			check: func(t *testing.T, reply map[string]any) {
+				if result, _ := reply["result"].(map[string]any); result["applied"] != false {
+					t.Errorf("result = %v, want applied false", reply["result"])
+				}
+			},
+		},
+		{
+			name:    "register capability",
+			request: `{"jsonrpc":"2.0","id":0,"method":"client/registerCapability","params":{}}`,
+			id:      float64(0),
+			check: func(t *testing.T, reply map[string]any) {
+				if result, ok := reply["result"]; !ok || result != nil {
+					t.Errorf("result = %v, want null", result)
+				}
+			},
+		},
+		{
+			name:    "unknown method",
+			request: `{"jsonrpc":"2.0","id":"x","method":"custom/method"}`,
+			id:      "x",
+			check: func(t *testing.T, reply map[string]any) {
+				rpcErr, _ := reply["error"].(map[string]any)
+				if rpcErr["code"] != float64(-32601) {
+					t.Errorf("error = %v, want method not found", reply["error"])
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdin := &nopWriteCloser{}
+			c := newTestClient(stdin)
+			c.handleMessage([]byte(tt.request))
+			replies := readFrames(t, &stdin.Buffer)
+			if len(replies) != 1 {
+				t.Fatalf("got %d replies, want 1", len(replies))
+			}
+			if replies[0]["id"] != tt.id {
+				t.Errorf("reply ID = %#v, want %#v", replies[0]["id"], tt.id)
+			}
+			tt.check(t, replies[0])
+		})
+	}
+}
+
+func TestHandleProgressNotifications(t *testing.T) {
+	stdin := &nopWriteCloser{}
+	c := newTestClient(stdin)
+
+	c.handleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"window/workDoneProgress/create","params":{"token":"load"}}`))
+	c.handleMessage([]byte(`{"jsonrpc":"2.0","method":"$/progress","params":{"token":"load","value":{"kind":"begin","title":"Loading packages"}}}`))
+	c.handleMessage([]byte(`{"jsonrpc":"2.0","method":"$/progress","params":{"token":"load","value":{"kind":"report","message":"3/10","percentage":30}}}`))
+	if p := c.Progress(); len(p) != 1 || p[0].Title != "Loading packages" || p[0].Percentage != 30 {
+		t.Fatalf("progress = %+v", p)
+	}
+	if c.WaitIdle(context.Background(), 10*time.Millisecond) {
+		t.Error("idle while loading")
+	}
+
+	c.handleMessage([]byte(`{"jsonrpc":"2.0","method":"$/progress","params":{"token":"load","value":{"kind":"end"}}}`))
+	if !c.WaitIdle(context.Background(), time.Second) {
+		t.Error("not idle after the end of the progress")
+	}
+	// Notifications are not answered, the create request is
+	if replies := readFrames(t, &stdin.Buffer); len(replies) != 1 {
+		t.Errorf("got %d replies, want 1", len(replies))
+	}
+}
+
+func TestCallRoundTrip(t *testing.T) {
+	toServer, clientOut := io.Pipe()
+	serverOut, fromServer := io.Pipe()
+	c := newTestClient(clientOut)
+	go c.ReadLoop(serverOut)
+	t.Cleanup(func() {
+		clientOut.Close()
+		fromServer.Close()
+	})
+
+	// The server asks for its configuration before answering, and echoes
+	// the request ID as a string
+	go func() {
+		reader := bufio.NewReader(toServer)
+		tp := textproto.NewReader(reader)
+		read := func() map[string]any {
+			headers, err := tp.ReadMIMEHeader()
+			if err != nil {
+				return nil
+			}
+			length, _ := strconv.Atoi(headers.Get("Content-Length"))
+			body := make([]byte, length)
+			if _, err := io.ReadFull(reader, body); err != nil {
+				return nil
+			}
+			var msg map[string]any
+			_ = json.Unmarshal(body, &msg)
+			return msg
+		}
+		req := read()
+		if req == nil {
+			return
+		}
+		_, _ = io.WriteString(fromServer, frame(`{"jsonrpc":"2.0","id":"cfg","method":"workspace/configuration","params":{"items":[{"section":"testls"}]}}`))
+		if reply := read(); reply == nil || reply["id"] != "cfg" {
+			return
+		}
+		id := strconv.FormatFloat(req["id"].(float64), 'f', -1, 64)
+		_, _ = io.WriteString(fromServer, frame(`{"jsonrpc":"2.0","id":"`+id+`","result":[{"name":"Handler"}]}`))
+	}()
+
+	res, err := c.call(context.Background(), "workspace/symbol", map[string]any{"query": "Handler"}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res) != `[{"name":"Handler"}]` {
+		t.Errorf("result = %s", res)
+	}
+}
@@ -3,18 +3,23 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"log/slog"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 	"unicode"
 
@@ -24,10 +29,33 @@ import (
 
 var (
 	// ExtensionWeights prioritizes source code files over config or documentation files.
-	// Used in ScoreFile logic.
+	// Used in ScoreFile logic. These are the defaults, extended or overridden
+	// by the extension_weights section of the config file.
 	ExtensionWeights = map[string]int{
 		".go": 25, ".ts": 20, ".tsx": 20, ".js": 15,
 		".rs": 20, ".zig": 20, ".py": 20, ".java": 15, ".h": 20, ".cpp": 20, ".c": 20,
+		".tf": 15, ".hcl": 10,
+	}
+
+	// SymbolExtractors maps file extensions to the function extracting their
+	// definitions. Used in ScoreFile logic; other files only get path scoring.
+	SymbolExtractors = map[string]func(context.Context, string) []Symbol{
+		".go": ExtractGoSymbols,
+		".ts": ExtractJSSymbols, ".tsx": ExtractJSSymbols, ".mts": ExtractJSSymbols, ".cts": ExtractJSSymbols,
+		".js": ExtractJSSymbols, ".jsx": ExtractJSSymbols, ".mjs": ExtractJSSymbols, ".cjs": ExtractJSSymbols,
+		".py": ExtractPythonSymbols, ".pyi": ExtractPythonSymbols,
+		".java": ExtractJavaSymbols,
+		".tf":   ExtractTerraformSymbols, ".hcl": ExtractHCLSymbols,
+		".sh": ExtractShellSymbols, ".bash": ExtractShellSymbols, ".zsh": ExtractShellSymbols,
+		".mk": ExtractMakefileSymbols,
+	}
+
+	// FileNameExtractors maps lower-cased file names to their symbol extractor,
+	// for files recognized by name rather than extension. Checked before
+	// SymbolExtractors.
+	FileNameExtractors = map[string]func(context.Context, string) []Symbol{
+		"makefile": ExtractMakefileSymbols, "gnumakefile": ExtractMakefileSymbols,
+		"justfile": ExtractJustfileSymbols, ".justfile": ExtractJustfileSymbols,
 	}
 
 	// IgnoreDirs contains directory names that should be skipped during
@@ -35,118 +63,505 @@ var (
 	IgnoreDirs = map[string]bool{
 		".git": true, "node_modules": true, "vendor": true,
 		".next": true, ".idea": true, ".vscode": true, "bin": true,
+		".codemcp": true, ".venv": true, "__pycache__": true,
 	}
 
+	// Workers is the maximum number of files scored/parsed concurrently.
+	// Set via the --workers flag.
+	Workers = max(1, runtime.NumCPU()/2)
+
+	// Weights are the scores of the different kinds of matches.
+	// Set via score_weights in the config.
+	Weights = ScoreWeights{
+		ExactFile:             500,
+		PathMatch:             50,
+		Symbol:                40,
+		LSPMatch:              50,
+		LSPExact:              50,
+		LSPPrefix:             20,
+		LSPKind:               10,
+		DependencyPenalty:     25,
+		DependencyTestPenalty: 50,
+		BranchChange:          30,
+	}
+
+	// MaxResults is the number of results returned by a search.
+	// Set via max_results in the config.
+	MaxResults = 50
+
+	// DisabledTools lists the MCP tools not exposed by the server.
+	// Set via disabled_tools in the config, extended by the --disable-tools
+	// flag or CODEMCP_DISABLE_TOOLS.
+	DisabledTools = map[string]bool{}
+
+	// EnabledTools, when not empty, lists the only MCP tools exposed by the
+	// server (minus DisabledTools).
+	// Set via the --enable-tools flag or CODEMCP_ENABLE_TOOLS.
+	EnabledTools = map[string]bool{}
+
 	// AllowedPathPrefixes stores absolute paths that are safe to read from.
 	// This includes the project root, GOMODCACHE, and GOROOT.
 	AllowedPathPrefixes []string
 )
 
+// scoreBatchSize is the number of files scored between two scheduler yields.
+const scoreBatchSize = 64
+
 // FileScore represents the relevance of a file to a search query.
 type FileScore struct {
 	Path    string   `json:"path"`
 	Score   int      `json:"score"`
-	Reasons []string `json:"reasons"`       // e.g., "exact-file", "func:Login"
-	IsDep   bool     `json:"is_dependency"` // True if file is from external module
+	Reasons []string `json:"reasons"`          // e.g., "exact-file", "func:Login"
+	IsDep   bool     `json:"is_dependency"`    // True if file is from external module
+	Root    string   `json:"root,omitempty"`   // Search root of the file, with several roots
+	Owners  []string `json:"owners,omitempty"` // From CODEOWNERS, for project files
+}
+
+// ScoreWeights are the tunable scores of a match.
+type ScoreWeights struct {
+	ExactFile             int // File name equal to the query
+	PathMatch             int // Every term found in the path
+	Symbol                int // Per symbol matching a term
+	LSPMatch              int // Base score of a language server hit
+	LSPExact              int // Language server symbol equal to the query
+	LSPPrefix             int // Language server symbol starting with the query
+	LSPKind               int // Language server hit on a class, function or method
+	DependencyPenalty     int // Subtracted from dependency hits
+	DependencyTestPenalty int // Subtracted from dependency test hits
+	BranchChange          int // Added to files changed on the current branch
+}
+
+// fields maps the score_weights config keys to the weights.
+func (w *ScoreWeights) fields() map[string]*int {
+	return map[string]*int{
+		"exact_file":              &w.ExactFile,
+		"path_match":              &w.PathMatch,
+		"symbol":                  &w.Symbol,
+		"lsp_match":               &w.LSPMatch,
+		"lsp_exact":               &w.LSPExact,
+		"lsp_prefix":              &w.LSPPrefix,
+		"lsp_kind":                &w.LSPKind,
+		"dependency_penalty":      &w.DependencyPenalty,
+		"dependency_test_penalty": &w.DependencyTestPenalty,
+		"branch_change":           &w.BranchChange,
+	}
+}
+
+// SearchOptions tunes a search beyond its query.
+type SearchOptions struct {
+	// IncludeStdlib keeps the standard library symbols returned by the
+	// language servers (GOROOT for gopls), filtered out by default.
+	IncludeStdlib bool `json:"include_stdlib,omitempty"`
+
+	// Ref searches the project files as of a git ref (branch, tag or
+	// commit) instead of the working tree, without the language servers.
+	Ref string `json:"ref,omitempty"`
 }
 
 // CLIOutput defines the JSON structure when running in --json mode.
 type CLIOutput struct {
 	Query    string      `json:"query"`
+	Ref      string      `json:"ref,omitempty"`      // Git ref searched instead of the working tree
+	Worktree *Worktree   `json:"worktree,omitempty"` // Git working tree and branch of the root
 	Duration string      `json:"duration"`
 	Count    int         `json:"count"`
 	Files    []FileScore `json:"files"`
+	Error    string      `json:"error,omitempty"` // Failed --stdin query, or failed dependency backends
+	// Offset of the next files of a search_files result over MaxResultBytes
+	NextOffset int `json:"next_offset,omitempty"`
 }
 
 func main() {
-	jsonOutput := flag.Bool("json", false, "Output results as JSON")
-	searchPath := flag.String("path", ".", "Root path to search")
-	useGopls := flag.Bool("gopls", true, "Use gopls for dependency search")
+	// Until SetupLogging, log to stderr
+	slog.SetDefault(newStderrLogger())
+
+	// CODEMCP_* environment variables provide the defaults of the flags
+	applyEnv()
+
+	format := flag.String("format", envString("FORMAT", FormatTable), "Output format: "+strings.Join(Formats, ", "))
+	jsonOutput := flag.Bool("json", envBool("JSON", false), "Same as -format=json (deprecated)")
+	flag.BoolVar(&Quiet, "quiet", envBool("QUIET", false), "Do not print the banner lines before the results table")
+	searchPaths := newRootsFlag(envString("ROOT", "."))
+	flag.Var(searchPaths, "path", "Root path to search; repeat it or separate paths with commas to search several roots, the first one being the project root")
+	useGopls := flag.Bool("gopls", envBool("GOPLS", true), "Use gopls for dependency search")
+	includeStdlib := flag.Bool("stdlib", envBool("STDLIB", false), "Include standard library symbols in dependency results")
+	ref := flag.String("ref", envString("REF", ""), "Search the project files as of this git ref (branch, tag or commit) instead of the working tree, without dependencies")
+	useRust := flag.Bool("rust-analyzer", envBool("RUST_ANALYZER", true), "Use rust-analyzer for dependency search in Cargo projects")
+	flag.BoolVar(&NodeModules, "node-modules", NodeModules, "Search and read node_modules dependencies of TS/JS projects")
+	flag.IntVar(&Workers, "workers", Workers, "Maximum number of files scored concurrently")
+	remote := flag.Bool("remote", false, "Require a running daemon to answer the query")
+	socketPath := flag.String("socket", envString("SOCKET", ""), "Daemon Unix socket (default: derived from the project root)")
+	useCache := flag.Bool("cache", envBool("CACHE", true), "Cache CLI query results under .codemcp/cache")
+	showStatus := flag.Bool("status", false, "Start the language servers and print their indexing status")
+	watch := flag.Bool("watch", false, "Re-run the query whenever a project file changes")
+	flag.StringVar(&ListenAddr, "listen", envString("LISTEN", ""), "Serve MCP over HTTP (streamable HTTP at /mcp, SSE at /sse) on this address, e.g. :8080, instead of stdio")
+	flag.StringVar(&AuthToken, "auth-token", envString("AUTH_TOKEN", ""), "Bearer token HTTP clients must send (prefer CODEMCP_AUTH_TOKEN, flags are visible to other users)")
+	flag.StringVar(&TLSCert, "tls-cert", envString("TLS_CERT", ""), "Certificate file to serve HTTPS with -listen")
+	flag.StringVar(&AdminToken, "admin-token", envString("ADMIN_TOKEN", ""), "Bearer token of the "+AdminEndpoint+" endpoint changing the exposed tools at runtime, disabled without it (prefer CODEMCP_ADMIN_TOKEN)")
+	flag.StringVar(&TLSKey, "tls-key", envString("TLS_KEY", ""), "Private key file of -tls-cert")
+	sessionRootDirs := flag.String("session-roots", envString("SESSION_ROOTS", ""), "Comma-separated directories under which HTTP clients may bind their session to a project with the "+RootHeader+" header")
+	flag.BoolVar(&ClientRoots, "client-roots", envBool("CLIENT_ROOTS", ClientRoots), "Search and read the workspace roots advertised by MCP clients")
+	stdinBatch := flag.Bool("stdin", false, "Read one query per line from stdin and print the results of each as a JSON line")
+	flag.DurationVar(&WatchInterval, "watch-interval", WatchInterval, "How often -watch checks the project for changes")
+	showVersion := flag.Bool("version", false, "Print the version, VCS revision and Go version, then exit")
+	lspConcurrency := flag.Int("lsp-concurrency", MaxConcurrentCalls, "Maximum concurrent requests per language server (overrides lsp_concurrency in the config)")
+	lspTimeout := flag.Duration("lsp-timeout", CallTimeout, "Maximum wait for a language server response (overrides lsp_timeout in the config)")
+	maxResultBytes := flag.Int("max-result-bytes", MaxResultBytes, "Maximum size of a MCP tool result, truncated beyond it, 0 for no limit (overrides max_result_bytes in the config)")
+	callsPerMinute := flag.Int("calls-per-minute", CallsPerMinute, "Maximum MCP tool calls of a session per minute, 0 for no limit (overrides calls_per_minute in the config)")
+	bytesPerMinute := flag.Int("bytes-per-minute", BytesPerMinute, "Maximum MCP tool result bytes of a session per minute, 0 for no limit (overrides bytes_per_minute in the config)")
+	grepBackend := flag.String("grep-backend", GrepBackend, "Content search backend of the grep_files MCP tool: auto, git (git grep, tracked files) or go (overrides grep_backend in the config)")
+	linter := flag.String("linter", Linter, "Linter of the lint MCP tool: auto (golangci-lint with a config in the project, staticcheck otherwise), staticcheck or golangci-lint (overrides linter in the config)")
+	untracked := flag.Bool("untracked", envBool("UNTRACKED", IncludeUntracked), "Search the files git does not track yet (overrides untracked in the config)")
+	ignored := flag.Bool("ignored", envBool("IGNORED", IncludeIgnored), "Search the files git ignores, e.g. generated code (overrides ignored in the config)")
+	audit := flag.Bool("audit", envBool("AUDIT", AuditEnabled), "Record every MCP tool call under .codemcp/audit (overrides audit in the config)")
+	enableTools := flag.String("enable-tools", envString("ENABLE_TOOLS", ""), "Comma-separated MCP tools to expose, all by default, e.g. search_files,outline_markdown")
+	disableTools := flag.String("disable-tools", envString("DISABLE_TOOLS", ""), "Comma-separated MCP tools not to expose, on top of disabled_tools in the config")
+	flag.BoolVar(&ConfirmWrites, "confirm", envBool("CONFIRM", ConfirmWrites), "Ask the user to approve the changes of write_file, edit_file and apply_patch, through MCP elicitation or a confirm argument")
+	flag.StringVar(&Mode, "mode", envString("MODE", Mode), "MCP server mode: ro (read-only) or rw (adds the write_file, edit_file and apply_patch tools)")
+	logLevel := LogLevel.Level()
+	flag.TextVar(&logLevel, "log-level", logLevel, "Minimum level of the log messages: debug, info, warn or error (overrides log_level in the config)")
+	logFile := flag.String("log-file", LogFile, "Append the log messages to this file as JSON lines instead of stderr (overrides log_file in the config)")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] <query>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] -stdin < queries.txt\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] daemon\n", os.Args[0])
 		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nFlag defaults can be set with %s<FLAG> environment variables (e.g. %sGOPLS=false, %sROOT=/src/app).\n", EnvPrefix, EnvPrefix, EnvPrefix)
 	}
 
 	flag.Parse()
 	args := flag.Args()
+	searchOpts := SearchOptions{IncludeStdlib: *includeStdlib, Ref: *ref}
+
+	if *jsonOutput {
+		*format = FormatJSON
+	}
+	if !validFormat(*format) {
+		slog.Error("invalid format", "format", *format, "expected", strings.Join(Formats, ", "))
+		os.Exit(ExitUsage)
+	}
+
+	if *showVersion {
+		printVersion(*format == FormatJSON)
+		return
+	}
+
+	// completion <shell> -> Print the shell completion script
+	if len(args) == 2 && args[0] == "completion" {
+		if err := printCompletionScript(args[1]); err != nil {
+			slog.Error("cannot print the completion script", "err", err)
+			os.Exit(ExitUsage)
+		}
+		return
+	}
+
+	if Mode != ModeReadOnly && Mode != ModeReadWrite {
+		slog.Error("invalid mode", "mode", Mode, "expected", ModeReadOnly+" or "+ModeReadWrite)
+		os.Exit(ExitUsage)
+	}
+	if *stdinBatch && len(args) > 0 {
+		slog.Error("-stdin reads the queries from stdin, not the arguments")
+		os.Exit(ExitUsage)
+	}
 
 	// Resolve absolute path for the project root
-	absPath, err := filepath.Abs(*searchPath)
+	absPath, roots, err := resolveRoots(searchPaths.paths)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error resolving path: %v\n", err)
-		os.Exit(1)
+		slog.Error("cannot resolve the root path", "path", searchPaths.String(), "err", err)
+		os.Exit(ExitUsage)
+	}
+	ExtraRoots = roots
+	// The daemon only knows its own root and answers single queries
+	if *remote && *watch {
+		slog.Error("-remote cannot be combined with -watch")
+		os.Exit(ExitUsage)
+	}
+	if *remote && len(ExtraRoots) > 0 {
+		slog.Error("-remote searches a single root, not several -path")
+		os.Exit(ExitUsage)
+	}
+	for _, dir := range splitList(*sessionRootDirs) {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			slog.Error("cannot resolve the session roots", "path", dir, "err", err)
+			os.Exit(ExitUsage)
+		}
+		SessionRootDirs = append(SessionRootDirs, abs)
 	}
 
-	// If using gopls, initialize it immediately.
-	// It runs as a background process.
-	if *useGopls {
-		InitGopls(absPath)
-		defer ShutdownGopls()
+	// The environment defaults, then the config, then the explicit flags
+	IncludeUntracked, IncludeIgnored = *untracked, *ignored
+	cfg, err := LoadConfig(absPath)
+	if err != nil {
+		slog.Error("cannot load the config", "err", err)
+		os.Exit(ExitUsage)
+	}
+	cfg.Apply()
+	for _, name := range splitList(*enableTools) {
+		EnabledTools[name] = true
+	}
+	for _, name := range splitList(*disableTools) {
+		DisabledTools[name] = true
+	}
+	// An explicit flag wins over the config
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "lsp-timeout":
+			CallTimeout = *lspTimeout
+		case "lsp-concurrency":
+			MaxConcurrentCalls = *lspConcurrency
+		case "log-level":
+			LogLevel.Set(logLevel)
+		case "log-file":
+			LogFile = *logFile
+		case "audit":
+			AuditEnabled = *audit
+		case "untracked":
+			IncludeUntracked = *untracked
+		case "ignored":
+			IncludeIgnored = *ignored
+		case "max-result-bytes":
+			MaxResultBytes = *maxResultBytes
+		case "calls-per-minute":
+			CallsPerMinute = *callsPerMinute
+		case "bytes-per-minute":
+			BytesPerMinute = *bytesPerMinute
+		case "grep-backend":
+			GrepBackend = *grepBackend
+		case "linter":
+			Linter = *linter
+		}
+	})
+	if GrepBackend != GrepAuto && GrepBackend != GrepGit && GrepBackend != GrepGo {
+		slog.Error("invalid grep backend", "backend", GrepBackend, "expected", GrepAuto+", "+GrepGit+" or "+GrepGo)
+		os.Exit(ExitUsage)
+	}
+	if Linter != LinterAuto && Linter != LinterStaticcheck && Linter != LinterGolangci {
+		slog.Error("invalid linter", "linter", Linter, "expected", LinterAuto+", "+LinterStaticcheck+" or "+LinterGolangci)
+		os.Exit(ExitUsage)
+	}
+
+	// The flags and config the results depend on: the daemon and the query
+	// cache only answer for the same ones
+	settings := CacheSettings(absPath, *useGopls, *useRust)
+
+	if *socketPath == "" {
+		*socketPath = DefaultSocketPath(absPath)
+	}
+
+	// A running daemon answers CLI queries with gopls already warm,
+	// no need to start our own. It only knows its own root.
+	if len(args) > 0 && !isSubcommand(args) && !*watch && len(ExtraRoots) == 0 {
+		query := strings.Join(args, " ")
+		if *remote || daemonAvailable(*socketPath) {
+			start := time.Now()
+			resp, err := RemoteSearch(*socketPath, query, searchOpts, settings)
+			if err == nil {
+				printOutput(resp.Output, absPath, resp.Servers, time.Since(start), *format)
+				if resp.Output.Error != "" {
+					os.Exit(ExitFailure)
+				}
+				os.Exit(resultsExitCode(resp.Output.Count))
+			}
+			if *remote {
+				slog.Error("search failed", "err", err)
+				os.Exit(ExitFailure)
+			}
+		}
+	}
+
+	// A cached answer computed on the same tree, with the same config,
+	// skips gopls startup entirely.
+	fingerprint := ""
+	// A ref may move while the working tree does not: no cache
+	if *useCache && len(args) > 0 && !isSubcommand(args) && !*watch && len(ExtraRoots) == 0 && *ref == "" {
+		start := time.Now()
+		query := strings.Join(args, " ")
+		fingerprint, _ = ProjectFingerprint(context.Background(), absPath)
+		if files, ok := LoadCachedQuery(absPath, query, searchOpts, settings, fingerprint); fingerprint != "" && ok {
+			output := CLIOutput{
+				Query:    query,
+				Worktree: FindWorktree(context.Background(), absPath),
+				Duration: time.Since(start).String(),
+				Count:    len(files),
+				Files:    files,
+			}
+			printOutput(output, absPath, nil, time.Since(start), *format)
+			os.Exit(resultsExitCode(len(files)))
+		}
+	}
+
+	closeLog := SetupLogging()
+	defer closeLog()
+
+	// __complete <prefix> -> Print the project symbols for shell completion
+	if len(args) > 0 && args[0] == completeCommand {
+		printCompletions(context.Background(), absPath, strings.Join(args[1:], " "))
+		return
+	}
+
+	// Language servers (gopls, rust-analyzer...) are spawned lazily, for the
+	// languages detected in the project, on first search.
+	languages, disabled := cfg.Languages()
+	if !*useGopls {
+		disabled["go"] = true
+	}
+	if !*useRust {
+		disabled["rust"] = true
+	}
+	LSP = NewLSPManager(absPath, languages, disabled)
+	defer LSP.Shutdown()
+
+	// --status -> Report the language servers state once they are up
+	if *showStatus {
+		ctx := context.Background()
+		for _, c := range LSP.Clients(ctx) {
+			c.WaitIdle(ctx, LoadTimeout)
+		}
+		printStatus(LSP.Status(ctx), *format == FormatJSON)
+		return
+	}
+
+	// --stdin -> One query per input line, JSONL results
+	if *stdinBatch {
+		if code := runBatch(os.Stdin, absPath, searchOpts); code != ExitFound {
+			LSP.Shutdown()
+			closeLog()
+			os.Exit(code)
+		}
+		return
 	}
 
 	// No query arguments -> Run as MCP Server (stdio mode)
 	if len(args) == 0 {
+		// Long running: start the servers in the background right away
+		go LSP.Clients(context.Background())
 		runServer(absPath)
 		return
 	}
 
+	// daemon -> Keep gopls and caches warm behind a Unix socket
+	if len(args) == 1 && args[0] == "daemon" {
+		go LSP.Clients(context.Background())
+		runDaemon(absPath, *socketPath, settings)
+		return
+	}
+
 	// Query arguments present -> Run as CLI tool
 	query := strings.Join(args, " ")
-	runCLI(query, absPath, *jsonOutput)
+	if *watch {
+		runWatch(query, absPath, searchOpts, *format)
+		return
+	}
+	if code := runCLI(query, absPath, searchOpts, *format, settings, fingerprint); code != ExitFound {
+		// os.Exit skips the deferred calls
+		LSP.Shutdown()
+		closeLog()
+		os.Exit(code)
+	}
 }
 
-func runCLI(query string, absPath string, asJson bool) {
+// runCLI searches and prints the results, then returns the exit code:
+// ExitFailure when a dependency backend failed, even with results. When
+// fingerprint is not empty and every backend answered, the results are
+// stored in the query cache under it and settings.
+func runCLI(query string, absPath string, opts SearchOptions, format string, settings string, fingerprint string) int {
 	start := time.Now()
 	// Run Hybrid Search (Local AST + Gopls)
-	results, err := Search(absPath, query)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Search failed: %v\n", err)
-		os.Exit(1)
+	results, err := Search(context.Background(), absPath, query, opts, nil)
+	if err != nil && !isBackendError(err) {
+		slog.Error("search failed", "err", err)
+		return ExitFailure
 	}
 	duration := time.Since(start)
 
-	if asJson {
-		output := CLIOutput{
-			Query:    query,
-			Duration: duration.String(),
-			Count:    len(results),
-			Files:    results,
-		}
+	// Without the dependency results of a server that failed, the next run
+	// would not find what a complete search finds. Servers that are not
+	// installed fail every run the same way, and are not reported.
+	if fingerprint != "" && err == nil {
+		_ = StoreCachedQuery(absPath, query, opts, settings, fingerprint, results)
+	}
+
+	output := CLIOutput{
+		Query:    query,
+		Ref:      opts.Ref,
+		Worktree: FindWorktree(context.Background(), absPath),
+		Duration: duration.String(),
+		Count:    len(results),
+		Files:    results,
+	}
+	if err != nil {
+		slog.Error("search incomplete", "err", err)
+		output.Error = err.Error()
+	}
+	printOutput(output, absPath, LSP.Running(), duration, format)
+	if err != nil {
+		return ExitFailure
+	}
+	return resultsExitCode(len(results))
+}
+
+// printOutput renders search results on stdout in format (FormatTable...).
+// servers lists the language servers that took part in the search.
+func printOutput(output CLIOutput, absPath string, servers []string, duration time.Duration, format string) {
+	switch format {
+	case FormatJSON:
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
 		_ = enc.Encode(output)
 		return
+	case FormatJSONL:
+		printJSONL(output.Files)
+		return
+	case FormatCSV:
+		printCSV(output.Files)
+		return
+	case FormatPaths:
+		printPaths(output.Files, absPath)
+		return
 	}
 
 	// Human Readable Output
-	fmt.Printf("Searching '%s' in %s\n", query, absPath)
-	if GoplsInstance != nil {
-		fmt.Println("Gopls enabled (searching dependencies)")
+	if !Quiet {
+		fmt.Printf("Searching '%s' in %s%s\n", output.Query, absPath, worktreeLabel(output.Worktree))
+		if len(servers) > 0 {
+			fmt.Printf("%s enabled (searching dependencies)\n", strings.Join(servers, ", "))
+		}
+		fmt.Printf("Found %d files in %v\n\n", output.Count, duration)
 	}
-	fmt.Printf("Found %d files in %v\n\n", len(results), duration)
 
 	fmt.Printf("%-6s | %-25s | %s\n", "SCORE", "REASON", "FILE")
 	fmt.Println(strings.Repeat("-", 100))
 
-	for _, r := range results {
+	for _, r := range output.Files {
 		reason := ""
 		if len(r.Reasons) > 0 {
-			reason = r.Reasons[0]
+			reason = fitColumn(r.Reasons[0], reasonWidth)
 		}
 		pathDisplay := r.Path
 		if r.IsDep {
-			// Cyan color for dependencies
-			pathDisplay = fmt.Sprintf("\033[36m[DEP] %s\033[0m", r.Path)
+			pathDisplay = "[DEP] " + r.Path
+			if Color {
+				// Cyan color for dependencies
+				pathDisplay = "\033[36m" + pathDisplay + "\033[0m"
+			}
 		}
 		fmt.Printf("%-6d | %-25s | %s\n", r.Score, reason, pathDisplay)
 	}
 }
 
 // initSecurity configures the allowed paths for read_file.
-// It allows the project root, the Go Module Cache, and GOROOT.
+// It allows the search roots, the Go Module Cache, GOROOT and the dependency
+// directories of the other detected languages (e.g. the cargo registry).
 func initSecurity(rootPath string) {
-	AllowedPathPrefixes = append(AllowedPathPrefixes, rootPath)
+	AllowedPathPrefixes = append(AllowedPathPrefixes, projectReadPaths(context.Background(), rootPath, LSP)...)
+	AllowedPathPrefixes = append(AllowedPathPrefixes, ExtraRoots...)
+	// A root inside a git working tree reads the rest of it
+	if wt := FindWorktree(context.Background(), rootPath); wt != nil && wt.Root != rootPath {
+		AllowedPathPrefixes = append(AllowedPathPrefixes, wt.Root)
+	}
+	shared := len(AllowedPathPrefixes)
 
 	// Add GOMODCACHE
 	if out, err := exec.Command("go", "env", "GOMODCACHE").Output(); err == nil {
@@ -163,10 +578,18 @@ func initSecurity(rootPath string) {
 			AllowedPathPrefixes = append(AllowedPathPrefixes, path)
 		}
 	}
+
+	// Add the directories allowed by the config, and its deny patterns
+	initReadAccess(rootPath)
+
+	// The session projects only share the dependencies and read_access
+	sharedPathPrefixes = AllowedPathPrefixes[shared:]
 }
 
-// isAllowedPath checks if the target path is within one of the allowed prefixes.
-func isAllowedPath(target string) bool {
+// isAllowedPath checks if the target path is within one of the allowed prefixes
+// (those of the session project, if any) or the client roots of the MCP
+// session of ctx, and not denied by the config.
+func isAllowedPath(ctx context.Context, target string) bool {
 	absTarget, err := filepath.Abs(target)
 	if err != nil {
 		return false
@@ -174,8 +597,11 @@ func isAllowedPath(target string) bool {
 
 	// Clean the path to remove .. and double separators
 	cleanTarget := filepath.Clean(absTarget)
+	if isDeniedPath(cleanTarget) {
+		return false
+	}
 
-	for _, prefix := range AllowedPathPrefixes {
+	for _, prefix := range allowedPrefixes(ctx) {
 		// Ensure prefix allows for checking subdirectories correctly
 		// e.g. /app matches /app/foo but not /apple
 		cleanPrefix := filepath.Clean(prefix)
@@ -183,85 +609,261 @@ func isAllowedPath(target string) bool {
 			return true
 		}
 	}
-	return false
+	return isSessionRoot(ctx, cleanTarget)
+}
+
+// jsonToolResult returns a tool result with structured as its structured
+// content, matching the output schema of the tool, and text as indented JSON
+// for the clients reading the text content only.
+func jsonToolResult(structured any, text any) *mcp.CallToolResult {
+	jsonData, err := json.MarshalIndent(text, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("JSON marshaling failed: %v", err))
+	}
+	return mcp.NewToolResultStructured(structured, string(jsonData))
+}
+
+// toolEnabled reports whether the MCP tool name is exposed, according to
+// EnabledTools and DisabledTools.
+func toolEnabled(name string) bool {
+	return !DisabledTools[name] && (len(EnabledTools) == 0 || EnabledTools[name])
+}
+
+// readOnlyAnnotations returns the annotations of a tool that only reads the
+// project, so that clients may run it without asking for confirmation.
+func readOnlyAnnotations(title string) mcp.ToolOption {
+	return mcp.WithToolAnnotation(mcp.ToolAnnotation{
+		Title:           title,
+		ReadOnlyHint:    mcp.ToBoolPtr(true),
+		DestructiveHint: mcp.ToBoolPtr(false),
+		IdempotentHint:  mcp.ToBoolPtr(true),
+		OpenWorldHint:   mcp.ToBoolPtr(false),
+	})
+}
+
+// resolvePath turns a tool path argument into a file path. Relative paths are
+// joined with root, absolute paths (common from gopls) are respected.
+func resolvePath(root string, pathArg string) string {
+	if !filepath.IsAbs(pathArg) {
+		return filepath.Join(root, pathArg)
+	}
+	return pathArg
 }
 
 func runServer(rootPath string) {
 	// Initialize security boundaries
 	initSecurity(rootPath)
 
+	hooks := &server.Hooks{}
+	opts := []server.ServerOption{
+		server.WithToolCapabilities(true),
+		server.WithLogging(),
+		server.WithHooks(hooks),
+		server.WithToolHandlerMiddleware(cancelMiddleware()),
+	}
+	if AuditEnabled {
+		opts = append(opts, server.WithToolHandlerMiddleware(auditMiddleware(rootPath)))
+	}
+	opts = append(opts,
+		server.WithToolHandlerMiddleware(handleRateLimits(hooks)),
+		server.WithToolHandlerMiddleware(budgetMiddleware()),
+	)
 	s := server.NewMCPServer(
 		"Search-MCP",
-		"1.2.0",
-		server.WithToolCapabilities(true),
+		ReadBuildInfo().String(),
+		opts...,
 	)
 
+	// Log messages: stderr (or -log-file) and the clients
+	enableClientLogging(s, hooks)
+	// notifications/cancelled stops the tool call it names
+	handleCancellation(s, hooks)
+	// completion/complete, for the initialized sessions
+	completions := handleCompletions(hooks)
+
 	// Tool: search_files
 	searchTool := mcp.NewTool("search_files",
 		mcp.WithDescription("Search codebase and dependencies. Uses AST for local files and Gopls for dependencies/symbols. Always use this before read_file."),
 		mcp.WithString("query", mcp.Required(), mcp.Description("Query (e.g. 'AuthService login')")),
+		mcp.WithBoolean("include_stdlib", mcp.Description("Also return standard library symbols (e.g. how net/http implements keep-alive). Off by default.")),
+		mcp.WithString("ref", mcp.Description("Search the project as of this git ref (branch, tag or commit, e.g. v1.4) instead of the working tree, without dependencies. Read the results with read_file and the same ref.")),
+		mcp.WithNumber("offset", mcp.Description("Number of files to skip, the next_offset of a previous result too large to hold them all")),
+		mcp.WithOutputSchema[CLIOutput](),
+		readOnlyAnnotations("Search files"),
 	)
 
 	s.AddTool(searchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		query, _ := request.RequireString("query")
 		start := time.Now()
 
-		results, err := Search(rootPath, query)
-		if err != nil {
+		opts := SearchOptions{IncludeStdlib: request.GetBool("include_stdlib", false), Ref: request.GetString("ref", "")}
+		ctx, onPartial, stopProgress := progressReporter(ctx, request)
+		defer stopProgress()
+		root := projectRoot(ctx, rootPath)
+		results, err := Search(ctx, root, query, opts, onPartial)
+		if err != nil && !isBackendError(err) {
 			return mcp.NewToolResultError(fmt.Sprintf("Error: %v", err)), nil
 		}
+		if d := time.Since(start); d > slowSearch {
+			slog.Warn("slow search", "query", query, "duration", d, "results", len(results))
+		}
+		if results == nil {
+			// An empty list, not null, as the output schema requires
+			results = []FileScore{}
+		}
 
 		// Create JSON output structure
 		output := CLIOutput{
 			Query:    query,
+			Ref:      opts.Ref,
+			Worktree: FindWorktree(ctx, root),
 			Duration: time.Since(start).String(),
 			Count:    len(results),
 			Files:    results,
 		}
-
-		jsonData, err := json.MarshalIndent(output, "", "  ")
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("JSON marshaling failed: %v", err)), nil
+			// The local results stand, tell why dependencies may be missing
+			output.Error = err.Error()
 		}
+		output = pageSearch(output, request.GetInt("offset", 0))
 
-		return mcp.NewToolResultText(string(jsonData)), nil
+		return jsonToolResult(output, output), nil
 	})
 
 	// Tool: read_file
 	readTool := mcp.NewTool("read_file",
-		mcp.WithDescription("Read the full content of a file. This tool is restricted to files within the project root, the Go Module Cache, or the Go Standard Library. Use this to read files found via search_files."),
+		mcp.WithDescription("Read the full content of a file. This tool is restricted to files within the project root, the Go Module Cache, or the Go Standard Library. Use this to read files found via search_files. Large files come in parts: pass the offset given at the end of a part to read the next one."),
 		mcp.WithString("path", mcp.Required(), mcp.Description("Absolute path to the file (or relative to project root)")),
+		mcp.WithNumber("offset", mcp.Description("Byte offset to read from, given by the truncation marker of a file too large for one result")),
+		mcp.WithString("ref", mcp.Description("Read the file as of this git ref (branch, tag or commit) instead of the working tree, for the results of search_files with a ref")),
+		readOnlyAnnotations("Read file"),
 	)
 
 	s.AddTool(readTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		pathArg, _ := request.RequireString("path")
 
-		// Handle relative paths by joining with root, but respect absolute paths (common from gopls)
-		targetPath := pathArg
-		if !filepath.IsAbs(pathArg) {
-			targetPath = filepath.Join(rootPath, pathArg)
-		}
+		root := projectRoot(ctx, rootPath)
+		targetPath := resolvePath(root, pathArg)
 
 		// Security Check
-		if !isAllowedPath(targetPath) {
-			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Reading file %s is not allowed. Scope restricted to project root and Go dependencies.", pathArg)), nil
+		if !isAllowedPath(ctx, targetPath) {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Reading file %s is not allowed. Scope restricted to the search roots, the client roots, dependencies and read_access of the config.", pathArg)), nil
+		}
+
+		if pattern, ok := IsSecretFile(targetPath); ok {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: %s looks like a secret file (matches %q). Add it to secrets.allow in the config to read it.", pathArg, pattern)), nil
 		}
 
-		content, err := os.ReadFile(targetPath)
+		var content []byte
+		var err error
+		if ref := request.GetString("ref", ""); ref != "" {
+			content, err = ReadRefFile(ctx, root, ref, targetPath)
+		} else {
+			content, err = os.ReadFile(targetPath)
+		}
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
-		return mcp.NewToolResultText(string(content)), nil
+		text, n := RedactSecrets(targetPath, string(content))
+		if n > 0 {
+			slog.Debug("secrets redacted", "path", targetPath, "count", n)
+		}
+		return mcp.NewToolResultText(pageText(text, request.GetInt("offset", 0))), nil
 	})
 
-	if err := server.ServeStdio(s); err != nil {
-		os.Exit(1)
+	// Tool: outline_markdown
+	s.AddTool(outlineMarkdownTool(rootPath))
+	s.AddTool(indexStatusTool())
+	s.AddTool(statusTool(s, rootPath))
+	s.AddTool(repoMapTool(rootPath))
+	s.AddTool(packageSummaryTool(rootPath))
+	s.AddTool(dependencyGraphTool(rootPath))
+	s.AddTool(importGraphTool(rootPath))
+	s.AddTool(deadCodeTool(rootPath))
+	s.AddTool(complexityTool(rootPath))
+	s.AddTool(todosTool(rootPath))
+	s.AddTool(duplicatesTool(rootPath))
+	s.AddTool(interfaceMatrixTool(rootPath))
+	s.AddTool(vetTool(rootPath))
+	s.AddTool(lintTool(rootPath))
+	s.AddTool(gitHistoryTool(rootPath))
+	s.AddTool(gitDiffTool(rootPath))
+	s.AddTool(diffFileRefsTool(rootPath))
+	s.AddTool(searchCommitsTool(rootPath))
+	s.AddTool(symbolHistoryTool(rootPath))
+	s.AddTool(lineHistoryTool(rootPath))
+	s.AddTool(grepTool(rootPath))
+	s.AddTool(ownersTool(rootPath))
+
+	// Resources: symbol://{package}/{name}
+	s.AddResourceTemplate(symbolResourceTemplate(rootPath))
+
+	// Prompts
+	s.AddPrompt(explainSymbolPrompt(rootPath))
+	s.AddPrompt(summarizePackagePrompt(rootPath))
+	s.AddPrompt(findFeaturePrompt(rootPath))
+
+	// Tools: write_file, edit_file, apply_patch (read-write mode only)
+	s.AddTools(writeTools(rootPath)...)
+
+	// Project roots of the HTTP sessions
+	if ListenAddr != "" && len(SessionRootDirs) > 0 {
+		handleSessionProjects(hooks, rootPath)
+	}
+
+	// Workspace roots of the clients
+	if ClientRoots {
+		handleClientRoots(s, hooks)
+	}
+
+	// Tools exposed by the mode, the config, -enable-tools and
+	// -disable-tools, changed at runtime by signals and AdminEndpoint
+	known := s.ListTools()
+	for name := range EnabledTools {
+		if known[name] == nil {
+			slog.Warn("unknown tool in -enable-tools", "tool", name)
+		}
+	}
+	tools := NewToolSet(s)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	handleModeSignals(ctx, tools)
+
+	// --listen -> Serve HTTP clients instead of stdio
+	if ListenAddr != "" {
+		if err := serveHTTP(ctx, s, completions, ListenAddr, rootPath, tools); err != nil {
+			slog.Error("MCP server failed", "err", err)
+			os.Exit(ExitFailure)
+		}
+		return
+	}
+
+	// The MCP stream owns stdout: send anything else printing to it to stderr
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+
+	stdio := server.NewStdioServer(s)
+	stdio.SetErrorLogger(slog.NewLogLogger(slog.Default().Handler(), slog.LevelError))
+	in, out := stdioCompletions(ctx, completions, rootPath, os.Stdin, stdout)
+	if err := stdio.Listen(ctx, in, out); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("MCP server failed", "err", err)
+		os.Exit(ExitFailure)
 	}
 }
 
+// PartialFunc receives an intermediate batch of results while a search is
+// still running. stage identifies the producer ("local", "gopls", ...).
+type PartialFunc func(stage string, batch []FileScore)
+
 // Search runs local AST search and Gopls dependency search concurrently
 // and merges the results with deduplication.
-func Search(absRoot string, query string) ([]FileScore, error) {
+// Cancelling ctx stops both searches and returns ctx.Err().
+// If onPartial is not nil, it is called with the local hits as soon as they
+// are scored, then with the new (deduplicated) hits of each language server.
+// With opts.Ref, only the project files of the ref are searched.
+// When a dependency backend fails, the results come with a *BackendError.
+func Search(ctx context.Context, absRoot string, query string, opts SearchOptions, onPartial PartialFunc) ([]FileScore, error) {
 	var results []FileScore
 	var mu sync.Mutex
 	var wg sync.WaitGroup
@@ -272,108 +874,305 @@ func Search(absRoot string, query string) ([]FileScore, error) {
 		terms = strings.Fields(queryLower)
 	}
 
+	if opts.Ref != "" {
+		refRes, err := RefSearch(ctx, absRoot, opts.Ref, terms, queryLower)
+		if err != nil {
+			return nil, err
+		}
+		if onPartial != nil && len(refRes) > 0 {
+			onPartial("local", topResults(refRes))
+		}
+		return topResults(refRes), nil
+	}
+
+	// Closed once local results are in, so gopls hits are always merged
+	// (and streamed) after them.
+	localDone := make(chan struct{})
+	progress := searchProgress(ctx)
+
 	// Local Search (AST + Path)
 	wg.Add(1)
+	progress.start("local")
 	go func() {
 		defer wg.Done()
-		localRes, _ := LocalSearch(absRoot, terms, queryLower)
+		defer close(localDone)
+		defer progress.done("local")
+		localRes, _ := LocalSearch(ctx, absRoot, terms, queryLower)
+		if roots := extraRoots(ctx, absRoot); len(roots) > 0 {
+			for i := range localRes {
+				localRes[i].Root = absRoot
+			}
+			localRes = append(localRes, searchExtraRoots(ctx, roots, terms, queryLower)...)
+		}
 		mu.Lock()
 		results = append(results, localRes...)
 		mu.Unlock()
+		if onPartial != nil && len(localRes) > 0 && ctx.Err() == nil {
+			onPartial("local", topResults(localRes))
+		}
 	}()
 
-	// Gopls Search (Dependencies + Symbols)
-	if GoplsInstance != nil {
+	// merge adds the hits of a dependency search (a language server or the
+	// Go module cache fallback) missing from the results, once local
+	// results are in, and streams them as the stage partial batch.
+	merge := func(stage string, remote []FileScore) {
+		<-localDone
+
+		var added []FileScore
+		mu.Lock()
+		// Index the files already found (by LocalSearch or another server) on
+		// their cleaned absolute path, so each new hit is checked in O(1).
+		seen := make(map[string]bool, len(results)+len(remote))
+		for _, existing := range results {
+			seen[absResultPath(absRoot, existing.Path)] = true
+		}
+
+		// Merge results with Deduplication
+		for _, gr := range remote {
+			key := absResultPath(absRoot, gr.Path)
+			// If new, add it
+			if !seen[key] {
+				seen[key] = true
+				// If the server returns a file inside our root, make it relative
+				if strings.HasPrefix(gr.Path, absRoot) {
+					rel, _ := filepath.Rel(absRoot, gr.Path)
+					gr.Path = rel
+					gr.IsDep = false // It is actually local
+				}
+				results = append(results, gr)
+				added = append(added, gr)
+			}
+		}
+		mu.Unlock()
+		if onPartial != nil && len(added) > 0 && ctx.Err() == nil {
+			onPartial(stage, topResults(added))
+		}
+	}
+
+	// fail records a dependency search that failed, for the BackendError
+	var failures []string
+	fail := func(backend string, err error) {
+		mu.Lock()
+		failures = append(failures, backend+": "+err.Error())
+		mu.Unlock()
+	}
+
+	// Language Server Search (Dependencies + Symbols): gopls, rust-analyzer...
+	lsp := projectLSP(ctx)
+	for _, client := range lsp.Clients(ctx) {
 		wg.Add(1)
+		progress.start(client.name)
+		go func(client *LSPClient) {
+			defer wg.Done()
+			defer progress.done(client.name)
+			// Query the server for workspace symbols
+			goplsRes, err := client.SymbolSearch(ctx, query, opts)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Warn("language server search failed", "server", client.name, "err", err)
+					fail(client.name, err)
+				}
+				return
+			}
+			merge(client.name, goplsRes)
+		}(client)
+	}
+
+	// Without gopls, approximate Go dependency search from the module cache
+	if lsp.NeedsGoFallback(ctx) {
+		wg.Add(1)
+		progress.start(goDepsReason)
 		go func() {
 			defer wg.Done()
-			// Query gopls for workspace symbols
-			goplsRes, err := GoplsInstance.SymbolSearch(query)
-			if err == nil {
-				mu.Lock()
-				// Merge results with Deduplication
-				for _, gr := range goplsRes {
-					// Check if this file was already found by LocalSearch
-					isDup := false
-					for _, existing := range results {
-						// Convert existing path to absolute for comparison
-						existingAbs := existing.Path
-						if !filepath.IsAbs(existingAbs) {
-							existingAbs = filepath.Join(absRoot, existingAbs)
-						}
-
-						if existingAbs == gr.Path {
-							isDup = true
-							break
-						}
-					}
-
-					// If new, add it
-					if !isDup {
-						// If gopls returns a file inside our root, make it relative
-						if strings.HasPrefix(gr.Path, absRoot) {
-							rel, _ := filepath.Rel(absRoot, gr.Path)
-							gr.Path = rel
-							gr.IsDep = false // It is actually local
-						}
-						results = append(results, gr)
-					}
+			defer progress.done(goDepsReason)
+			depRes, err := GoDepSearch(ctx, absRoot, query)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Warn("module cache search failed", "err", err)
+					fail(goDepsReason, err)
 				}
-				mu.Unlock()
+				return
 			}
+			merge(goDepsReason, depRes)
 		}()
 	}
 
 	wg.Wait()
 
+	// Partial results are not useful to a caller that went away.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Servers that failed to start are not retried, each search reports them
+	failures = append(lsp.Failed(), failures...)
+	if len(failures) > 0 {
+		return topResults(results), &BackendError{Failures: failures}
+	}
+	return topResults(results), nil
+}
+
+// BackendError is returned by Search with its results when dependency
+// backends (language servers, module cache search) failed: the results miss
+// their hits but are otherwise complete.
+type BackendError struct {
+	Failures []string // Backend and error, e.g. "gopls: init failed: EOF"
+}
+
+func (e *BackendError) Error() string {
+	return "dependency search incomplete: " + strings.Join(e.Failures, "; ")
+}
+
+// isBackendError reports whether err is a BackendError, leaving the results
+// of Search usable.
+func isBackendError(err error) bool {
+	var backendErr *BackendError
+	return errors.As(err, &backendErr)
+}
+
+// topResults sorts results by descending score and keeps the top MaxResults.
+// The input slice is left untouched.
+func topResults(results []FileScore) []FileScore {
+	sorted := make([]FileScore, len(results))
+	copy(sorted, results)
+
 	// Final Sort by Score
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Score > sorted[j].Score
 	})
 
-	// Limit to top 50 results
-	if len(results) > 50 {
-		results = results[:50]
+	// Limit to the top MaxResults results
+	if len(sorted) > MaxResults {
+		sorted = sorted[:MaxResults]
 	}
-	return results, nil
+	return sorted
+}
+
+// absResultPath returns the cleaned absolute form of a result path.
+// Local results are relative to root while gopls results are absolute.
+func absResultPath(root string, path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	return filepath.Clean(path)
 }
 
 // LocalSearch iterates through files in root and scores them.
-func LocalSearch(root string, terms []string, queryLower string) ([]FileScore, error) {
-	files, _ := CollectFiles(root)
+// Files are partitioned into shards (see ShardFiles) searched concurrently;
+// overall at most Workers files are scored at once, in batches of
+// scoreBatchSize files per shard, yielding the processor between batches.
+// The files changed on the current branch are boosted (see BranchChanges),
+// the generated and vendored ones penalized (see PenalizeLinguist), and the
+// owners of the files are set from CODEOWNERS.
+// It stops early and returns ctx.Err() when ctx is cancelled.
+func LocalSearch(ctx context.Context, root string, terms []string, queryLower string) ([]FileScore, error) {
+	files, _ := WorkspaceFiles(ctx, root)
+	results, err := scoreFiles(ctx, root, files, terms, queryLower)
+	results = PenalizeLinguist(ctx, root, BoostChanged(results, BranchChanges(ctx, root)))
+	return SetOwners(root, results), err
+}
+
+// scoreFiles scores files, relative to root, in shards searched
+// concurrently.
+func scoreFiles(ctx context.Context, root string, files []string, terms []string, queryLower string) ([]FileScore, error) {
+	// Name-level symbols for languages without a built-in extractor
+	IndexCtags(ctx, root, files)
+	shards := ShardFiles(root, files)
+	searchProgress(ctx).addFiles(len(files))
+
+	workers := Workers
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+
 	var results []FileScore
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, sf := range shards {
+		wg.Add(1)
+		go func(sf shardFiles) {
+			defer wg.Done()
+			res := sf.shard.search(ctx, sem, root, sf.files, terms, queryLower)
+			mu.Lock()
+			results = append(results, res...)
+			mu.Unlock()
+		}(sf)
+	}
+	wg.Wait()
 
-	for _, f := range files {
-		score, reasons := ScoreFile(root, f, terms, queryLower)
-		if score > 0 {
-			results = append(results, FileScore{
-				Path:    f,
-				Score:   score,
-				Reasons: reasons,
-				IsDep:   false,
-			})
-		}
+	if err := ctx.Err(); err != nil {
+		return results, err
 	}
-	return results, nil
+	return PairHeaders(results, files), nil
 }
 
 // CollectFiles uses git ls-files if available, otherwise filepath.WalkDir.
-func CollectFiles(root string) ([]string, error) {
-	if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
-		cmd := exec.Command("git", "ls-files", "-c", "-o", "--exclude-standard")
-		cmd.Dir = root
-		out, err := cmd.Output()
+// Either way IgnoreDirs and .codemcpignore patterns are applied. The
+// untracked and ignored files are listed according to IncludeUntracked and
+// IncludeIgnored.
+func CollectFiles(ctx context.Context, root string) ([]string, error) {
+	if inGitWorkTree(root) {
+		args := []string{"ls-files", "-c"}
+		if IncludeUntracked {
+			args = append(args, "-o", "--exclude-standard")
+		}
+		out, err := runGit(ctx, root, args...)
+		if err == nil && IncludeIgnored {
+			var ignored string
+			ignored, err = runGit(ctx, root, "ls-files", "-o", "-i", "--exclude-standard")
+			out += ignored
+		}
 		if err == nil {
-			return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+			return FilterIgnored(root, strings.Split(strings.TrimSpace(out), "\n")), nil
+		}
+	}
+	// Not a git repository (or git failed): walk the tree ourselves
+	return WalkFiles(ctx, root)
+}
+
+// WorkspaceFiles returns CollectFiles for root plus the files of the go.work
+// modules outside root (e.g. "../shared/util.go"), all relative to root.
+func WorkspaceFiles(ctx context.Context, root string) ([]string, error) {
+	files, err := CollectFiles(ctx, root)
+	if err != nil {
+		return files, err
+	}
+	for _, m := range outsideModules(root) {
+		moduleFiles, err := CollectFiles(ctx, filepath.Join(root, m))
+		if err != nil {
+			continue
+		}
+		for _, f := range moduleFiles {
+			if f != "" {
+				files = append(files, m+"/"+filepath.ToSlash(f))
+			}
 		}
 	}
-	// Fallback omitted for brevity, logic exists in previous versions if needed
-	return []string{}, nil
+	return files, nil
+}
+
+// FileExtractor returns the symbol extractor of the file at relPath, by name
+// then extension. Files without a registered symbol extractor fall back to
+// ctags, if installed.
+func FileExtractor(relPath string) (func(context.Context, string) []Symbol, bool) {
+	fileName := strings.ToLower(filepath.Base(relPath))
+	if extract, ok := FileNameExtractors[fileName]; ok {
+		return extract, true
+	}
+	if extract, ok := SymbolExtractors[filepath.Ext(fileName)]; ok {
+		return extract, true
+	}
+	if CtagsAvailable() && needsCtags(relPath) {
+		return ExtractCtagsSymbols, true
+	}
+	return nil, false
 }
 
 // ScoreFile calculates the score for a single local file.
 // It combines path matching heuristics and AST content matching.
-func ScoreFile(root string, relPath string, terms []string, queryLower string) (int, []string) {
+// Extracted symbols are cached in sh, which may be nil.
+func ScoreFile(ctx context.Context, sh *Shard, root string, relPath string, terms []string, queryLower string) (int, []string) {
 	score := 0
 	reasons := []string{}
 	pathLower := strings.ToLower(relPath)
@@ -383,7 +1182,7 @@ func ScoreFile(root string, relPath string, terms []string, queryLower string) (
 	// Path Scoring
 	nameNoExt := strings.TrimSuffix(fileName, ext)
 	if nameNoExt == queryLower {
-		score += 500
+		score += Weights.ExactFile
 		reasons = append(reasons, "exact-file")
 	}
 
@@ -394,14 +1193,13 @@ func ScoreFile(root string, relPath string, terms []string, queryLower string) (
 		}
 	}
 	if matches > 0 && matches == len(terms) {
-		score += 50
+		score += Weights.PathMatch
 	}
 
 	// AST Scoring (Content)
-	// Only parse .go files. We skip this step if the file is not Go.
-	if ext == ".go" {
+	if extract, ok := FileExtractor(relPath); ok {
 		absPath := filepath.Join(root, relPath)
-		astScore, astReasons := AnalyzeGoFile(absPath, terms)
+		astScore, astReasons := ScoreSymbols(sh.Symbols(ctx, absPath, extract), terms)
 		if astScore > 0 {
 			score += astScore
 			reasons = append(reasons, astReasons...)
@@ -415,6 +1213,21 @@ func ScoreFile(root string, relPath string, terms []string, queryLower string) (
 		}
 	}
 
+	// Boost or penalty of the path_weights rules matching the path. A
+	// penalized file stays in the results, ranked last.
+	if score > 0 {
+		if w, pathReasons := scorePath(relPath); len(pathReasons) > 0 {
+			score = max(1, score+w)
+			reasons = append(reasons, pathReasons...)
+		}
+	}
+
+	// Penalty: Go files the build constraints exclude (other GOOS, tags...)
+	if score > 0 && ext == ".go" && GoFileExcluded(filepath.Join(root, relPath)) {
+		score /= buildExcludedPenalty
+		reasons = append(reasons, "build-excluded")
+	}
+
 	return score, reasons
 }
 
@@ -440,41 +1253,53 @@ func Tokenize(input string) []string {
 	return tokens
 }
 
-// AnalyzeGoFile parses a Go file's AST to find matching function or type definitions.
-func AnalyzeGoFile(absPath string, terms []string) (int, []string) {
+// Symbol is a definition extracted from a source file.
+type Symbol struct {
+	Kind string // Used as the reason prefix, e.g. "func" or "type"
+	Name string
+}
+
+// ExtractGoSymbols parses a Go file and returns its function and type definitions.
+// It returns nil when ctx is already cancelled or the file does not parse.
+func ExtractGoSymbols(ctx context.Context, absPath string) []Symbol {
+	if ctx.Err() != nil {
+		return nil
+	}
 	fset := token.NewFileSet()
 	// Parse only comments and top-level declarations (SkipObjectResolution)
 	// This makes parsing very fast as we don't need full type checking.
 	node, err := parser.ParseFile(fset, absPath, nil, parser.SkipObjectResolution|parser.ParseComments)
 	if err != nil {
-		return 0, nil
+		return nil
 	}
 
-	score := 0
-	var matched []string
-
+	var symbols []Symbol
 	ast.Inspect(node, func(n ast.Node) bool {
 		switch x := n.(type) {
 		case *ast.FuncDecl:
-			// Match function names
-			name := strings.ToLower(x.Name.Name)
-			for _, t := range terms {
-				if strings.Contains(name, t) {
-					score += 40
-					matched = append(matched, "func:"+x.Name.Name)
-				}
-			}
+			symbols = append(symbols, Symbol{Kind: "func", Name: x.Name.Name})
 		case *ast.TypeSpec:
-			// Match struct/interface names
-			name := strings.ToLower(x.Name.Name)
-			for _, t := range terms {
-				if strings.Contains(name, t) {
-					score += 40
-					matched = append(matched, "type:"+x.Name.Name)
-				}
-			}
+			// Struct/interface names
+			symbols = append(symbols, Symbol{Kind: "type", Name: x.Name.Name})
 		}
 		return true
 	})
+	return symbols
+}
+
+// ScoreSymbols adds Weights.Symbol for every (symbol, term) pair where the
+// symbol name contains the term, and returns the matching "kind:Name" reasons.
+func ScoreSymbols(symbols []Symbol, terms []string) (int, []string) {
+	score := 0
+	var matched []string
+	for _, sym := range symbols {
+		name := strings.ToLower(sym.Name)
+		for _, t := range terms {
+			if strings.Contains(name, t) {
+				score += Weights.Symbol
+				matched = append(matched, sym.Kind+":"+sym.Name)
+			}
+		}
+	}
 	return score, matched
 }
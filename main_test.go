@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunCLIFailingServer(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "handler.go"), []byte("package x\n\nfunc Handler() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The server exits before answering the initialize request
+	saved := LSP
+	LSP = NewLSPManager(root, []Language{{Name: "broken", Command: []string{"false"}, Extensions: []string{".go"}}}, nil)
+	t.Cleanup(func() {
+		LSP.Shutdown()
+		LSP = saved
+	})
+
+	if code := runCLI("Handler", root, SearchOptions{}, FormatPaths, "", ""); code != ExitFailure {
+		t.Errorf("exit code = %d, want %d", code, ExitFailure)
+	}
+}
+
+func TestRunCLIMissingServer(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "handler.go"), []byte("package x\n\nfunc Handler() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Not installed servers do not fail the search
+	saved := LSP
+	LSP = NewLSPManager(root, []Language{{Name: "missing", Command: []string{"codemcp-no-such-server"}, Extensions: []string{".go"}}}, nil)
+	t.Cleanup(func() {
+		LSP.Shutdown()
+		LSP = saved
+	})
+
+	if code := runCLI("Handler", root, SearchOptions{}, FormatPaths, "", ""); code != ExitFound {
+		t.Errorf("exit code = %d, want %d", code, ExitFound)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Heading is a node of a markdown outline.
+type Heading struct {
+	Level    int        `json:"level"`
+	Title    string     `json:"title"`
+	Line     int        `json:"line"` // 1-based line of the heading
+	Children []*Heading `json:"children,omitempty"`
+}
+
+// MarkdownOutline is the structured content of the outline_markdown tool.
+type MarkdownOutline struct {
+	Path     string     `json:"path"`
+	Headings []*Heading `json:"headings"`
+}
+
+// markdownOutlineSchema is the output schema of outline_markdown, written by
+// hand since the reflected one cannot describe the recursive Heading.
+const markdownOutlineSchema = `{
+  "type": "object",
+  "properties": {
+    "path": {"type": "string"},
+    "headings": {"type": "array", "items": {"$ref": "#/$defs/heading"}}
+  },
+  "required": ["path", "headings"],
+  "$defs": {
+    "heading": {
+      "type": "object",
+      "properties": {
+        "level": {"type": "integer"},
+        "title": {"type": "string"},
+        "line": {"type": "integer"},
+        "children": {"type": "array", "items": {"$ref": "#/$defs/heading"}}
+      },
+      "required": ["level", "title", "line"]
+    }
+  }
+}`
+
+var (
+	atxHeadingRe = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	setextRe     = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	fenceRe      = regexp.MustCompile("^ {0,3}(```|~~~)")
+)
+
+// OutlineMarkdown returns the heading tree of a markdown document.
+// ATX (# Title) and setext (Title + ===/---) headings are recognized,
+// headings inside fenced code blocks are ignored.
+func OutlineMarkdown(content string) []*Heading {
+	var roots []*Heading
+	var stack []*Heading // Open headings, by increasing level
+
+	add := func(h *Heading) {
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, h)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, h)
+		}
+		stack = append(stack, h)
+	}
+
+	lines := strings.Split(content, "\n")
+	fence := ""
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			switch fence {
+			case "":
+				fence = m[1]
+			case m[1]:
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		if m := atxHeadingRe.FindStringSubmatch(line); m != nil {
+			add(&Heading{Level: len(m[1]), Title: strings.TrimSpace(m[2]), Line: i + 1})
+			continue
+		}
+		// Setext: a non-blank paragraph line underlined by === or ---
+		if i+1 < len(lines) && strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "    ") {
+			if m := setextRe.FindStringSubmatch(strings.TrimRight(lines[i+1], "\r")); m != nil {
+				level := 2
+				if m[1][0] == '=' {
+					level = 1
+				}
+				add(&Heading{Level: level, Title: strings.TrimSpace(line), Line: i + 1})
+			}
+		}
+	}
+	return roots
+}
+
+// outlineMarkdownTool returns the outline_markdown tool and its handler.
+func outlineMarkdownTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("outline_markdown",
+		mcp.WithDescription("Return the heading tree (with 1-based line numbers) of a markdown file. Use it to navigate documentation section by section instead of reading whole files."),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Absolute path to the markdown file (or relative to project root)")),
+		mcp.WithRawOutputSchema(json.RawMessage(markdownOutlineSchema)),
+		readOnlyAnnotations("Outline markdown"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		pathArg, _ := request.RequireString("path")
+		targetPath := resolvePath(rootPath, pathArg)
+
+		// Security Check
+		if !isAllowedPath(ctx, targetPath) {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Reading file %s is not allowed.", pathArg)), nil
+		}
+
+		content, err := os.ReadFile(targetPath)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		headings := OutlineMarkdown(string(content))
+		if headings == nil {
+			headings = []*Heading{}
+		}
+		// The text stays the bare tree, as before the structured content
+		return jsonToolResult(MarkdownOutline{Path: pathArg, Headings: headings}, headings), nil
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// methodComplete is the MCP request completing the arguments of the prompts
+// and resource templates. mcp-go (v0.43) has no handler for it nor a hook
+// able to answer a request, so the stdio and streamable HTTP transports
+// answer it before the server sees it, see completer.
+const methodComplete = "completion/complete"
+
+// maxCompleteValues is the maximum number of values of a completion result.
+const maxCompleteValues = 100
+
+// CompleteArgument returns the values starting with value of the argument
+// name: project files for path, Go package directories for package, project
+// symbols for symbol and name. A lower case value matches any case.
+func CompleteArgument(ctx context.Context, root string, name string, value string) []string {
+	var candidates []string
+	switch name {
+	case "path":
+		candidates, _ = WorkspaceFiles(ctx, root)
+		for i, f := range candidates {
+			candidates[i] = filepath.ToSlash(f)
+		}
+	case "package":
+		files, _ := WorkspaceFiles(ctx, root)
+		seen := make(map[string]bool)
+		for _, f := range files {
+			if strings.HasSuffix(f, ".go") && !seen[path.Dir(filepath.ToSlash(f))] {
+				seen[path.Dir(filepath.ToSlash(f))] = true
+				candidates = append(candidates, path.Dir(filepath.ToSlash(f)))
+			}
+		}
+		sort.Strings(candidates)
+	case "symbol", "name":
+		candidates, _ = ProjectSymbols(ctx, root)
+	}
+	return matchPrefix(candidates, value)
+}
+
+// rpcMessage holds the fields of a JSON-RPC message the transports look at.
+type rpcMessage struct {
+	ID     mcp.RequestId   `json:"id"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// completer answers the completion/complete requests like the MCP server
+// would: for the sessions it initialized only, running its hooks.
+type completer struct {
+	hooks    *server.Hooks
+	sessions sync.Map // IDs of the initialized sessions
+}
+
+// handleCompletions returns the completer of the MCP server with hooks,
+// tracking its sessions.
+func handleCompletions(hooks *server.Hooks) *completer {
+	c := &completer{hooks: hooks}
+	hooks.AddAfterInitialize(func(ctx context.Context, _ any, _ *mcp.InitializeRequest, _ *mcp.InitializeResult) {
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			c.sessions.Store(session.SessionID(), true)
+		}
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		c.sessions.Delete(session.SessionID())
+	})
+	return c
+}
+
+// complete returns the response to msg when it is a completion/complete
+// request of the initialized session sessionID, completing against the
+// project at root. Other messages are left to the MCP server, which rejects
+// the requests of unknown sessions.
+func (c *completer) complete(ctx context.Context, sessionID string, root string, msg []byte) ([]byte, bool) {
+	var m rpcMessage
+	if !bytes.Contains(msg, []byte(methodComplete)) || json.Unmarshal(msg, &m) != nil || m.Method != methodComplete {
+		return nil, false
+	}
+	if _, ok := c.sessions.Load(sessionID); !ok {
+		return nil, false
+	}
+	for _, hook := range c.hooks.OnBeforeAny {
+		hook(ctx, m.ID, methodComplete, m.Params)
+	}
+	var params mcp.CompleteParams
+	if err := json.Unmarshal(m.Params, &params); err != nil {
+		for _, hook := range c.hooks.OnError {
+			hook(ctx, m.ID, methodComplete, m.Params, err)
+		}
+		response, _ := json.Marshal(mcp.NewJSONRPCError(m.ID, mcp.INVALID_PARAMS, err.Error(), nil))
+		return response, true
+	}
+
+	values := CompleteArgument(ctx, root, params.Argument.Name, params.Argument.Value)
+	var result mcp.CompleteResult
+	result.Completion.Values = values[:min(len(values), maxCompleteValues)]
+	result.Completion.Total = len(values)
+	result.Completion.HasMore = len(values) > maxCompleteValues
+	for _, hook := range c.hooks.OnSuccess {
+		hook(ctx, m.ID, methodComplete, &params, &result)
+	}
+	response, _ := json.Marshal(mcp.NewJSONRPCResultResponse(m.ID, result))
+	return response, true
+}
+
+// advertiseCompletions adds the completions capability to msg when it is the
+// response to initialize, which mcp-go does not know about.
+func advertiseCompletions(msg []byte) []byte {
+	if !bytes.Contains(msg, []byte(`"serverInfo"`)) {
+		return msg
+	}
+	var response map[string]json.RawMessage
+	var result map[string]json.RawMessage
+	var capabilities map[string]json.RawMessage
+	if json.Unmarshal(msg, &response) != nil ||
+		json.Unmarshal(response["result"], &result) != nil ||
+		json.Unmarshal(result["capabilities"], &capabilities) != nil || capabilities == nil {
+		return msg
+	}
+	capabilities["completions"] = json.RawMessage(`{}`)
+	result["capabilities"], _ = json.Marshal(capabilities)
+	response["result"], _ = json.Marshal(result)
+	out, err := json.Marshal(response)
+	if err != nil {
+		return msg
+	}
+	if bytes.HasSuffix(msg, []byte("\n")) {
+		out = append(out, '\n')
+	}
+	return out
+}
+
+// completionWriter serializes the writes of the stdio messages, each written
+// at once, advertising the completions capability.
+type completionWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (c *completionWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.w.Write(advertiseCompletions(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// stdioCompletions returns the streams to serve stdio with: the requests of
+// in and the responses to out, minus the completion/complete requests c
+// answers.
+func stdioCompletions(ctx context.Context, c *completer, root string, in io.Reader, out io.Writer) (io.Reader, io.Writer) {
+	w := &completionWriter{w: out}
+	pr, pw := io.Pipe()
+	go func() {
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				// The session of the stdio transport of mcp-go
+				if response, ok := c.complete(ctx, "stdio", root, line); ok {
+					_, _ = w.Write(append(response, '\n'))
+				} else if _, err := pw.Write(line); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr, w
+}
+
+// completeHTTP wraps the streamable HTTP handler next to answer the
+// completion/complete requests of a session with c, on its project, and
+// advertise the capability.
+func completeHTTP(c *completer, root string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sessionID := r.Header.Get(server.HeaderKeySessionID)
+		sessionRoot := root
+		if p, ok := sessionProjects.Load(sessionID); ok {
+			sessionRoot = p.(*Project).Root
+		}
+		if response, ok := c.complete(r.Context(), sessionID, sessionRoot, body); ok {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(response)
+			return
+		}
+
+		var m rpcMessage
+		if json.Unmarshal(body, &m) != nil || m.Method != string(mcp.MethodInitialize) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		out := rec.body.Bytes()
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+			// One event per message, data on a single line
+			lines := bytes.Split(out, []byte("\n"))
+			for i, line := range lines {
+				if data, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+					lines[i] = append([]byte("data: "), advertiseCompletions(data)...)
+				}
+			}
+			out = bytes.Join(lines, []byte("\n"))
+		} else {
+			out = advertiseCompletions(out)
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(out)
+	})
+}
+
+// responseRecorder buffers a response, so it can be rewritten.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) { r.status = status }
+
+func (r *responseRecorder) Write(p []byte) (int, error) { return r.body.Write(p) }
+
+// Flush ignores the flushes of the wrapped handler until the response is
+// complete.
+func (r *responseRecorder) Flush() {}
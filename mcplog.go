@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// clientLoggerName is the logger of the log messages sent to MCP clients.
+const clientLoggerName = "codemcp"
+
+// slowSearch is the duration past which a search_files call is logged as
+// slow.
+const slowSearch = 5 * time.Second
+
+// clientLogHandler is a slog.Handler sending the records to the MCP clients
+// as notifications/message, at or above the level each session set with
+// logging/setLevel (error by default), on top of next.
+type clientLogHandler struct {
+	next     slog.Handler
+	server   *server.MCPServer
+	sessions *sync.Map // Session IDs of the connected clients
+	attrs    []slog.Attr
+	group    string // Prefix of the attribute keys, "a.b." for WithGroup("a").WithGroup("b")
+}
+
+// enableClientLogging makes the default logger also send its records to the
+// clients of s.
+func enableClientLogging(s *server.MCPServer, hooks *server.Hooks) {
+	sessions := &sync.Map{}
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		sessions.Store(session.SessionID(), true)
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		sessions.Delete(session.SessionID())
+	})
+	slog.SetDefault(slog.New(&clientLogHandler{next: slog.Default().Handler(), server: s, sessions: sessions}))
+}
+
+// Enabled reports true for every level: a client may ask for debug messages
+// while the local log only keeps info.
+func (h *clientLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+func (h *clientLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	if h.next.Enabled(ctx, r.Level) {
+		err = h.next.Handle(ctx, r)
+	}
+
+	data := map[string]any{"msg": r.Message}
+	for _, a := range h.attrs {
+		data[a.Key] = attrValue(a.Value)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		data[h.group+a.Key] = attrValue(a.Value)
+		return true
+	})
+	notification := mcp.NewLoggingMessageNotification(mcpLogLevel(r.Level), clientLoggerName, data)
+	h.sessions.Range(func(id, _ any) bool {
+		// Filtered on the level of the session, never blocks
+		_ = h.server.SendLogMessageToSpecificClient(id.(string), notification)
+		return true
+	})
+	return err
+}
+
+func (h *clientLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.next = h.next.WithAttrs(attrs)
+	c.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		c.attrs = append(c.attrs, slog.Attr{Key: h.group + a.Key, Value: a.Value})
+	}
+	return &c
+}
+
+func (h *clientLogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.next = h.next.WithGroup(name)
+	c.group = h.group + name + "."
+	return &c
+}
+
+// attrValue returns the JSON friendly form of v: errors and durations as
+// strings.
+func attrValue(v slog.Value) any {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+	}
+	return v.Any()
+}
+
+// mcpLogLevel maps a slog level to the closest MCP logging level.
+func mcpLogLevel(level slog.Level) mcp.LoggingLevel {
+	switch {
+	case level >= slog.LevelError:
+		return mcp.LoggingLevelError
+	case level >= slog.LevelWarn:
+		return mcp.LoggingLevelWarning
+	case level >= slog.LevelInfo:
+		return mcp.LoggingLevelInfo
+	}
+	return mcp.LoggingLevelDebug
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ModuleGraph is the result of the dependency_graph tool: the build list of
+// the main module, with the requirement edges between its modules.
+type ModuleGraph struct {
+	Main      string        `json:"main"`
+	GoVersion string        `json:"go_version,omitempty"`
+	Modules   []GraphModule `json:"modules"`
+}
+
+// GraphModule is a module of the build list, at its selected version.
+type GraphModule struct {
+	Path       string   `json:"path"`
+	Version    string   `json:"version,omitempty"`
+	Direct     bool     `json:"direct,omitempty"`  // Required by go.mod without // indirect
+	Replace    string   `json:"replace,omitempty"` // path@version, or the directory of a local replacement
+	GoVersion  string   `json:"go_version,omitempty"`
+	Requires   []string `json:"requires,omitempty"`    // path@version required by this module's go.mod
+	RequiredBy []string `json:"required_by,omitempty"` // Modules of the build list requiring it
+}
+
+// DependencyGraph returns the module graph of the module at root, from go
+// list -m -json all (versions and replacements) and go mod graph (edges).
+// With filter, only the modules whose path contains it are returned.
+func DependencyGraph(ctx context.Context, root string, filter string) (*ModuleGraph, error) {
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		return nil, errors.New("no go.mod in the project root")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	list, err := goCommand(ctx, root, "list", "-m", "-json", "all")
+	if err != nil {
+		return nil, err
+	}
+	edges, err := goCommand(ctx, root, "mod", "graph")
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &ModuleGraph{Modules: []GraphModule{}}
+	var modules []*GraphModule
+	selected := make(map[string]*GraphModule) // By go mod graph node: path@version, path for the main module
+	dec := json.NewDecoder(bytes.NewReader(list))
+	for dec.More() {
+		var mod struct {
+			Path      string
+			Version   string
+			Main      bool
+			Indirect  bool
+			GoVersion string
+			Replace   *struct {
+				Path    string
+				Version string
+			}
+		}
+		if err := dec.Decode(&mod); err != nil {
+			return nil, err
+		}
+		if mod.Main {
+			if graph.Main == "" {
+				graph.Main, graph.GoVersion = mod.Path, mod.GoVersion
+			}
+			selected[mod.Path] = &GraphModule{Path: mod.Path}
+			continue
+		}
+		m := &GraphModule{Path: mod.Path, Version: mod.Version, Direct: !mod.Indirect, GoVersion: mod.GoVersion}
+		if r := mod.Replace; r != nil {
+			m.Replace = r.Path
+			if r.Version != "" {
+				m.Replace += "@" + r.Version
+			}
+		}
+		modules = append(modules, m)
+		selected[mod.Path+"@"+mod.Version] = m
+	}
+
+	// <from> <to> per line; only the edges from modules of the build list
+	// count, the others come from versions MVS did not select
+	for _, line := range strings.Split(string(edges), "\n") {
+		from, to, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		parent, ok := selected[from]
+		if !ok {
+			continue
+		}
+		if parent.Version != "" {
+			parent.Requires = append(parent.Requires, to)
+		}
+		if child, ok := selected[to]; ok {
+			child.RequiredBy = append(child.RequiredBy, from)
+		}
+	}
+
+	for _, m := range modules {
+		if filter == "" || strings.Contains(m.Path, filter) {
+			graph.Modules = append(graph.Modules, *m)
+		}
+	}
+	return graph, nil
+}
+
+// goCommand runs the go command in root with the build configuration,
+// returning its standard output or its error message.
+func goCommand(ctx context.Context, root string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), Build.Env()...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("go %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("go %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// dependencyGraphTool returns the module dependency graph of the project.
+func dependencyGraphTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("dependency_graph",
+		mcp.WithDescription("Return the Go module dependencies of the project: every module of the build list with its selected version, whether go.mod requires it directly, its replacement, the modules it requires and the modules requiring it. Use it to answer upgrade questions: why a module is needed, which version is used, what a bump would pull in."),
+		mcp.WithString("module", mcp.Description("Only return the modules whose path contains this string, e.g. golang.org/x/net")),
+		mcp.WithOutputSchema[ModuleGraph](),
+		readOnlyAnnotations("Module dependency graph"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		graph, err := DependencyGraph(ctx, rootPath, request.GetString("module", ""))
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("dependency graph failed: %v", err)), nil
+		}
+		return jsonToolResult(graph, graph), nil
+	}
+}
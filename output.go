@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Output formats of the CLI, selected with --format.
+const (
+	FormatTable = "table" // Aligned columns for humans
+	FormatJSON  = "json"  // The whole CLIOutput, indented
+	FormatJSONL = "jsonl" // One FileScore object per line
+	FormatCSV   = "csv"   // score,path,is_dependency,reasons with a header
+	FormatPaths = "paths" // Bare file paths, for xargs or an editor
+)
+
+// Exit codes of the CLI, so scripts and git hooks can branch on the outcome.
+const (
+	ExitFound     = 0 // The query matched at least one file
+	ExitNoResults = 1 // The query matched nothing
+	ExitUsage     = 2 // Invalid flags, arguments or configuration
+	ExitFailure   = 3 // The search backend (daemon, MCP server...) failed
+)
+
+// resultsExitCode returns the exit code of a search that found count files.
+func resultsExitCode(count int) int {
+	if count == 0 {
+		return ExitNoResults
+	}
+	return ExitFound
+}
+
+// Formats lists the valid --format values.
+var Formats = []string{FormatTable, FormatJSON, FormatJSONL, FormatCSV, FormatPaths}
+
+// Quiet suppresses the banner lines of the table output.
+// Set via the --quiet flag or CODEMCP_QUIET.
+var Quiet bool
+
+// Color enables the ANSI colors of the table output. It is disabled when
+// NO_COLOR is set or stdout is not a terminal.
+var Color = os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+
+// reasonWidth is the width of the REASON column of the table output.
+const reasonWidth = 25
+
+// isTerminal reports whether f is a character device, e.g. a terminal rather
+// than a pipe or a file.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// fitColumn truncates s to width runes, so the next columns stay aligned.
+func fitColumn(s string, width int) string {
+	if r := []rune(s); len(r) > width {
+		return string(r[:width-1]) + "…"
+	}
+	return s
+}
+
+// validFormat reports whether format is one of Formats.
+func validFormat(format string) bool {
+	return slices.Contains(Formats, format)
+}
+
+// printJSONL prints one result per line.
+func printJSONL(files []FileScore) {
+	enc := json.NewEncoder(os.Stdout)
+	for _, f := range files {
+		_ = enc.Encode(f)
+	}
+}
+
+// printCSV prints the results as CSV, reasons separated by ';'.
+func printCSV(files []FileScore) {
+	w := csv.NewWriter(os.Stdout)
+	_ = w.Write([]string{"score", "path", "is_dependency", "reasons"})
+	for _, f := range files {
+		_ = w.Write([]string{strconv.Itoa(f.Score), f.Path, strconv.FormatBool(f.IsDep), strings.Join(f.Reasons, ";")})
+	}
+	w.Flush()
+}
+
+// printPaths prints the path of each result, relative to the current
+// directory when below it, absolute otherwise, so they can be opened from
+// the shell whatever the -path.
+func printPaths(files []FileScore, absPath string) {
+	cwd, _ := os.Getwd()
+	for _, f := range files {
+		p := f.Path
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(absPath, p)
+		}
+		if rel, err := filepath.Rel(cwd, p); cwd != "" && err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			p = rel
+		}
+		fmt.Println(p)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// CodeownersFiles are the locations of the CODEOWNERS file, relative to the
+// project root, in the order GitHub looks them up: the first found is used.
+var CodeownersFiles = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// ownersRule is a CODEOWNERS line: a gitignore-style pattern and its owners.
+type ownersRule struct {
+	pattern string
+	rule    ignoreRule
+	owners  []string // Empty: the matching files have no owner
+}
+
+// Codeowners is a parsed CODEOWNERS file.
+type Codeowners struct {
+	Path  string // Relative to the root
+	rules []ownersRule
+}
+
+// FileOwners is the result of the owners tool.
+type FileOwners struct {
+	Path   string   `json:"path"`
+	Owners []string `json:"owners"`         // Users (@name), teams (@org/team) or emails
+	Rule   string   `json:"rule,omitempty"` // CODEOWNERS pattern deciding the owners
+	Source string   `json:"source"`         // CODEOWNERS file, relative to the project root
+}
+
+// codeownersCache keeps the parsed CODEOWNERS of each root until the file
+// changes.
+var codeownersCache = struct {
+	sync.Mutex
+	entries map[string]codeownersEntry
+}{entries: make(map[string]codeownersEntry)}
+
+type codeownersEntry struct {
+	path    string
+	modTime time.Time
+	owners  *Codeowners
+}
+
+// LoadCodeowners returns the CODEOWNERS of root, or nil when it has none.
+func LoadCodeowners(root string) *Codeowners {
+	for _, name := range CodeownersFiles {
+		absPath := filepath.Join(root, filepath.FromSlash(name))
+		info, err := os.Stat(absPath)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		codeownersCache.Lock()
+		defer codeownersCache.Unlock()
+		if e, ok := codeownersCache.entries[root]; ok && e.path == name && e.modTime.Equal(info.ModTime()) {
+			return e.owners
+		}
+		owners, err := parseCodeowners(absPath)
+		if err != nil {
+			return nil
+		}
+		owners.Path = name
+		codeownersCache.entries[root] = codeownersEntry{path: name, modTime: info.ModTime(), owners: owners}
+		return owners
+	}
+	return nil
+}
+
+// parseCodeowners reads the CODEOWNERS file at absPath.
+func parseCodeowners(absPath string) (*Codeowners, error) {
+	f, err := os.Open(absPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &Codeowners{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Negations (!) are not supported by CODEOWNERS
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "!") {
+			continue
+		}
+		rule, ok := parseIgnoreLine(fields[0], "")
+		if !ok {
+			continue
+		}
+		var owners []string
+		for _, owner := range fields[1:] {
+			if strings.HasPrefix(owner, "#") {
+				break
+			}
+			owners = append(owners, owner)
+		}
+		c.rules = append(c.rules, ownersRule{pattern: fields[0], rule: rule, owners: owners})
+	}
+	return c, scanner.Err()
+}
+
+// Owners returns the owners of relPath (relative to the root, slash
+// separated), a directory with isDir, and the pattern of the last matching
+// rule, which wins.
+func (c *Codeowners) Owners(relPath string, isDir bool) ([]string, string) {
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		if r := c.rules[i]; r.matches(relPath, isDir) {
+			return r.owners, r.pattern
+		}
+	}
+	return nil, ""
+}
+
+// matches reports whether the rule matches relPath or one of its parent
+// directories. As on GitHub, "dir/*" only matches the files directly in dir.
+func (r ownersRule) matches(relPath string, isDir bool) bool {
+	if (isDir || !r.rule.dirOnly) && r.rule.re.MatchString(relPath) {
+		return true
+	}
+	if strings.HasSuffix(r.pattern, "/*") {
+		return false
+	}
+	for dir := path.Dir(relPath); dir != "."; dir = path.Dir(dir) {
+		if r.rule.re.MatchString(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetOwners fills the Owners of the results of LocalSearch in root from its
+// CODEOWNERS.
+func SetOwners(root string, results []FileScore) []FileScore {
+	c := LoadCodeowners(root)
+	if c == nil {
+		return results
+	}
+	for i, r := range results {
+		results[i].Owners, _ = c.Owners(filepath.ToSlash(r.Path), false)
+	}
+	return results
+}
+
+// ownersTool returns the owners of a file from the CODEOWNERS of the
+// project.
+func ownersTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("owners",
+		mcp.WithDescription("Return the owners (users, teams or emails) of a file or directory from the CODEOWNERS file of the project. Use it to tell the user who to consult about code you found."),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Absolute path to the file or directory (or relative to project root)")),
+		mcp.WithOutputSchema[FileOwners](),
+		readOnlyAnnotations("Code owners"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		pathArg, err := request.RequireString("path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		rootPath := projectRoot(ctx, rootPath)
+		targetPath := resolvePath(rootPath, pathArg)
+		rel, err := filepath.Rel(rootPath, targetPath)
+		if err != nil || !filepath.IsLocal(rel) {
+			return mcp.NewToolResultError(fmt.Sprintf("%s is outside the project root %s", pathArg, rootPath)), nil
+		}
+
+		c := LoadCodeowners(rootPath)
+		if c == nil {
+			return mcp.NewToolResultError(fmt.Sprintf("No CODEOWNERS file in the project (looked for %s)", strings.Join(CodeownersFiles, ", "))), nil
+		}
+		result := FileOwners{Path: filepath.ToSlash(rel), Source: c.Path}
+		info, err := os.Stat(targetPath)
+		result.Owners, result.Rule = c.Owners(result.Path, err == nil && info.IsDir())
+		if result.Owners == nil {
+			// An empty list, not null, as the output schema requires
+			result.Owners = []string{}
+		}
+		return jsonToolResult(result, result), nil
+	}
+}
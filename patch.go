@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FilePatch is the part of a unified diff changing one file.
+type FilePatch struct {
+	OldPath string // "" for a created file
+	NewPath string // "" for a deleted file
+	Hunks   []Hunk
+}
+
+// Hunk is a "@@ -l,c +l,c @@" section of a FilePatch.
+type Hunk struct {
+	OldStart int      // 1-based line of the hunk in the original file
+	Lines    []string // Diff lines, prefixed by ' ', '-' or '+'
+}
+
+// patchLookaround is how far from its stated line a hunk is searched for,
+// when the file drifted since the diff was made.
+const patchLookaround = 50
+
+// ParsePatch parses a unified diff (as produced by diff -u or git diff).
+// Paths lose their a/ and b/ prefixes; /dev/null becomes "".
+func ParsePatch(patch string) ([]FilePatch, error) {
+	var files []FilePatch
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			files = append(files, FilePatch{
+				OldPath: patchPath(line[4:], "a/"),
+				NewPath: patchPath(lines[i+1][4:], "b/"),
+			})
+			i++
+		case strings.HasPrefix(line, "@@ "):
+			if len(files) == 0 {
+				return nil, fmt.Errorf("line %d: hunk before file header", i+1)
+			}
+			start, oldCount, newCount, err := hunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			h := Hunk{OldStart: start}
+			// The header counts tell where the hunk ends
+			for oldCount > 0 || newCount > 0 {
+				i++
+				if i >= len(lines) {
+					return nil, fmt.Errorf("line %d: truncated hunk", i)
+				}
+				l := lines[i]
+				if l == "" {
+					l = " " // Context line whose leading space was stripped by an editor
+				}
+				switch l[0] {
+				case ' ':
+					oldCount--
+					newCount--
+				case '-':
+					oldCount--
+				case '+':
+					newCount--
+				case '\\':
+					continue // "\ No newline at end of file"
+				default:
+					return nil, fmt.Errorf("line %d: unexpected %q in hunk", i+1, l)
+				}
+				h.Lines = append(h.Lines, l)
+			}
+			cur := &files[len(files)-1]
+			cur.Hunks = append(cur.Hunks, h)
+		}
+		// Anything else ("diff --git", "index"...) is ignored
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file header (--- a/path, +++ b/path) found")
+	}
+	return files, nil
+}
+
+// patchPath cleans a path of a diff header.
+func patchPath(header string, prefix string) string {
+	// Drop the timestamp of diff -u headers
+	if i := strings.IndexByte(header, '\t'); i >= 0 {
+		header = header[:i]
+	}
+	header = strings.TrimSpace(header)
+	if header == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(header, prefix)
+}
+
+// hunkHeader parses a "@@ -l,c +l,c @@" header. Counts default to 1.
+func hunkHeader(header string) (start int, oldCount int, newCount int, err error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0, fmt.Errorf("invalid hunk header %q", header)
+	}
+	rangeOf := func(r string) (int, int, error) {
+		l, c, found := strings.Cut(r, ",")
+		line, err := strconv.Atoi(l)
+		if err != nil {
+			return 0, 0, err
+		}
+		count := 1
+		if found {
+			if count, err = strconv.Atoi(c); err != nil {
+				return 0, 0, err
+			}
+		}
+		return line, count, nil
+	}
+	start, oldCount, err1 := rangeOf(fields[1][1:])
+	_, newCount, err2 := rangeOf(fields[2][1:])
+	if err1 != nil || err2 != nil {
+		return 0, 0, 0, fmt.Errorf("invalid hunk header %q", header)
+	}
+	return start, oldCount, newCount, nil
+}
+
+// Apply applies the hunks to content, returning the new content. Each hunk
+// is looked for at its stated line first, then at most patchLookaround lines
+// around it.
+func (fp FilePatch) Apply(content string) (string, error) {
+	lines := strings.Split(content, "\n")
+	trailingNewline := strings.HasSuffix(content, "\n")
+	if trailingNewline {
+		lines = lines[:len(lines)-1]
+	}
+	if content == "" {
+		lines = nil
+	}
+
+	offset := 0 // Lines added minus lines removed by the previous hunks
+	for n, h := range fp.Hunks {
+		var old, repl []string
+		for _, l := range h.Lines {
+			switch l[0] {
+			case ' ':
+				old = append(old, l[1:])
+				repl = append(repl, l[1:])
+			case '-':
+				old = append(old, l[1:])
+			case '+':
+				repl = append(repl, l[1:])
+			}
+		}
+
+		want := max(0, h.OldStart-1+offset)
+		if len(old) == 0 {
+			// Pure insertion: "@@ -l,0 +..." inserts after line l (0 for a new file)
+			want = h.OldStart + offset
+		}
+		at := findLines(lines, old, want)
+		if at < 0 {
+			return "", fmt.Errorf("hunk %d (line %d) does not match the file", n+1, h.OldStart)
+		}
+		lines = append(lines[:at:at], append(repl, lines[at+len(old):]...)...)
+		offset += len(repl) - len(old)
+	}
+
+	out := strings.Join(lines, "\n")
+	if len(lines) > 0 && (trailingNewline || content == "") {
+		out += "\n"
+	}
+	return out, nil
+}
+
+// findLines returns the index of old in lines closest to want, or -1.
+func findLines(lines []string, old []string, want int) int {
+	matches := func(at int) bool {
+		if at < 0 || at+len(old) > len(lines) {
+			return false
+		}
+		for i, l := range old {
+			if lines[at+i] != l {
+				return false
+			}
+		}
+		return true
+	}
+	for d := 0; d <= patchLookaround; d++ {
+		if matches(want - d) {
+			return want - d
+		}
+		if matches(want + d) {
+			return want + d
+		}
+	}
+	return -1
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePatch(t *testing.T) {
+	patch := "diff --git a/old.go b/new.go\n" +
+		"--- a/old.go\t2024-01-01 00:00:00\n" +
+		"+++ b/new.go\n" +
+		"@@ -1,2 +1,2 @@\n" +
+		" a\n" +
+		"-b\n" +
+		"+c\n" +
+		"\\ No newline at end of file\n" +
+		"--- /dev/null\n" +
+		"+++ b/created.go\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+x\n"
+	files, err := ParsePatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d files, want 2", len(files))
+	}
+	if files[0].OldPath != "old.go" || files[0].NewPath != "new.go" {
+		t.Errorf("paths = %q, %q", files[0].OldPath, files[0].NewPath)
+	}
+	if h := files[0].Hunks; len(h) != 1 || h[0].OldStart != 1 || len(h[0].Lines) != 3 {
+		t.Errorf("hunks = %+v", h)
+	}
+	if files[1].OldPath != "" || files[1].NewPath != "created.go" {
+		t.Errorf("created paths = %q, %q", files[1].OldPath, files[1].NewPath)
+	}
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{"no header", "just text\n"},
+		{"hunk before header", "@@ -1 +1 @@\n-a\n+b\n"},
+		{"invalid hunk header", "--- a/f\n+++ b/f\n@@ -x +1 @@\n"},
+		{"truncated hunk", "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n"},
+		{"unexpected line", "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n?b\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePatch(tt.patch); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestFilePatchApply(t *testing.T) {
+	numbered := func(n int) string {
+		var b strings.Builder
+		for i := 1; i <= n; i++ {
+			b.WriteString("line" + strings.Repeat("x", i) + "\n")
+		}
+		return b.String()
+	}
+	tests := []struct {
+		name    string
+		content string
+		patch   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "exact",
+			content: "a\nb\nc\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    "a\nB\nc\n",
+		},
+		{
+			name:    "offset down",
+			content: "new1\nnew2\nnew3\na\nb\nc\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    "new1\nnew2\nnew3\na\nB\nc\n",
+		},
+		{
+			name:    "offset up",
+			content: "a\nb\nc\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -10,3 +10,3 @@\n a\n-b\n+B\n c\n",
+			want:    "a\nB\nc\n",
+		},
+		{
+			name:    "second hunk shifted by the first",
+			content: "a\nb\nc\nd\ne\nf\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,2 +1,4 @@\n a\n+a1\n+a2\n b\n@@ -5,2 +7,1 @@\n e\n-f\n",
+			want:    "a\na1\na2\nb\nc\nd\ne\n",
+		},
+		{
+			name:    "beyond the lookaround",
+			content: numbered(patchLookaround+20) + "a\nb\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+B\n",
+			wantErr: true,
+		},
+		{
+			name:    "rejected hunk",
+			content: "a\nb\nc\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-x\n+B\n c\n",
+			wantErr: true,
+		},
+		{
+			name:    "new file",
+			content: "",
+			patch:   "--- /dev/null\n+++ b/f\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+			want:    "a\nb\n",
+		},
+		{
+			name:    "no trailing newline",
+			content: "a\nb",
+			patch:   "--- a/f\n+++ b/f\n@@ -2 +2 @@\n-b\n+B\n",
+			want:    "a\nB",
+		},
+		{
+			name:    "insertion",
+			content: "a\nb\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,0 +2 @@\n+x\n",
+			want:    "a\nx\nb\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := ParsePatch(tt.patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := files[0].Apply(tt.content)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
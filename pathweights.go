@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// pathWeight is a compiled path_weights rule.
+type pathWeight struct {
+	pattern string
+	re      *regexp.Regexp
+	weight  int
+}
+
+// PathWeights boost (positive weight) or penalize (negative weight) the
+// files whose path matches a glob, e.g. {"internal/**": 30, "**/mocks/**": -50}.
+// Set via path_weights in the config.
+var PathWeights []pathWeight
+
+// SetPathWeights compiles the path_weights rules. Patterns are globs on the
+// slash-separated path relative to the root (*, ?, [...], **); a pattern
+// without a slash matches the file name at any depth, like .gitignore.
+func SetPathWeights(rules map[string]int) {
+	PathWeights = nil
+	for pattern, w := range rules {
+		glob := strings.TrimPrefix(pattern, "/")
+		expr := globToRegexp(glob)
+		if !strings.Contains(glob, "/") {
+			expr = "(?:.*/)?" + expr
+		}
+		re, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			slog.Warn("invalid path weight pattern in config", "pattern", pattern, "err", err)
+			continue
+		}
+		PathWeights = append(PathWeights, pathWeight{pattern: pattern, re: re, weight: w})
+	}
+	// Stable reasons whatever the map order
+	sort.Slice(PathWeights, func(i, j int) bool { return PathWeights[i].pattern < PathWeights[j].pattern })
+}
+
+// scorePath returns the sum of the weights of the rules matching relPath,
+// and a "path:<pattern>" reason per match.
+func scorePath(relPath string) (int, []string) {
+	if len(PathWeights) == 0 {
+		return 0, nil
+	}
+	p := filepath.ToSlash(relPath)
+	score := 0
+	var reasons []string
+	for _, r := range PathWeights {
+		if r.re.MatchString(p) {
+			score += r.weight
+			reasons = append(reasons, "path:"+r.pattern)
+		}
+	}
+	return score, reasons
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ProgressThreshold is how long a search runs before its progress (files
+// scanned, pending language servers) is reported, and progressInterval how
+// often it is reported then.
+const (
+	ProgressThreshold = time.Second
+	progressInterval  = time.Second
+)
+
+// SearchProgress counts the work of a running search. A nil *SearchProgress
+// counts nothing.
+type SearchProgress struct {
+	total   atomic.Int64
+	scanned atomic.Int64
+
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+type searchProgressKey struct{}
+
+// WithSearchProgress returns a context making the searches run with it
+// count their work in p.
+func WithSearchProgress(ctx context.Context, p *SearchProgress) context.Context {
+	return context.WithValue(ctx, searchProgressKey{}, p)
+}
+
+// searchProgress returns the SearchProgress of ctx, or nil.
+func searchProgress(ctx context.Context) *SearchProgress {
+	p, _ := ctx.Value(searchProgressKey{}).(*SearchProgress)
+	return p
+}
+
+// addFiles adds n files to scan.
+func (p *SearchProgress) addFiles(n int) {
+	if p != nil {
+		p.total.Add(int64(n))
+	}
+}
+
+// fileScanned counts a scanned file.
+func (p *SearchProgress) fileScanned() {
+	if p != nil {
+		p.scanned.Add(1)
+	}
+}
+
+// start marks stage ("local", "gopls"...) as pending until done is called.
+func (p *SearchProgress) start(stage string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending == nil {
+		p.pending = make(map[string]bool)
+	}
+	p.pending[stage] = true
+}
+
+func (p *SearchProgress) done(stage string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, stage)
+}
+
+// Pending returns the sorted stages still running.
+func (p *SearchProgress) Pending() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stages := make([]string, 0, len(p.pending))
+	for stage := range p.pending {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+	return stages
+}
+
+// progressReporter streams the progress of a search to the client as MCP
+// progress notifications, when the client asked for them (progressToken in
+// the request _meta):
+//   - the returned PartialFunc sends the intermediate batches, the message
+//     holding {"stage": ..., "files": [...]};
+//   - once the search runs longer than ProgressThreshold, the returned
+//     context reports every progressInterval the files scanned and the
+//     pending stages, the message holding
+//     {"stage": "progress", "scanned": n, "total": n, "pending": [...]}.
+//
+// stop ends the reports. Without a progressToken, ctx is returned as is
+// with a nil PartialFunc.
+func progressReporter(ctx context.Context, request mcp.CallToolRequest) (context.Context, PartialFunc, func()) {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return ctx, nil, func() {}
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return ctx, nil, func() {}
+	}
+	token := request.Params.Meta.ProgressToken
+
+	var mu sync.Mutex
+	progress := 0
+	notify := func(message any) {
+		msg, err := json.Marshal(message)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		// The progress must increase with every notification
+		progress++
+		_ = srv.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+			"progressToken": token,
+			"progress":      progress,
+			"message":       string(msg),
+		})
+	}
+
+	p := &SearchProgress{}
+	done := make(chan struct{})
+	go func() {
+		timer := time.NewTimer(ProgressThreshold)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			scanned, total := p.scanned.Load(), p.total.Load()
+			notify(map[string]any{
+				"stage":   "progress",
+				"scanned": scanned,
+				"total":   total,
+				"pending": p.Pending(),
+			})
+			timer.Reset(progressInterval)
+		}
+	}()
+
+	var once sync.Once
+	stop := func() { once.Do(func() { close(done) }) }
+	onPartial := func(stage string, batch []FileScore) {
+		notify(map[string]any{"stage": stage, "files": batch})
+	}
+	return WithSearchProgress(ctx, p), onPartial, stop
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// promptResults bounds the search results listed in a prompt.
+const promptResults = 10
+
+// explainSymbolPrompt returns the explain_symbol prompt: the declaration of
+// a Go symbol when its package is given, the files likely declaring it
+// otherwise, with instructions to explain it.
+func explainSymbolPrompt(rootPath string) (mcp.Prompt, server.PromptHandlerFunc) {
+	prompt := mcp.NewPrompt("explain_symbol",
+		mcp.WithPromptDescription("Explain what a symbol does and how it is used, from its source and its callers."),
+		mcp.WithArgument("symbol", mcp.RequiredArgument(), mcp.ArgumentDescription("Symbol name, e.g. Login or AuthService.Login")),
+		mcp.WithArgument("package", mcp.ArgumentDescription("Go package declaring it: a directory of the project or an import path. Searched when empty.")),
+	)
+
+	return prompt, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		symbol := strings.TrimSpace(request.Params.Arguments["symbol"])
+		pkg := strings.TrimSpace(request.Params.Arguments["package"])
+		if symbol == "" {
+			return nil, fmt.Errorf("missing symbol argument")
+		}
+
+		instructions := fmt.Sprintf("Explain the symbol %s: what it does, its inputs and outputs, its side effects and errors, and how the rest of the code uses it. Use search_files to find its callers and read_file to read them. Cite file paths and line numbers.", symbol)
+		if pkg == "" {
+			results, err := Search(ctx, rootPath, symbol, SearchOptions{}, nil)
+			if err != nil && !isBackendError(err) {
+				return nil, err
+			}
+			text := instructions + "\n\nsearch_files found it in:\n" + formatPromptResults(results)
+			return mcp.NewGetPromptResult("Explain "+symbol, []mcp.PromptMessage{
+				mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text)),
+			}), nil
+		}
+
+		dir, err := packageDir(ctx, rootPath, pkg)
+		if err != nil {
+			return nil, err
+		}
+		if !isAllowedPath(ctx, dir) {
+			return nil, fmt.Errorf("access denied: package %s is outside the project and its dependencies", pkg)
+		}
+		decl, err := FindGoDecl(ctx, dir, symbol)
+		if err != nil {
+			return nil, err
+		}
+		source, _ := RedactSecrets(decl.Path, decl.Source)
+		return mcp.NewGetPromptResult("Explain "+symbol, []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(fmt.Sprintf("%s\n\nIts declaration, %s:%d:", instructions, decl.Path, decl.Line))),
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(mcp.TextResourceContents{
+				URI:      "symbol://" + pkg + "/" + symbol,
+				MIMEType: "text/x-go",
+				Text:     source,
+			})),
+		}), nil
+	}
+}
+
+// summarizePackagePrompt returns the summarize_package prompt: the files of
+// a Go package and their declarations, with instructions to summarize it.
+func summarizePackagePrompt(rootPath string) (mcp.Prompt, server.PromptHandlerFunc) {
+	prompt := mcp.NewPrompt("summarize_package",
+		mcp.WithPromptDescription("Summarize the purpose, main types and entry points of a Go package."),
+		mcp.WithArgument("package", mcp.RequiredArgument(), mcp.ArgumentDescription("A directory of the project (\".\" for the root package) or an import path")),
+	)
+
+	return prompt, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		pkg := strings.TrimSpace(request.Params.Arguments["package"])
+		if pkg == "" {
+			return nil, fmt.Errorf("missing package argument")
+		}
+		dir, err := packageDir(ctx, rootPath, pkg)
+		if err != nil {
+			return nil, err
+		}
+		if !isAllowedPath(ctx, dir) {
+			return nil, fmt.Errorf("access denied: package %s is outside the project and its dependencies", pkg)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Summarize the Go package %s (%s): its purpose, its main types and functions, its entry points and its dependencies on the rest of the project. Read the key files with read_file before answering.\n\nFiles and declarations:\n", pkg, dir)
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			var decls []string
+			for _, sym := range ExtractGoSymbols(ctx, filepath.Join(dir, name)) {
+				decls = append(decls, sym.Kind+" "+sym.Name)
+			}
+			fmt.Fprintf(&sb, "- %s: %s\n", name, strings.Join(decls, ", "))
+		}
+		return mcp.NewGetPromptResult("Summarize "+pkg, []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(sb.String())),
+		}), nil
+	}
+}
+
+// findFeaturePrompt returns the find_feature prompt: the search results of a
+// feature description, with instructions to locate its implementation.
+func findFeaturePrompt(rootPath string) (mcp.Prompt, server.PromptHandlerFunc) {
+	prompt := mcp.NewPrompt("find_feature",
+		mcp.WithPromptDescription("Find where a feature is implemented, starting from the search_files results for it."),
+		mcp.WithArgument("feature", mcp.RequiredArgument(), mcp.ArgumentDescription("Feature description or keywords, e.g. 'password reset email'")),
+	)
+
+	return prompt, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		feature := strings.TrimSpace(request.Params.Arguments["feature"])
+		if feature == "" {
+			return nil, fmt.Errorf("missing feature argument")
+		}
+		results, err := Search(ctx, rootPath, feature, SearchOptions{}, nil)
+		if err != nil && !isBackendError(err) {
+			return nil, err
+		}
+		text := fmt.Sprintf("Find where the feature %q is implemented. Start from the search_files results below, read the most relevant files with read_file, and run more search_files queries with the names you discover to follow the calls. Answer with the entry points and the files and line numbers involved.\n\nsearch_files results:\n%s", feature, formatPromptResults(results))
+		return mcp.NewGetPromptResult("Find "+feature, []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text)),
+		}), nil
+	}
+}
+
+// formatPromptResults lists the best search results, one per line.
+func formatPromptResults(results []FileScore) string {
+	if len(results) == 0 {
+		return "(no results)\n"
+	}
+	results = results[:min(len(results), promptResults)]
+	var sb strings.Builder
+	for _, r := range results {
+		dep := ""
+		if r.IsDep {
+			dep = " (dependency)"
+		}
+		fmt.Fprintf(&sb, "- %s%s, score %d: %s\n", r.Path, dep, r.Score, strings.Join(r.Reasons, ", "))
+	}
+	return sb.String()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	pyDefRe    = regexp.MustCompile(`^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`)
+	pyClassRe  = regexp.MustCompile(`^\s*class\s+([A-Za-z_]\w*)`)
+	pyAssignRe = regexp.MustCompile(`^([A-Za-z_]\w*)\s*(?::[^=]+)?=[^=]`)
+)
+
+// ExtractPythonSymbols returns the functions (including methods), classes and
+// module-level assignments of a Python file, with the "pyfunc", "pyclass" and
+// "pyvar" kinds. Docstrings and comments are skipped.
+func ExtractPythonSymbols(ctx context.Context, absPath string) []Symbol {
+	if ctx.Err() != nil {
+		return nil
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil
+	}
+
+	var symbols []Symbol
+	inString := "" // Delimiter of the triple-quoted string spanning lines, if any
+	for _, line := range strings.Split(string(content), "\n") {
+		if inString != "" {
+			if strings.Contains(line, inString) {
+				inString = ""
+			}
+			continue
+		}
+
+		if m := pyDefRe.FindStringSubmatch(line); m != nil {
+			symbols = append(symbols, Symbol{Kind: "pyfunc", Name: m[1]})
+		} else if m := pyClassRe.FindStringSubmatch(line); m != nil {
+			symbols = append(symbols, Symbol{Kind: "pyclass", Name: m[1]})
+		} else if m := pyAssignRe.FindStringSubmatch(line); m != nil {
+			// Only unindented assignments are module-level
+			symbols = append(symbols, Symbol{Kind: "pyvar", Name: m[1]})
+		}
+
+		inString = openTripleQuote(line)
+	}
+	return symbols
+}
+
+// openTripleQuote returns the triple-quote delimiter left open at the end of
+// line, or "" when every triple-quoted string on the line is closed.
+func openTripleQuote(line string) string {
+	if i := strings.IndexByte(line, '#'); i >= 0 && !strings.Contains(line[:i], `"`) && !strings.Contains(line[:i], `'`) {
+		line = line[:i]
+	}
+	open := ""
+	for len(line) > 0 {
+		if open == "" {
+			dq, sq := strings.Index(line, `"""`), strings.Index(line, `'''`)
+			switch {
+			case dq < 0 && sq < 0:
+				return ""
+			case sq < 0 || (dq >= 0 && dq < sq):
+				open, line = `"""`, line[dq+3:]
+			default:
+				open, line = `'''`, line[sq+3:]
+			}
+			continue
+		}
+		end := strings.Index(line, open)
+		if end < 0 {
+			return open
+		}
+		open, line = "", line[end+3:]
+	}
+	return open
+}
+
+// pythonLanguage describes pyright, used when the project has a
+// pyproject.toml or a virtualenv. pylsp can be used instead through the
+// language_servers config section.
+func pythonLanguage() Language {
+	return Language{
+		Name:    "python",
+		Command: []string{"pyright-langserver", "--stdio"},
+		Markers: []string{"pyproject.toml", ".venv/pyvenv.cfg", "venv/pyvenv.cfg"},
+		Detect: func(root string) bool {
+			// An activated virtualenv
+			return os.Getenv("VIRTUAL_ENV") != ""
+		},
+		Classify: func(root string, path string) (bool, bool) {
+			// Filter out the stdlib stubs bundled with pyright.
+			if strings.Contains(path, "/typeshed-fallback/stdlib/") {
+				return true, false
+			}
+			return false, strings.Contains(path, "/site-packages/") || strings.Contains(path, "/typeshed-fallback/")
+		},
+		Stdlib: func(path string) bool {
+			return strings.Contains(path, "/typeshed-fallback/stdlib/")
+		},
+		ReadPaths: sitePackages,
+	}
+}
+
+// sitePackages returns the site-packages directories of the project
+// virtualenvs (.venv, venv) and of the active one ($VIRTUAL_ENV).
+func sitePackages(root string) []string {
+	envs := []string{filepath.Join(root, ".venv"), filepath.Join(root, "venv")}
+	if env := os.Getenv("VIRTUAL_ENV"); env != "" {
+		envs = append(envs, env)
+	}
+
+	var paths []string
+	for _, env := range envs {
+		matches, _ := filepath.Glob(filepath.Join(env, "lib", "python*", "site-packages"))
+		paths = append(paths, matches...)
+		// Windows layout
+		if info, err := os.Stat(filepath.Join(env, "Lib", "site-packages")); err == nil && info.IsDir() {
+			paths = append(paths, filepath.Join(env, "Lib", "site-packages"))
+		}
+	}
+	return paths
+}
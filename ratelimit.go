@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+var (
+	// CallsPerMinute is the maximum number of tool calls of an MCP session
+	// over the last minute, 0 for no limit.
+	// Set via calls_per_minute in the config, the --calls-per-minute flag or
+	// CODEMCP_CALLS_PER_MINUTE.
+	CallsPerMinute = 0
+
+	// BytesPerMinute is the maximum size of the tool results returned to an
+	// MCP session over the last minute, 0 for no limit.
+	// Set via bytes_per_minute in the config, the --bytes-per-minute flag or
+	// CODEMCP_BYTES_PER_MINUTE.
+	BytesPerMinute = 0
+)
+
+// rateWindow is the period the rate limits apply to.
+const rateWindow = time.Minute
+
+// usageEntry is a tool call: its start and the size of its result.
+type usageEntry struct {
+	at    time.Time
+	bytes int
+}
+
+// rateLimiter counts the tool calls and result bytes of each MCP session.
+type rateLimiter struct {
+	mu       sync.Mutex
+	sessions map[string][]*usageEntry // Calls of the last rateWindow, oldest first
+}
+
+// handleRateLimits returns the middleware enforcing CallsPerMinute and
+// BytesPerMinute on each session, forgetting a session with it. It must run
+// inside the audit middleware, so the log records the rejected calls.
+func handleRateLimits(hooks *server.Hooks) server.ToolHandlerMiddleware {
+	limiter := &rateLimiter{sessions: make(map[string][]*usageEntry)}
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		limiter.mu.Lock()
+		delete(limiter.sessions, session.SessionID())
+		limiter.mu.Unlock()
+	})
+	return limiter.middleware
+}
+
+func (l *rateLimiter) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if CallsPerMinute <= 0 && BytesPerMinute <= 0 {
+			return next(ctx, request)
+		}
+		var id string
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			id = session.SessionID()
+		}
+		entry, err := l.acquire(id, time.Now())
+		if err != nil {
+			return mcp.NewToolResultError("Rate limit exceeded: " + err.Error()), nil
+		}
+
+		result, err := next(ctx, request)
+		if result != nil {
+			n := 0
+			for _, c := range result.Content {
+				if text, ok := c.(mcp.TextContent); ok {
+					n += len(text.Text)
+				}
+			}
+			l.mu.Lock()
+			entry.bytes = n
+			l.mu.Unlock()
+		}
+		return result, err
+	}
+}
+
+// acquire records a tool call of the session id at now, unless it exceeds a
+// limit, then the error says when to retry.
+func (l *rateLimiter) acquire(id string, now time.Time) (*usageEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Drop the calls out of the window
+	calls := l.sessions[id]
+	for len(calls) > 0 && now.Sub(calls[0].at) >= rateWindow {
+		calls = calls[1:]
+	}
+	l.sessions[id] = calls
+
+	if CallsPerMinute > 0 && len(calls) >= CallsPerMinute {
+		retry := calls[len(calls)-CallsPerMinute].at.Add(rateWindow).Sub(now)
+		return nil, fmt.Errorf("%d tool calls in the last minute, the limit is %d; retry in %s", len(calls), CallsPerMinute, retry.Round(time.Second))
+	}
+	if BytesPerMinute > 0 {
+		used := 0
+		for _, c := range calls {
+			used += c.bytes
+		}
+		if used >= BytesPerMinute {
+			// Bytes are freed as the oldest calls leave the window
+			var retry time.Duration
+			left := used
+			for _, c := range calls {
+				if left -= c.bytes; left < BytesPerMinute {
+					retry = c.at.Add(rateWindow).Sub(now)
+					break
+				}
+			}
+			return nil, fmt.Errorf("%d result bytes in the last minute, the limit is %d; retry in %s", used, BytesPerMinute, retry.Round(time.Second))
+		}
+	}
+
+	entry := &usageEntry{at: now}
+	l.sessions[id] = append(calls, entry)
+	return entry, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Token budgets of repo_map, at about 4 bytes per token.
+const (
+	defaultMapTokens = 4000
+	maxMapTokens     = 25000
+	bytesPerToken    = 4
+)
+
+// RepoMap returns a compact map of the files of root under dir (relative to
+// root, "." for all): per package directory, shallowest first, the exported
+// declarations of each file, Go signatures included. Files whose block does
+// not fit in budget bytes are left out, and counted in the returned number.
+func RepoMap(ctx context.Context, root string, dir string, budget int) (string, int, error) {
+	files, err := CollectFiles(ctx, root)
+	if err != nil {
+		return "", 0, err
+	}
+	dir = path.Clean(filepath.ToSlash(dir))
+	byDir := make(map[string][]string)
+	for _, f := range files {
+		f = filepath.ToSlash(f)
+		if f == "" || dir != "." && !strings.HasPrefix(f, dir+"/") || !mappedFile(f) {
+			continue
+		}
+		byDir[path.Dir(f)] = append(byDir[path.Dir(f)], f)
+	}
+	dirs := make([]string, 0, len(byDir))
+	for d := range byDir {
+		dirs = append(dirs, d)
+	}
+	// Breadth first: the top of the tree fits first in the budget
+	sort.Slice(dirs, func(i, j int) bool {
+		di, dj := strings.Count(dirs[i], "/"), strings.Count(dirs[j], "/")
+		if dirs[i] == "." || dirs[j] == "." {
+			return dirs[i] == "." && dirs[j] != "."
+		}
+		if di != dj {
+			return di < dj
+		}
+		return dirs[i] < dirs[j]
+	})
+
+	var b strings.Builder
+	omitted := 0
+	for _, d := range dirs {
+		if err := ctx.Err(); err != nil {
+			return b.String(), omitted, err
+		}
+		header := d + "/\n"
+		sort.Strings(byDir[d])
+		for _, f := range byDir[d] {
+			decls := fileDecls(ctx, filepath.Join(root, filepath.FromSlash(f)))
+			if len(decls) == 0 {
+				continue
+			}
+			block := "  " + path.Base(f) + ":\n    " + strings.Join(decls, "\n    ") + "\n"
+			if b.Len()+len(header)+len(block) > budget {
+				omitted++
+				continue
+			}
+			b.WriteString(header + block)
+			header = ""
+		}
+	}
+	return b.String(), omitted, nil
+}
+
+// mappedFile reports whether the file at relPath belongs in the repo map:
+// source files with a built-in symbol extractor, tests and test data aside.
+func mappedFile(relPath string) bool {
+	name := strings.ToLower(path.Base(relPath))
+	if strings.HasSuffix(name, "_test.go") || strings.Contains("/"+relPath+"/", "/testdata/") {
+		return false
+	}
+	if _, ok := FileNameExtractors[name]; ok {
+		return true
+	}
+	_, ok := SymbolExtractors[path.Ext(name)]
+	return ok
+}
+
+// fileDecls returns the declarations of the file at absPath shown by the
+// repo map: the exported Go declarations with their signatures, the symbols
+// of the extractor of other languages, private (_name) ones aside.
+func fileDecls(ctx context.Context, absPath string) []string {
+	if filepath.Ext(absPath) == ".go" {
+		return goSignatures(absPath)
+	}
+	extract, ok := FileExtractor(absPath)
+	if !ok {
+		return nil
+	}
+	var decls []string
+	for _, s := range extract(ctx, absPath) {
+		if !strings.HasPrefix(s.Name, "_") {
+			decls = append(decls, s.Kind+" "+s.Name)
+		}
+	}
+	return decls
+}
+
+// goSignatures returns the exported declarations of the Go file at absPath,
+// one line each: functions and methods with their signature, types with
+// their kind (and the methods of interfaces), constants and variables by
+// name.
+func goSignatures(absPath string) []string {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, absPath, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	// print renders node on one line
+	print := func(node any) string {
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, fset, node); err != nil {
+			return ""
+		}
+		// Multi-line parameter lists leave "( a int, b string, )"
+		return oneLine.Replace(strings.Join(strings.Fields(buf.String()), " "))
+	}
+
+	var decls []string
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() || d.Recv != nil && !exportedReceiver(d.Recv) {
+				continue
+			}
+			sig := *d
+			sig.Body, sig.Doc = nil, nil
+			decls = append(decls, print(&sig))
+		case *ast.GenDecl:
+			var names []string
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if !s.Name.IsExported() {
+						continue
+					}
+					short := *s
+					short.Doc, short.Comment = nil, nil
+					switch t := s.Type.(type) {
+					case *ast.StructType:
+						short.Type = ast.NewIdent("struct")
+						decls = append(decls, "type "+print(&short))
+					case *ast.InterfaceType:
+						short.Type = ast.NewIdent("interface")
+						decls = append(decls, "type "+print(&short))
+						for _, m := range t.Methods.List {
+							if fn, ok := m.Type.(*ast.FuncType); ok && len(m.Names) > 0 && m.Names[0].IsExported() {
+								decls = append(decls, "  "+m.Names[0].Name+strings.TrimPrefix(print(fn), "func"))
+							}
+						}
+					default:
+						decls = append(decls, "type "+print(&short))
+					}
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						if n.IsExported() {
+							names = append(names, n.Name)
+						}
+					}
+				}
+			}
+			if len(names) > 0 {
+				decls = append(decls, d.Tok.String()+" "+strings.Join(names, ", "))
+			}
+		}
+	}
+	return decls
+}
+
+// oneLine tidies the parameter lists of a declaration joined on one line.
+var oneLine = strings.NewReplacer("( ", "(", ", )", ")", " )", ")")
+
+// exportedReceiver reports whether the receiver type of a method is
+// exported, so the method is part of the package API.
+func exportedReceiver(recv *ast.FieldList) bool {
+	if len(recv.List) == 0 {
+		return false
+	}
+	t := recv.List[0].Type
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+		case *ast.IndexExpr:
+			t = x.X
+		case *ast.IndexListExpr:
+			t = x.X
+		case *ast.Ident:
+			return x.IsExported()
+		default:
+			return false
+		}
+	}
+}
+
+// repoMapTool returns the signatures-only map of the repository.
+func repoMapTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("repo_map",
+		mcp.WithDescription("Return a compact map of the repository: per package directory, the exported types and function signatures of each file (symbol names for non-Go languages), within a token budget. Call it first to get an overview of an unfamiliar repository, then search_files and read_file for details."),
+		mcp.WithString("path", mcp.Description("Limit the map to this directory (absolute or relative to project root), the project root by default")),
+		mcp.WithNumber("max_tokens", mcp.Description(fmt.Sprintf("Size budget of the map in tokens, %d by default, at most %d", defaultMapTokens, maxMapTokens))),
+		readOnlyAnnotations("Repository map"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		pathArg := request.GetString("path", ".")
+		targetPath := resolvePath(rootPath, pathArg)
+		rel, err := filepath.Rel(rootPath, targetPath)
+		if err != nil || !filepath.IsLocal(rel) {
+			return mcp.NewToolResultError(fmt.Sprintf("%s is outside the project root %s", pathArg, rootPath)), nil
+		}
+
+		tokens := min(max(request.GetInt("max_tokens", defaultMapTokens), 100), maxMapTokens)
+		repoMap, omitted, err := RepoMap(ctx, rootPath, rel, tokens*bytesPerToken)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("repo map failed: %v", err)), nil
+		}
+		if repoMap == "" && omitted == 0 {
+			return mcp.NewToolResultText("No source files with declarations"), nil
+		}
+		if omitted > 0 {
+			repoMap += fmt.Sprintf("[%d files omitted to fit %d tokens; raise max_tokens or pass the path of a directory]\n", omitted, tokens)
+		}
+		return mcp.NewToolResultText(repoMap), nil
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ExtraRoots are the absolute paths of the search roots after the first one
+// (the project root, which owns the config, language servers and caches).
+// Their files are scored like the project files and readable by read_file.
+// Set via a repeated or comma-separated --path.
+var ExtraRoots []string
+
+// ClientRoots enables the MCP roots capability: the workspace roots a client
+// advertises are searched and readable during its session, on top of the
+// search roots, and updated when the client reports a change.
+// Set via the --client-roots flag or CODEMCP_CLIENT_ROOTS.
+var ClientRoots = true
+
+// sessionRoots maps an MCP session ID to the absolute paths of its roots.
+var sessionRoots sync.Map
+
+// listRootsTimeout bounds the wait for a client to list its roots.
+const listRootsTimeout = 30 * time.Second
+
+// rootsFlag is the value of --path: the search roots, from repeated flags or
+// comma-separated lists. The first Set replaces the default.
+type rootsFlag struct {
+	paths []string
+	set   bool
+}
+
+func (r *rootsFlag) String() string {
+	if r == nil {
+		return ""
+	}
+	return strings.Join(r.paths, ",")
+}
+
+func (r *rootsFlag) Set(value string) error {
+	if !r.set {
+		r.paths = nil
+		r.set = true
+	}
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			r.paths = append(r.paths, p)
+		}
+	}
+	return nil
+}
+
+// newRootsFlag returns a rootsFlag defaulting to value.
+func newRootsFlag(value string) *rootsFlag {
+	r := &rootsFlag{}
+	_ = r.Set(value)
+	r.set = false
+	if len(r.paths) == 0 {
+		r.paths = []string{"."}
+	}
+	return r
+}
+
+// resolveRoots returns the absolute project root and the other distinct
+// roots, which must be existing directories.
+func resolveRoots(paths []string) (string, []string, error) {
+	var root string
+	var extra []string
+	seen := make(map[string]bool)
+	for i, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return "", nil, err
+		}
+		if seen[abs] {
+			continue
+		}
+		seen[abs] = true
+		if i == 0 {
+			root = abs
+			continue
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return "", nil, err
+		}
+		if !info.IsDir() {
+			return "", nil, fmt.Errorf("%s is not a directory", p)
+		}
+		extra = append(extra, abs)
+	}
+	return root, extra, nil
+}
+
+// extraRoots returns the roots searched besides root: ExtraRoots, unless the
+// MCP session of ctx is bound to a project of its own, then the roots of the
+// session.
+func extraRoots(ctx context.Context, root string) []string {
+	base := ExtraRoots
+	if sessionProject(ctx) != nil {
+		base = nil
+	}
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return base
+	}
+	v, ok := sessionRoots.Load(session.SessionID())
+	if !ok {
+		return base
+	}
+	roots := slices.Clone(base)
+	for _, r := range v.([]string) {
+		if r != root && !slices.Contains(roots, r) {
+			roots = append(roots, r)
+		}
+	}
+	return roots
+}
+
+// isSessionRoot reports whether target is inside a root of the MCP session
+// of ctx.
+func isSessionRoot(ctx context.Context, target string) bool {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return false
+	}
+	v, ok := sessionRoots.Load(session.SessionID())
+	if !ok {
+		return false
+	}
+	for _, r := range v.([]string) {
+		if target == r || strings.HasPrefix(target, r+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleClientRoots registers the handlers keeping the roots of each session
+// up to date: they are listed once the client is initialized and again on
+// notifications/roots/list_changed, and forgotten with the session.
+func handleClientRoots(s *server.MCPServer, hooks *server.Hooks) {
+	refresh := func(ctx context.Context, _ mcp.JSONRPCNotification) {
+		session := server.ClientSessionFromContext(ctx)
+		if session == nil {
+			return
+		}
+		if info, ok := session.(server.SessionWithClientInfo); ok && info.GetClientCapabilities().Roots == nil {
+			return
+		}
+		// Notification handlers run in the read loop of the session, which
+		// must go on to receive the response.
+		go func() {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), listRootsTimeout)
+			defer cancel()
+			result, err := s.RequestRoots(ctx, mcp.ListRootsRequest{})
+			if err != nil {
+				slog.Warn("cannot list the client roots", "session", session.SessionID(), "err", err)
+				return
+			}
+			roots := clientRootPaths(result.Roots)
+			sessionRoots.Store(session.SessionID(), roots)
+			slog.Info("client roots", "session", session.SessionID(), "roots", roots)
+		}()
+	}
+	s.AddNotificationHandler("notifications/initialized", refresh)
+	s.AddNotificationHandler(mcp.MethodNotificationRootsListChanged, refresh)
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		sessionRoots.Delete(session.SessionID())
+	})
+}
+
+// clientRootPaths returns the existing directories of the file:// roots.
+func clientRootPaths(roots []mcp.Root) []string {
+	var paths []string
+	for _, r := range roots {
+		u, err := url.Parse(r.URI)
+		if err != nil || u.Scheme != "file" {
+			slog.Debug("ignoring client root", "uri", r.URI)
+			continue
+		}
+		path := filepath.Clean(filepath.FromSlash(u.Path))
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			slog.Debug("ignoring client root", "uri", r.URI)
+			continue
+		}
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// searchExtraRoots scores the files of roots. Their results carry absolute
+// paths, and the root they come from.
+func searchExtraRoots(ctx context.Context, roots []string, terms []string, queryLower string) []FileScore {
+	var results []FileScore
+	for _, root := range roots {
+		res, _ := LocalSearch(ctx, root, terms, queryLower)
+		for i := range res {
+			res[i].Path = filepath.Join(root, res[i].Path)
+			res[i].Root = root
+		}
+		results = append(results, res...)
+	}
+	return results
+}
+
+// RootsFingerprint combines the ProjectFingerprint of root and ExtraRoots.
+func RootsFingerprint(ctx context.Context, root string) (string, error) {
+	fingerprint, err := ProjectFingerprint(ctx, root)
+	if err != nil {
+		return "", err
+	}
+	for _, extra := range ExtraRoots {
+		f, err := ProjectFingerprint(ctx, extra)
+		if err != nil {
+			return "", err
+		}
+		fingerprint += f
+	}
+	return fingerprint, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rustLanguage describes rust-analyzer, used for Cargo workspaces.
+func rustLanguage() Language {
+	return Language{
+		Name:        "rust",
+		Command:     []string{"rust-analyzer"},
+		VersionArgs: []string{"--version"},
+		Markers:     []string{"Cargo.toml"},
+		// By default rust-analyzer only returns workspace symbols,
+		// ask it to include the crates from the cargo registry too.
+		InitOptions: map[string]any{
+			"workspace": map[string]any{
+				"symbol": map[string]any{
+					"search": map[string]any{
+						"scope": "workspace_and_dependencies",
+						"kind":  "all_symbols",
+					},
+				},
+			},
+		},
+		Classify: func(root string, path string) (bool, bool) {
+			// Filter out the Rust standard library shipped with the toolchain.
+			if strings.Contains(path, "/.rustup/toolchains/") || strings.Contains(path, "/rustlib/src/") {
+				return true, false
+			}
+			return false, strings.Contains(path, "/.cargo/registry/") || strings.Contains(path, "/.cargo/git/")
+		},
+		ReadPaths: func(root string) []string {
+			home := cargoHome()
+			if home == "" {
+				return nil
+			}
+			return []string{filepath.Join(home, "registry"), filepath.Join(home, "git")}
+		},
+	}
+}
+
+// cargoHome returns the cargo home directory ($CARGO_HOME or ~/.cargo).
+func cargoHome() string {
+	if home := os.Getenv("CARGO_HOME"); home != "" {
+		return home
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".cargo")
+	}
+	return ""
+}
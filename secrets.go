@@ -0,0 +1,171 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"unicode"
+)
+
+// SecretsConfig tunes the secret protection of read_file.
+//
+//	secrets:
+//	  files: ["*.keystore"]
+//	  allow: ["test.pem"]
+//
+// Patterns are globs matched against the file name. Disabled and Allow are
+// read from the global config only, the Files of the project config add to
+// the global ones.
+type SecretsConfig struct {
+	// Disabled turns off both the refusal of secret files and the masking
+	// of secrets in file content.
+	Disabled bool `yaml:"disabled"`
+	// Files adds patterns to SecretFilePatterns.
+	Files []string `yaml:"files"`
+	// Allow lists files read as is, even when they match SecretFilePatterns.
+	Allow []string `yaml:"allow"`
+}
+
+// Secrets is the secrets section of the config.
+var Secrets SecretsConfig
+
+var (
+	// SecretFilePatterns are the names of files read_file refuses to return.
+	SecretFilePatterns = []string{
+		".env", ".env.*", "*.pem", "*.key", "*.p12", "*.pfx",
+		"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519",
+		"*credentials*", ".netrc", ".pgpass", ".htpasswd",
+	}
+
+	// SecretFileAllow are the names of files matching SecretFilePatterns that
+	// are known not to hold secrets.
+	SecretFileAllow = []string{".env.example", ".env.sample", ".env.template", "*.pub"}
+
+	// redactExempt are files full of high-entropy checksums, masking them
+	// would only hide hashes.
+	redactExempt = map[string]bool{
+		"go.sum": true, "go.work.sum": true, "Cargo.lock": true, "package-lock.json": true,
+		"yarn.lock": true, "pnpm-lock.yaml": true, "poetry.lock": true, "uv.lock": true,
+	}
+
+	// secretPatterns match well-known token formats, masked whatever their entropy.
+	secretPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+		regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),                       // AWS access key
+		regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),                      // GitHub token
+		regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`),                    // GitHub fine-grained token
+		regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`),                    // Slack token
+		regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}\b`),                           // OpenAI style API key
+		regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),                           // Google API key
+		regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`), // JWT
+	}
+
+	// tokenCandidate matches the strings checked for entropy.
+	tokenCandidate = regexp.MustCompile(`[A-Za-z0-9+/_-]{24,}={0,2}`)
+)
+
+const (
+	// redacted replaces the masked secrets.
+	redacted = "[REDACTED]"
+	// minSecretEntropy is the Shannon entropy (bits per character) above which
+	// a token mixing upper case, lower case and digits is considered random.
+	minSecretEntropy = 4.0
+	// maxLowerRun is the mean length of the lower case runs of a random token,
+	// about 1.7 for base62, above 4 for words.
+	maxLowerRun = 2.5
+)
+
+// matchesName reports whether the base name of path matches one of patterns.
+func matchesName(path string, patterns []string) (string, bool) {
+	name := filepath.Base(path)
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// IsSecretFile reports whether the file at path must not be read, and the
+// pattern it matches.
+func IsSecretFile(path string) (string, bool) {
+	if Secrets.Disabled {
+		return "", false
+	}
+	if _, ok := matchesName(path, slices.Concat(SecretFileAllow, Secrets.Allow)); ok {
+		return "", false
+	}
+	return matchesName(path, slices.Concat(SecretFilePatterns, Secrets.Files))
+}
+
+// RedactSecrets masks the well-known tokens and high-entropy strings of the
+// content of the file at path. It returns the content and the number of
+// masked secrets.
+func RedactSecrets(path string, content string) (string, int) {
+	if Secrets.Disabled || redactExempt[filepath.Base(path)] {
+		return content, 0
+	}
+	if _, ok := matchesName(path, Secrets.Allow); ok {
+		return content, 0
+	}
+	count := 0
+	for _, re := range secretPatterns {
+		content = re.ReplaceAllStringFunc(content, func(string) string {
+			count++
+			return redacted
+		})
+	}
+	content = tokenCandidate.ReplaceAllStringFunc(content, func(token string) string {
+		if !looksRandom(token) {
+			return token
+		}
+		count++
+		return redacted
+	})
+	return content, count
+}
+
+// looksRandom reports whether token mixes upper case letters, lower case
+// letters and digits with a high entropy, like API keys and passwords do.
+// CamelCase identifiers are told apart by their long lower case runs (words),
+// hex hashes by their lack of upper case.
+func looksRandom(token string) bool {
+	var upper, digit bool
+	lowerRuns, lowerLen, run := 0, 0, 0
+	for _, r := range token {
+		if unicode.IsLower(r) {
+			if run == 0 {
+				lowerRuns++
+			}
+			run++
+			lowerLen++
+			continue
+		}
+		run = 0
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return upper && digit && lowerRuns > 0 &&
+		float64(lowerLen)/float64(lowerRuns) < maxLowerRun &&
+		entropy(token) >= minSecretEntropy
+}
+
+// entropy returns the Shannon entropy of s, in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	n := float64(len(s))
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
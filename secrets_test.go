@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// withSecrets sets cfg as the secrets section of the config until the end
+// of the test.
+func withSecrets(t *testing.T, cfg SecretsConfig) {
+	t.Helper()
+	saved := Secrets
+	Secrets = cfg
+	t.Cleanup(func() { Secrets = saved })
+}
+
+func TestIsSecretFile(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{".env", true},
+		{".env.local", true},
+		{"/srv/app/config/.env.production", true},
+		{"server.pem", true},
+		{"tls/server.key", true},
+		{"id_rsa", true},
+		{"/home/u/.ssh/id_ed25519", true},
+		{"aws_credentials.json", true},
+		{".netrc", true},
+		{"store.p12", true},
+
+		{".env.example", false},
+		{".env.sample", false},
+		{"id_rsa.pub", false},
+		{"main.go", false},
+		{"environment.go", false},
+		{"keys.go", false},
+		{"pem.go", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if _, got := IsSecretFile(tt.path); got != tt.want {
+				t.Errorf("IsSecretFile(%s) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSecretFileConfig(t *testing.T) {
+	withSecrets(t, SecretsConfig{Files: []string{"*.keystore"}, Allow: []string{"test.pem"}})
+	if _, ok := IsSecretFile("release.keystore"); !ok {
+		t.Error("files pattern not denied")
+	}
+	if _, ok := IsSecretFile("testdata/test.pem"); ok {
+		t.Error("allowed file denied")
+	}
+	if _, ok := IsSecretFile("prod.pem"); !ok {
+		t.Error("default pattern not denied")
+	}
+
+	withSecrets(t, SecretsConfig{Disabled: true})
+	if _, ok := IsSecretFile(".env"); ok {
+		t.Error(".env denied with secrets disabled")
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	// Assembled so that the fixtures are not taken for leaked tokens
+	aws := "AKIA" + "IOSFODNN7EXAMPLE"
+	github := "ghp_" + strings.Repeat("a1B2c3D4e5", 4)
+	jwt := "eyJ" + "hbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2lnbmF0dXJl"
+	key := "-----BEGIN RSA " + "PRIVATE KEY-----\nMIIBOgIBAAJBAKj34GkxFhD90vcNLYLInFEX6Ppy1tPf9Cnzj4p4WGeKLs1Pt8Qu\n-----END RSA " + "PRIVATE KEY-----"
+	random := "Zq8Xv2Lw7Kp4Rt9Nm3Bs6Hd1"
+
+	tests := []struct {
+		name    string
+		content string
+		want    int // Masked secrets
+	}{
+		{"aws key", "aws_access_key_id = " + aws, 1},
+		{"github token", `token: "` + github + `"`, 1},
+		{"jwt", "Authorization: Bearer " + jwt, 1},
+		{"private key", "key = \"\"\"\n" + key + "\n\"\"\"", 1},
+		{"random password", "DB_PASSWORD=" + random, 1},
+		{"several", aws + "\n" + github + "\n", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n := RedactSecrets("config.yaml", tt.content)
+			if n != tt.want {
+				t.Errorf("masked %d secrets, want %d: %q", n, tt.want, got)
+			}
+			if !strings.Contains(got, redacted) {
+				t.Errorf("no %s in %q", redacted, got)
+			}
+			for _, secret := range []string{aws, github, jwt, random, "MIIBOgIBAAJBAKj34"} {
+				if strings.Contains(got, secret) {
+					t.Errorf("%q left in %q", secret, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactSecretsFalsePositives(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		content string
+	}{
+		{"camel case identifier", "main.go", "func TestHTTPHandlerV2ReturnsStatusOK(t *testing.T) {}"},
+		{"long identifier", "main.go", "var defaultMaxConcurrentLanguageServerCalls = 4"},
+		{"git hash", "CHANGELOG.md", "Fixed in 3f786850e387550fdab836ed7e6dc881de23001b"},
+		{"uuid", "fixtures.json", `{"id": "123e4567-e89b-12d3-a456-426614174000"}`},
+		{"import path", "main.go", `import "github.com/mark3labs/mcp-go/server"`},
+		{"go.sum hash", "go.sum", "github.com/google/uuid v1.6.0 h1:NIvaJDMOsjHA8n1jAhLSgzrAzy1Hgr+hNrb57e+94F0="},
+		{"short value", "config.yaml", "password: hunter2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n := RedactSecrets(tt.path, tt.content)
+			if n != 0 || got != tt.content {
+				t.Errorf("RedactSecrets(%s) masked %d: %q", tt.path, n, got)
+			}
+		})
+	}
+}
+
+func TestRedactSecretsConfig(t *testing.T) {
+	content := "token: " + "ghp_" + strings.Repeat("a1B2c3D4e5", 4)
+	withSecrets(t, SecretsConfig{Allow: []string{"fixtures.yaml"}})
+	if _, n := RedactSecrets("testdata/fixtures.yaml", content); n != 0 {
+		t.Error("allowed file redacted")
+	}
+	withSecrets(t, SecretsConfig{Disabled: true})
+	if _, n := RedactSecrets("config.yaml", content); n != 0 {
+		t.Error("redacted with secrets disabled")
+	}
+}
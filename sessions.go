@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RootHeader is the HTTP header of the initialize request binding an MCP
+// session to a project root of its own.
+const RootHeader = "Codemcp-Root"
+
+// SessionRootDirs are the directories under which HTTP clients may bind
+// their session to a project with RootHeader. Empty disables it.
+// Set via the --session-roots flag or CODEMCP_SESSION_ROOTS.
+var SessionRootDirs []string
+
+// Project is a project root bound to HTTP sessions, with its own language
+// servers and read_file allowlist. Sessions on the same root share it.
+type Project struct {
+	Root string
+	LSP  *LSPManager
+
+	readOnce  sync.Once
+	readPaths []string
+	sessions  int
+}
+
+var (
+	// projects maps the root of a Project to it, while a session uses it.
+	projects   = make(map[string]*Project)
+	projectsMu sync.Mutex
+
+	// sessionProjects maps an MCP session ID to its *Project.
+	sessionProjects sync.Map
+
+	// sharedPathPrefixes are the AllowedPathPrefixes readable from every
+	// project: GOMODCACHE, GOROOT and the read_access of the config.
+	sharedPathPrefixes []string
+)
+
+// ReadPaths returns the directories read_file may access in the project,
+// before sharedPathPrefixes.
+func (p *Project) ReadPaths() []string {
+	p.readOnce.Do(func() {
+		p.readPaths = projectReadPaths(context.Background(), p.Root, p.LSP)
+	})
+	return p.readPaths
+}
+
+// projectReadPaths returns root, its go.work modules living next to it and
+// the dependency directories of its languages (e.g. the cargo registry).
+func projectReadPaths(ctx context.Context, root string, m *LSPManager) []string {
+	paths := []string{root}
+	paths = append(paths, goWorkOutside(root)...)
+	return append(paths, m.ReadPaths(ctx)...)
+}
+
+// resolveSessionRoot returns the absolute, symlink-free form of dir, which
+// must be a directory inside one of SessionRootDirs.
+func resolveSessionRoot(dir string) (string, error) {
+	if len(SessionRootDirs) == 0 {
+		return "", errors.New("session roots are disabled, start the server with -session-roots")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+	for _, parent := range SessionRootDirs {
+		if p, err := filepath.EvalSymlinks(parent); err == nil {
+			parent = p
+		}
+		if root == parent || strings.HasPrefix(root, parent+string(os.PathSeparator)) {
+			return root, nil
+		}
+	}
+	return "", fmt.Errorf("%s is outside the session roots", dir)
+}
+
+// acquireProject returns the Project of root, created with the language
+// servers of sessionLanguages on first use.
+func acquireProject(root string) *Project {
+	projectsMu.Lock()
+	defer projectsMu.Unlock()
+	if p, ok := projects[root]; ok {
+		p.sessions++
+		return p
+	}
+
+	languages, disabled := sessionLanguages(root)
+	p := &Project{Root: root, LSP: NewLSPManager(root, languages, disabled), sessions: 1}
+	projects[root] = p
+	go p.LSP.Clients(context.Background())
+	return p
+}
+
+// sessionLanguages returns the languages of the session root, and the
+// disabled ones. A remote client picks the root, so server commands and
+// their environment come from the global config, as LoadConfig enforces:
+// the root config only selects and tunes them (extensions, markers, build
+// tags).
+func sessionLanguages(root string) ([]Language, map[string]bool) {
+	cfg, err := LoadConfig(root)
+	if err != nil {
+		slog.Warn("cannot load the config of the session root", "root", root, "err", err)
+	}
+	languages, disabled := cfg.Languages()
+	if LSP != nil {
+		// Languages disabled for the server stay disabled
+		for name := range LSP.disabled {
+			disabled[name] = true
+		}
+	}
+	return languages, disabled
+}
+
+// releaseProject drops a session of p, shutting its language servers down
+// with the last one.
+func releaseProject(p *Project) {
+	projectsMu.Lock()
+	defer projectsMu.Unlock()
+	if p.sessions--; p.sessions > 0 {
+		return
+	}
+	delete(projects, p.Root)
+	p.LSP.Shutdown()
+}
+
+// handleSessionProjects registers the hooks binding a session to the project
+// of the RootHeader of its initialize request, and releasing it with the
+// session. Sessions without the header, or naming rootPath, use rootPath.
+func handleSessionProjects(hooks *server.Hooks, rootPath string) {
+	hooks.AddAfterInitialize(func(ctx context.Context, _ any, request *mcp.InitializeRequest, _ *mcp.InitializeResult) {
+		session := server.ClientSessionFromContext(ctx)
+		dir := request.Header.Get(RootHeader)
+		if session == nil || dir == "" {
+			return
+		}
+		root, err := resolveSessionRoot(dir)
+		if err != nil {
+			// Rejected by checkSessionRoot already
+			slog.Warn("invalid session root", "session", session.SessionID(), "root", dir, "err", err)
+			return
+		}
+		if root == rootPath {
+			return
+		}
+		p := acquireProject(root)
+		if old, loaded := sessionProjects.Swap(session.SessionID(), p); loaded {
+			releaseProject(old.(*Project))
+		}
+		slog.Info("session project", "session", session.SessionID(), "root", root)
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		if p, ok := sessionProjects.LoadAndDelete(session.SessionID()); ok {
+			releaseProject(p.(*Project))
+		}
+	})
+}
+
+// sessionProject returns the project the MCP session of ctx is bound to, or
+// nil.
+func sessionProject(ctx context.Context) *Project {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return nil
+	}
+	if p, ok := sessionProjects.Load(session.SessionID()); ok {
+		return p.(*Project)
+	}
+	return nil
+}
+
+// projectRoot returns the root of the project of the MCP session of ctx,
+// root otherwise.
+func projectRoot(ctx context.Context, root string) string {
+	if p := sessionProject(ctx); p != nil {
+		return p.Root
+	}
+	return root
+}
+
+// projectLSP returns the language server manager of the project of the MCP
+// session of ctx, LSP otherwise.
+func projectLSP(ctx context.Context) *LSPManager {
+	if p := sessionProject(ctx); p != nil {
+		return p.LSP
+	}
+	return LSP
+}
+
+// fileLSP returns the language server manager of the project holding
+// absPath: a session project, LSP otherwise.
+func fileLSP(absPath string) *LSPManager {
+	projectsMu.Lock()
+	defer projectsMu.Unlock()
+	for root, p := range projects {
+		if absPath == root || strings.HasPrefix(absPath, root+string(os.PathSeparator)) {
+			return p.LSP
+		}
+	}
+	return LSP
+}
+
+// allowedPrefixes returns the read_file allowlist of the MCP session of ctx.
+func allowedPrefixes(ctx context.Context) []string {
+	if p := sessionProject(ctx); p != nil {
+		return slices.Concat(p.ReadPaths(), sharedPathPrefixes)
+	}
+	return AllowedPathPrefixes
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionLanguagesIgnoreRootCommands(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	root := t.TempDir()
+	config := `
+language_servers:
+  go:
+    command: ["sh", "-c", "touch /tmp/pwned"]
+    env:
+      GOFLAGS: -toolexec=/tmp/evil
+  evil:
+    command: ["sh", "-c", "touch /tmp/pwned"]
+    markers: ["go.mod"]
+`
+	if err := os.WriteFile(filepath.Join(root, ConfigFile), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	languages, _ := sessionLanguages(root)
+	for _, lang := range languages {
+		for _, def := range DefaultLanguages {
+			if def.Name == lang.Name && len(lang.Command) > 0 && lang.Command[0] != def.Command[0] {
+				t.Errorf("%s command = %q, want %q", lang.Name, lang.Command, def.Command)
+			}
+		}
+		if lang.Name == "evil" && len(lang.Command) != 0 {
+			t.Errorf("evil command = %q, want none", lang.Command)
+		}
+		for _, kv := range lang.Env {
+			if kv == "GOFLAGS=-toolexec=/tmp/evil" {
+				t.Errorf("%s env = %q", lang.Name, lang.Env)
+			}
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Shard is a partition of a project (a top-level directory or a go.work
+// module) searched by its own goroutine. It caches the symbols extracted
+// from its files so repeated searches only re-parse modified files.
+type Shard struct {
+	Name string // Directory relative to the project root, "." for top-level files
+
+	mu      sync.Mutex
+	symbols map[string]symbolCacheEntry // Keyed by absolute path
+	hits    int                         // Symbols served from the cache
+	misses  int                         // Symbols extracted
+	updated time.Time                   // End of the last search
+}
+
+// symbolCacheEntry holds the symbols of a file along with the fingerprint
+// used to detect modifications.
+type symbolCacheEntry struct {
+	modTime time.Time
+	size    int64
+	symbols []Symbol
+}
+
+// shardFiles associates a shard with the files it owns for one search.
+type shardFiles struct {
+	shard *Shard
+	files []string
+}
+
+// shardRegistry keeps shards alive across searches, keyed by project root
+// then by shard name.
+var shardRegistry = struct {
+	sync.Mutex
+	roots map[string]map[string]*Shard
+}{roots: make(map[string]map[string]*Shard)}
+
+// getShard returns the shard called name for root, creating it if needed.
+func getShard(root string, name string) *Shard {
+	shardRegistry.Lock()
+	defer shardRegistry.Unlock()
+
+	shards, ok := shardRegistry.roots[root]
+	if !ok {
+		shards = make(map[string]*Shard)
+		shardRegistry.roots[root] = shards
+	}
+	sh, ok := shards[name]
+	if !ok {
+		sh = &Shard{Name: name, symbols: make(map[string]symbolCacheEntry)}
+		shards[name] = sh
+	}
+	return sh
+}
+
+// dropShards forgets the shards of root, and the symbols they cache.
+func dropShards(root string) {
+	shardRegistry.Lock()
+	delete(shardRegistry.roots, root)
+	shardRegistry.Unlock()
+}
+
+// ShardFiles partitions files (relative to root) by go.work module when root
+// holds a go.work file, and by top-level directory otherwise. Files outside
+// every module fall back to their top-level directory.
+// Shards are returned sorted by name.
+func ShardFiles(root string, files []string) []shardFiles {
+	modules := goWorkModules(root)
+
+	byName := make(map[string][]string)
+	for _, f := range files {
+		if f == "" {
+			continue
+		}
+		name := shardName(filepath.ToSlash(f), modules)
+		byName[name] = append(byName[name], f)
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]shardFiles, 0, len(names))
+	for _, name := range names {
+		result = append(result, shardFiles{shard: getShard(root, name), files: byName[name]})
+	}
+	return result
+}
+
+// shardName returns the shard owning relPath: the deepest module directory
+// containing it, or else its top-level directory.
+func shardName(relPath string, modules []string) string {
+	best := ""
+	for _, m := range modules {
+		if m != "." && strings.HasPrefix(relPath, m+"/") && len(m) > len(best) {
+			best = m
+		}
+	}
+	if best != "" {
+		return best
+	}
+	if i := strings.IndexByte(relPath, '/'); i > 0 {
+		return relPath[:i]
+	}
+	return "."
+}
+
+// goWorkModules returns the module directories (relative, slash separated)
+// listed by the use directives of root/go.work, or nil without a go.work.
+func goWorkModules(root string) []string {
+	f, err := os.Open(filepath.Join(root, "go.work"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var modules []string
+	inBlock := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+		case inBlock && line != "":
+			modules = append(modules, cleanModuleDir(line))
+		case line == "use (":
+			inBlock = true
+		case strings.HasPrefix(line, "use "):
+			modules = append(modules, cleanModuleDir(strings.TrimPrefix(line, "use ")))
+		}
+	}
+	return modules
+}
+
+// outsideModules returns the go.work modules that live outside root,
+// e.g. "../shared".
+func outsideModules(root string) []string {
+	var outside []string
+	for _, m := range goWorkModules(root) {
+		if m == ".." || strings.HasPrefix(m, "../") {
+			outside = append(outside, m)
+		}
+	}
+	return outside
+}
+
+// goWorkFolders returns the absolute directories of the go.work modules,
+// sent to gopls as workspace folders.
+func goWorkFolders(root string) []string {
+	var folders []string
+	for _, m := range goWorkModules(root) {
+		if m != "." {
+			folders = append(folders, filepath.Join(root, filepath.FromSlash(m)))
+		}
+	}
+	return folders
+}
+
+// goWorkOutside returns the absolute directories of the go.work modules
+// outside root, which read_file may access.
+func goWorkOutside(root string) []string {
+	var dirs []string
+	for _, m := range outsideModules(root) {
+		dirs = append(dirs, filepath.Join(root, filepath.FromSlash(m)))
+	}
+	return dirs
+}
+
+func cleanModuleDir(dir string) string {
+	dir = strings.Trim(strings.TrimSpace(dir), `"`+"`")
+	return filepath.ToSlash(filepath.Clean(dir))
+}
+
+// search scores the files of the shard, in batches of scoreBatchSize files,
+// acquiring sem for every file so that all shards together respect Workers.
+// Cache entries of files no longer present in the shard are dropped.
+func (sh *Shard) search(ctx context.Context, sem chan struct{}, root string, files []string, terms []string, queryLower string) []FileScore {
+	var results []FileScore
+	scores := make([]FileScore, len(files))
+	progress := searchProgress(ctx)
+
+	for start := 0; start < len(files); start += scoreBatchSize {
+		if ctx.Err() != nil {
+			return results
+		}
+		end := min(start+scoreBatchSize, len(files))
+
+		var wg sync.WaitGroup
+		for i := start; i < end; i++ {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				score, reasons := ScoreFile(ctx, sh, root, files[i], terms, queryLower)
+				scores[i] = FileScore{Path: files[i], Score: score, Reasons: reasons}
+				progress.fileScanned()
+			}(i)
+		}
+		wg.Wait()
+
+		// Keep file order stable regardless of goroutine scheduling
+		for _, fs := range scores[start:end] {
+			if fs.Score > 0 {
+				results = append(results, fs)
+			}
+		}
+
+		// Give the scheduler a chance to run other work (gopls client, MCP I/O)
+		runtime.Gosched()
+	}
+
+	sh.prune(root, files)
+	return results
+}
+
+// Symbols returns the symbols of absPath, calling extract only when the file
+// changed since it was last cached. A nil shard does not cache.
+func (sh *Shard) Symbols(ctx context.Context, absPath string, extract func(context.Context, string) []Symbol) []Symbol {
+	if sh == nil {
+		return extract(ctx, absPath)
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil
+	}
+
+	sh.mu.Lock()
+	entry, ok := sh.symbols[absPath]
+	fresh := ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size()
+	if fresh {
+		sh.hits++
+	} else {
+		sh.misses++
+	}
+	sh.mu.Unlock()
+	if fresh {
+		return entry.symbols
+	}
+	if ok {
+		// Edited since the last search, keep the language servers in sync
+		fileLSP(absPath).FileChanged(absPath)
+	}
+
+	symbols := extract(ctx, absPath)
+	if ctx.Err() != nil {
+		// Extraction may have been cut short, don't cache it
+		return symbols
+	}
+	sh.mu.Lock()
+	sh.symbols[absPath] = symbolCacheEntry{modTime: info.ModTime(), size: info.Size(), symbols: symbols}
+	sh.mu.Unlock()
+	return symbols
+}
+
+// prune drops cache entries for files that are no longer part of the shard.
+func (sh *Shard) prune(root string, files []string) {
+	keep := make(map[string]bool, len(files))
+	for _, f := range files {
+		keep[filepath.Join(root, f)] = true
+	}
+
+	var removed []string
+	sh.mu.Lock()
+	for path := range sh.symbols {
+		if !keep[path] {
+			delete(sh.symbols, path)
+			removed = append(removed, path)
+		}
+	}
+	sh.updated = time.Now()
+	sh.mu.Unlock()
+
+	for _, path := range removed {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			fileLSP(path).FileChanged(path)
+		}
+	}
+}
+
+// IndexStats describes the symbol index of a project, the shards of its
+// root.
+type IndexStats struct {
+	Shards  int `json:"shards"`
+	Files   int `json:"files"`
+	Symbols int `json:"symbols"`
+	// Updated is the end of the last search, which refreshes the index
+	// (RFC 3339), empty before the first one.
+	Updated string `json:"updated,omitempty"`
+	// CacheHits are the files whose symbols came from the index,
+	// CacheMisses the files parsed, new or modified.
+	CacheHits    int     `json:"cache_hits"`
+	CacheMisses  int     `json:"cache_misses"`
+	CacheHitRate float64 `json:"cache_hit_rate"`
+}
+
+// ShardStats returns the statistics of the symbol index of root.
+func ShardStats(root string) IndexStats {
+	shardRegistry.Lock()
+	shards := make([]*Shard, 0, len(shardRegistry.roots[root]))
+	for _, sh := range shardRegistry.roots[root] {
+		shards = append(shards, sh)
+	}
+	shardRegistry.Unlock()
+
+	stats := IndexStats{Shards: len(shards)}
+	var updated time.Time
+	for _, sh := range shards {
+		sh.mu.Lock()
+		stats.Files += len(sh.symbols)
+		for _, entry := range sh.symbols {
+			stats.Symbols += len(entry.symbols)
+		}
+		stats.CacheHits += sh.hits
+		stats.CacheMisses += sh.misses
+		if sh.updated.After(updated) {
+			updated = sh.updated
+		}
+		sh.mu.Unlock()
+	}
+	if !updated.IsZero() {
+		stats.Updated = updated.Format(time.RFC3339)
+	}
+	if total := stats.CacheHits + stats.CacheMisses; total > 0 {
+		stats.CacheHitRate = float64(stats.CacheHits) / float64(total)
+	}
+	return stats
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	shFuncRe      = regexp.MustCompile(`^\s*(?:function\s+)?([A-Za-z_][\w:.-]*)\s*\(\s*\)`)
+	shKeywordRe   = regexp.MustCompile(`^\s*function\s+([A-Za-z_][\w:.-]*)\s*\{?\s*$`)
+	makeTargetRe  = regexp.MustCompile(`^([^\s:#=][^:#=]*?)\s*::?(?:\s|$)`)
+	justRecipeRe  = regexp.MustCompile(`^@?([A-Za-z_][\w-]*)(?:\s+[^:=]*)?:(?:\s|$)`)
+	justKeywordRe = regexp.MustCompile(`^(?:alias|set|export|import|mod)\s`)
+)
+
+// ExtractShellSymbols returns the functions defined in a shell script,
+// in both the "name() {" and "function name {" forms.
+func ExtractShellSymbols(ctx context.Context, absPath string) []Symbol {
+	return extractLines(ctx, absPath, func(line string) (Symbol, bool) {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			return Symbol{}, false
+		}
+		if m := shFuncRe.FindStringSubmatch(line); m != nil {
+			return Symbol{Kind: "func", Name: m[1]}, true
+		}
+		if m := shKeywordRe.FindStringSubmatch(line); m != nil {
+			return Symbol{Kind: "func", Name: m[1]}, true
+		}
+		return Symbol{}, false
+	})
+}
+
+// ExtractMakefileSymbols returns the explicit targets of a Makefile.
+// Special targets (.PHONY...) and pattern rules are skipped.
+func ExtractMakefileSymbols(ctx context.Context, absPath string) []Symbol {
+	var symbols []Symbol
+	extractLines(ctx, absPath, func(line string) (Symbol, bool) {
+		m := makeTargetRe.FindStringSubmatch(line)
+		if m == nil {
+			return Symbol{}, false
+		}
+		// "a b: deps" declares several targets
+		for _, target := range strings.Fields(m[1]) {
+			if strings.HasPrefix(target, ".") || strings.ContainsAny(target, "%$") {
+				continue
+			}
+			symbols = append(symbols, Symbol{Kind: "target", Name: target})
+		}
+		return Symbol{}, false
+	})
+	return symbols
+}
+
+// ExtractJustfileSymbols returns the recipes of a Justfile.
+func ExtractJustfileSymbols(ctx context.Context, absPath string) []Symbol {
+	return extractLines(ctx, absPath, func(line string) (Symbol, bool) {
+		if justKeywordRe.MatchString(line) {
+			return Symbol{}, false
+		}
+		if m := justRecipeRe.FindStringSubmatch(line); m != nil {
+			return Symbol{Kind: "target", Name: m[1]}, true
+		}
+		return Symbol{}, false
+	})
+}
+
+// extractLines reads absPath and collects the symbols match finds line by line.
+func extractLines(ctx context.Context, absPath string, match func(line string) (Symbol, bool)) []Symbol {
+	if ctx.Err() != nil {
+		return nil
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil
+	}
+
+	var symbols []Symbol
+	for _, line := range strings.Split(string(content), "\n") {
+		if sym, ok := match(line); ok {
+			symbols = append(symbols, sym)
+		}
+	}
+	return symbols
+}
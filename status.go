@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ServerStatus describes the state of a language server, as reported by the
+// index_status tool and the --status flag.
+type ServerStatus struct {
+	Language string `json:"language"`
+	Name     string `json:"name,omitempty"`
+	Version  string `json:"version,omitempty"`
+	// State is "running", "starting", "not started" or "failed"
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+
+	// Loading is true while the server reports work in progress: results
+	// may be incomplete until it is false.
+	Loading  bool           `json:"loading"`
+	Progress []WorkProgress `json:"progress,omitempty"`
+
+	// Reported by gopls only
+	Packages    int    `json:"packages,omitempty"`
+	MemoryBytes uint64 `json:"memory_bytes,omitempty"`
+}
+
+// IndexStatus is the structured content of the index_status tool.
+type IndexStatus struct {
+	Servers []ServerStatus `json:"servers"`
+}
+
+// Status returns the state of the server, asking it for statistics when it
+// supports them.
+func (c *LSPClient) Status(ctx context.Context) ServerStatus {
+	progress := c.Progress()
+	status := ServerStatus{
+		Name:     c.Name(),
+		Version:  c.Version(),
+		State:    "running",
+		Loading:  len(progress) > 0,
+		Progress: progress,
+	}
+	goplsStats(ctx, c, &status)
+	return status
+}
+
+// Status returns the state of the servers of the detected languages.
+// Servers are not started by it.
+func (m *LSPManager) Status(ctx context.Context) []ServerStatus {
+	if m == nil {
+		return nil
+	}
+	var statuses []ServerStatus
+	for _, lang := range m.Detected(ctx) {
+		m.mu.Lock()
+		srv, ok := m.servers[lang.Name]
+		var client *LSPClient
+		var err error
+		if ok {
+			client, err = srv.client, srv.err
+		}
+		m.mu.Unlock()
+
+		var status ServerStatus
+		switch {
+		case client != nil:
+			status = client.Status(ctx)
+		case err != nil:
+			status = ServerStatus{State: "failed", Error: err.Error()}
+		case ok:
+			status = ServerStatus{State: "starting", Loading: true}
+		default:
+			status = ServerStatus{State: "not started"}
+		}
+		status.Language = lang.Name
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// indexStatusTool reports whether the language servers finished indexing.
+func indexStatusTool() (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("index_status",
+		mcp.WithDescription("Report the state of the language servers (gopls...) used for dependency search: whether they are still loading the workspace, loaded packages and memory usage. Results of search_files may be incomplete while a server is loading."),
+		mcp.WithOutputSchema[IndexStatus](),
+		readOnlyAnnotations("Index status"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		statuses := projectLSP(ctx).Status(ctx)
+		if statuses == nil {
+			statuses = []ServerStatus{}
+		}
+		// The text stays the bare list, as before the structured content
+		return jsonToolResult(IndexStatus{Servers: statuses}, statuses), nil
+	}
+}
+
+// serverStart is the start of the process, for the uptime of the status
+// tool.
+var serverStart = time.Now()
+
+// ServerHealth is the result of the status tool.
+type ServerHealth struct {
+	Build      BuildInfo `json:"build"`
+	Uptime     string    `json:"uptime"`
+	Root       string    `json:"root"`
+	HeapBytes  uint64    `json:"heap_bytes"` // Of codemcp, language servers excluded
+	Goroutines int       `json:"goroutines"`
+
+	Index           IndexStats     `json:"index"`
+	LanguageServers []ServerStatus `json:"language_servers"`
+	Config          ConfigSummary  `json:"config"`
+
+	// Warnings are the likely causes of empty or incomplete results.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ConfigSummary is the configuration in effect, as reported by the status
+// tool.
+type ConfigSummary struct {
+	Mode           string   `json:"mode"`
+	Tools          []string `json:"tools"`
+	ReadPaths      []string `json:"read_paths"`
+	ClientRoots    bool     `json:"client_roots"`
+	Workers        int      `json:"workers"`
+	MaxResults     int      `json:"max_results"`
+	MaxResultBytes int      `json:"max_result_bytes"`
+	CallsPerMinute int      `json:"calls_per_minute"`
+	BytesPerMinute int      `json:"bytes_per_minute"`
+	LSPTimeout     string   `json:"lsp_timeout"`
+	Audit          bool     `json:"audit"`
+}
+
+// statusTool reports the health of the server, to debug empty results
+// without restarting it.
+func statusTool(s *server.MCPServer, rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("status",
+		mcp.WithDescription("Report the health of the server: version, uptime and memory, liveness and memory of the language servers (gopls...), size, freshness and cache hit rate of the symbol index, and the configuration in effect, with warnings about the likely causes of empty or incomplete search results."),
+		mcp.WithOutputSchema[ServerHealth](),
+		readOnlyAnnotations("Server status"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		root := projectRoot(ctx, rootPath)
+		var mem runtime.MemStats
+		toolsMu.Lock()
+		mode := Mode
+		toolsMu.Unlock()
+		runtime.ReadMemStats(&mem)
+
+		health := ServerHealth{
+			Build:           ReadBuildInfo(),
+			Uptime:          time.Since(serverStart).Round(time.Second).String(),
+			Root:            root,
+			HeapBytes:       mem.HeapInuse,
+			Goroutines:      runtime.NumGoroutine(),
+			Index:           ShardStats(root),
+			LanguageServers: projectLSP(ctx).Status(ctx),
+			Config: ConfigSummary{
+				Mode:           mode,
+				ReadPaths:      allowedPrefixes(ctx),
+				ClientRoots:    ClientRoots,
+				Workers:        Workers,
+				MaxResults:     MaxResults,
+				MaxResultBytes: MaxResultBytes,
+				CallsPerMinute: CallsPerMinute,
+				BytesPerMinute: BytesPerMinute,
+				LSPTimeout:     CallTimeout.String(),
+				Audit:          AuditEnabled,
+			},
+		}
+		if health.LanguageServers == nil {
+			health.LanguageServers = []ServerStatus{}
+		}
+		for name := range s.ListTools() {
+			health.Config.Tools = append(health.Config.Tools, name)
+		}
+		slices.Sort(health.Config.Tools)
+
+		if !slices.Contains(health.Config.Tools, "search_files") {
+			health.Warnings = append(health.Warnings, "search_files is disabled")
+		}
+		if health.Index.Updated == "" {
+			health.Warnings = append(health.Warnings, "no search ran yet, the symbol index is empty")
+		} else if health.Index.Files == 0 {
+			health.Warnings = append(health.Warnings, "the last search found no project file: check the root, ignore_dirs and .codemcpignore")
+		}
+		for _, st := range health.LanguageServers {
+			switch {
+			case st.State == "failed":
+				health.Warnings = append(health.Warnings, fmt.Sprintf("%s language server failed: %s", st.Language, st.Error))
+			case st.Loading:
+				health.Warnings = append(health.Warnings, fmt.Sprintf("%s language server is loading, dependency results may be incomplete", st.Language))
+			}
+		}
+		return jsonToolResult(health, health), nil
+	}
+}
+
+// printStatus prints the state of the language servers for --status.
+func printStatus(statuses []ServerStatus, asJson bool) {
+	if asJson {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(statuses)
+		return
+	}
+
+	if len(statuses) == 0 {
+		fmt.Println("No language server for this project")
+		return
+	}
+	fmt.Printf("%-12s | %-30s | %-11s | %s\n", "LANGUAGE", "SERVER", "STATE", "DETAILS")
+	fmt.Println(strings.Repeat("-", 100))
+	for _, st := range statuses {
+		server := strings.TrimSpace(st.Name + " " + st.Version)
+		var details []string
+		if st.Error != "" {
+			details = append(details, st.Error)
+		}
+		if st.Loading {
+			details = append(details, "loading")
+		}
+		for _, p := range st.Progress {
+			detail := strings.TrimSpace(p.Title + " " + p.Message)
+			if p.Percentage > 0 {
+				detail += fmt.Sprintf(" (%d%%)", p.Percentage)
+			}
+			if detail != "" {
+				details = append(details, detail)
+			}
+		}
+		if st.Packages > 0 {
+			details = append(details, fmt.Sprintf("%d packages", st.Packages))
+		}
+		if st.MemoryBytes > 0 {
+			details = append(details, fmt.Sprintf("%d MiB", st.MemoryBytes>>20))
+		}
+		fmt.Printf("%-12s | %-30s | %-11s | %s\n", st.Language, server, st.State, strings.Join(details, ", "))
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// SymbolURITemplate is the URI template of the symbol resources. package is
+// a directory relative to the project root ("." for the root package) or an
+// import path, name a function, type, variable, constant or Type.Method.
+const SymbolURITemplate = "symbol://{+package}/{name}"
+
+// GoDecl is the source of a top-level Go declaration.
+type GoDecl struct {
+	Path    string // Absolute path of the file
+	Line    int    // 1-based first line, including the doc comment
+	EndLine int
+	Source  string
+}
+
+// symbolResourceTemplate returns the symbol resource template and its
+// handler, serving the declaration source of a Go symbol.
+func symbolResourceTemplate(rootPath string) (mcp.ResourceTemplate, server.ResourceTemplateHandlerFunc) {
+	template := mcp.NewResourceTemplate(SymbolURITemplate, "Go symbol",
+		mcp.WithTemplateDescription("Source of one Go declaration (with its doc comment): symbol://internal/auth/Login, symbol://net/http/Client.Do. The package is a directory of the project or an import path."),
+		mcp.WithTemplateMIMEType("text/x-go"),
+	)
+
+	return template, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		pkg := templateArgument(request.Params.Arguments["package"])
+		name := templateArgument(request.Params.Arguments["name"])
+		if pkg == "" || name == "" {
+			return nil, fmt.Errorf("invalid symbol URI %s, expected %s", request.Params.URI, SymbolURITemplate)
+		}
+
+		dir, err := packageDir(ctx, rootPath, pkg)
+		if err != nil {
+			return nil, err
+		}
+		if !isAllowedPath(ctx, dir) {
+			return nil, fmt.Errorf("access denied: package %s is outside the project and its dependencies", pkg)
+		}
+		decl, err := FindGoDecl(ctx, dir, name)
+		if err != nil {
+			return nil, err
+		}
+		text, _ := RedactSecrets(decl.Path, decl.Source)
+
+		path := decl.Path
+		if rel, err := filepath.Rel(rootPath, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		return []mcp.ResourceContents{mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "text/x-go",
+			Text:     text,
+			Meta:     map[string]any{"path": path, "line": decl.Line, "end_line": decl.EndLine},
+		}}, nil
+	}
+}
+
+// templateArgument returns a URI template variable as a string. The server
+// passes them as string slices.
+func templateArgument(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case []string:
+		return strings.Join(x, "/")
+	}
+	return ""
+}
+
+// packageDir returns the directory of pkg: a directory relative to root when
+// it exists, otherwise an import path resolved by go list (project, module
+// cache or standard library).
+func packageDir(ctx context.Context, root string, pkg string) (string, error) {
+	if !filepath.IsAbs(pkg) {
+		dir := filepath.Join(root, filepath.FromSlash(pkg))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "go", "list", "-find", "-f", "{{.Dir}}", "--", pkg)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), Build.Env()...)
+	out, err := cmd.Output()
+	dir := strings.TrimSpace(string(out))
+	if err != nil || dir == "" {
+		return "", fmt.Errorf("package %s not found", pkg)
+	}
+	return dir, nil
+}
+
+// FindGoDecl returns the declaration of name in the Go package of dir.
+// name is a top-level identifier or Type.Method. Non-test files are searched
+// first.
+func FindGoDecl(ctx context.Context, dir string, name string) (*GoDecl, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files, tests []string
+	for _, e := range entries {
+		switch n := e.Name(); {
+		case e.IsDir() || !strings.HasSuffix(n, ".go"):
+		case strings.HasSuffix(n, "_test.go"):
+			tests = append(tests, filepath.Join(dir, n))
+		default:
+			files = append(files, filepath.Join(dir, n))
+		}
+	}
+
+	recv, method, isMethod := strings.Cut(name, ".")
+	for _, path := range append(files, tests...) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution|parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		for _, d := range file.Decls {
+			var node ast.Node
+			var doc *ast.CommentGroup
+			switch x := d.(type) {
+			case *ast.FuncDecl:
+				if isMethod && x.Recv != nil && len(x.Recv.List) > 0 && receiverName(x.Recv.List[0].Type) == recv && x.Name.Name == method ||
+					!isMethod && x.Recv == nil && x.Name.Name == name {
+					node, doc = x, x.Doc
+				}
+			case *ast.GenDecl:
+				if !isMethod {
+					node, doc = genDeclSpec(x, name)
+				}
+			}
+			if node == nil {
+				continue
+			}
+			start := node.Pos()
+			if doc != nil {
+				start = doc.Pos()
+			}
+			from, to := fset.Position(start), fset.Position(node.End())
+			return &GoDecl{
+				Path:    path,
+				Line:    from.Line,
+				EndLine: to.Line,
+				Source:  string(src[from.Offset:to.Offset]),
+			}, nil
+		}
+	}
+	return nil, errors.New("symbol " + name + " not found in " + dir)
+}
+
+// genDeclSpec returns the node to print for name in d: the whole declaration
+// when it declares only name, the spec within a group otherwise.
+func genDeclSpec(d *ast.GenDecl, name string) (ast.Node, *ast.CommentGroup) {
+	for _, spec := range d.Specs {
+		var doc *ast.CommentGroup
+		found := false
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			found, doc = s.Name.Name == name, s.Doc
+		case *ast.ValueSpec:
+			for _, n := range s.Names {
+				found = found || n.Name == name
+			}
+			doc = s.Doc
+		}
+		if !found {
+			continue
+		}
+		if len(d.Specs) == 1 && !d.Lparen.IsValid() {
+			return d, d.Doc
+		}
+		return spec, doc
+	}
+	return nil, nil
+}
+
+// receiverName returns the type name of a method receiver (T, *T, T[K]...).
+func receiverName(expr ast.Expr) string {
+	switch x := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(x.X)
+	case *ast.IndexExpr:
+		return receiverName(x.X)
+	case *ast.IndexListExpr:
+		return receiverName(x.X)
+	case *ast.Ident:
+		return x.Name
+	}
+	return ""
+}
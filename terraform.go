@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// hclBlockRe matches the header of a top-level labelled HCL block,
+// e.g. `resource "aws_s3_bucket" "policy" {`.
+var (
+	hclBlockRe = regexp.MustCompile(`^([A-Za-z_][\w-]*)((?:\s+"[^"]*")+)\s*\{`)
+	hclLabelRe = regexp.MustCompile(`"([^"]*)"`)
+)
+
+// ExtractTerraformSymbols returns the labelled blocks of a .tf file named
+// after their Terraform address (aws_s3_bucket.policy, module.vpc,
+// var.region, data.aws_ami.ubuntu, output.id), with the "tf" kind.
+func ExtractTerraformSymbols(ctx context.Context, absPath string) []Symbol {
+	return extractHCLBlocks(ctx, absPath, "tf", func(block string, labels []string) string {
+		switch block {
+		case "resource":
+			return strings.Join(labels, ".")
+		case "variable":
+			return "var." + strings.Join(labels, ".")
+		}
+		return block + "." + strings.Join(labels, ".")
+	})
+}
+
+// ExtractHCLSymbols returns the labelled blocks of a generic .hcl file
+// (Terragrunt, Nomad, Packer...) as block.label, with the "hcl" kind.
+func ExtractHCLSymbols(ctx context.Context, absPath string) []Symbol {
+	return extractHCLBlocks(ctx, absPath, "hcl", func(block string, labels []string) string {
+		return block + "." + strings.Join(labels, ".")
+	})
+}
+
+// extractHCLBlocks scans the unindented block headers of an HCL file, naming
+// each with name(blockType, labels).
+func extractHCLBlocks(ctx context.Context, absPath string, kind string, name func(string, []string) string) []Symbol {
+	if ctx.Err() != nil {
+		return nil
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil
+	}
+
+	var symbols []Symbol
+	for _, line := range strings.Split(string(content), "\n") {
+		m := hclBlockRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		var labels []string
+		for _, l := range hclLabelRe.FindAllStringSubmatch(m[2], -1) {
+			labels = append(labels, l[1])
+		}
+		symbols = append(symbols, Symbol{Kind: kind, Name: name(m[1], labels)})
+	}
+	return symbols
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// TodoTags are the comment markers find_todos looks for.
+var TodoTags = []string{"TODO", "FIXME", "HACK", "XXX"}
+
+// Limits of the number of comments returned by find_todos.
+const (
+	defaultTodos = 200
+	maxTodos     = 2000
+)
+
+// todoComment matches a marker of TodoTags as a word, after a comment
+// leader (//, #, /*, *, --, ;, <!--) on the line.
+var todoComment = regexp.MustCompile(`(?://|#|/\*|\*|--|;|<!--).*?\b(` + strings.Join(TodoTags, "|") + `)\b(.*)`)
+
+// Todo is a TODO-like comment.
+type Todo struct {
+	Line   int    `json:"line"`
+	Tag    string `json:"tag"`
+	Text   string `json:"text"`
+	Author string `json:"author,omitempty"` // From git blame, of committed lines
+	Date   string `json:"date,omitempty"`
+}
+
+// TodoFile is a file and its TODO-like comments, in line order.
+type TodoFile struct {
+	Path  string `json:"path"`
+	Todos []Todo `json:"todos"`
+}
+
+// TodoReport is the result of the find_todos tool.
+type TodoReport struct {
+	Files []TodoFile `json:"files"`
+	Total int        `json:"total"`
+}
+
+// FindTodos returns the first limit TODO-like comments of the files of root,
+// under absPath when set, grouped by file, those containing term
+// (case-insensitive) only when set.
+func FindTodos(ctx context.Context, root string, absPath string, term string, limit int) ([]TodoFile, int, error) {
+	// Grep finds the candidate lines, todoComment keeps the comments
+	matches, err := Grep(ctx, root, strings.Join(TodoTags, "|"), false, absPath, maxTodos*10)
+	if err != nil {
+		return nil, 0, err
+	}
+	term = strings.ToLower(term)
+	var files []TodoFile
+	total := 0
+	for _, m := range matches {
+		sub := todoComment.FindStringSubmatch(m.Text)
+		if sub == nil {
+			continue
+		}
+		text := strings.TrimSpace(sub[1] + sub[2])
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(text, "-->"), "*/"))
+		if term != "" && !strings.Contains(strings.ToLower(text), term) {
+			continue
+		}
+		if total++; total > limit {
+			continue
+		}
+		text, _ = RedactSecrets(m.Path, text)
+		if len(files) == 0 || files[len(files)-1].Path != m.Path {
+			files = append(files, TodoFile{Path: m.Path})
+		}
+		f := &files[len(files)-1]
+		f.Todos = append(f.Todos, Todo{Line: m.Line, Tag: sub[1], Text: text})
+	}
+	return files, total, nil
+}
+
+// blameTodos sets the author and date of the comments of f, in root, from
+// git blame.
+func blameTodos(ctx context.Context, root string, f *TodoFile) {
+	args := []string{"blame", "--line-porcelain"}
+	for _, t := range f.Todos {
+		args = append(args, "-L", fmt.Sprintf("%d,%d", t.Line, t.Line))
+	}
+	cmd := exec.CommandContext(ctx, "git", append(args, "--", f.Path)...)
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		// Untracked file, or outside git
+		return
+	}
+
+	// Per line: "<sha> <orig line> <final line> [<count>]", headers, then
+	// the line content after a tab
+	byLine := make(map[int]*Todo)
+	for i := range f.Todos {
+		byLine[f.Todos[i].Line] = &f.Todos[i]
+	}
+	var cur *Todo
+	var uncommitted bool
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			cur = nil
+		case cur == nil:
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			n, _ := strconv.Atoi(fields[2])
+			cur = byLine[n]
+			uncommitted = strings.Trim(fields[0], "0") == ""
+		case uncommitted:
+		case strings.HasPrefix(line, "author "):
+			cur.Author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-time "):
+			if sec, err := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64); err == nil {
+				cur.Date = time.Unix(sec, 0).UTC().Format("2006-01-02")
+			}
+		}
+	}
+}
+
+// todosTool lists the TODO-like comments of the project.
+func todosTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("find_todos",
+		mcp.WithDescription(fmt.Sprintf("Find the %s comments of the project files, grouped by file, with the author and date of each line from git blame. Use it to review technical debt, or the pending work around code.", strings.Join(TodoTags, "/"))),
+		mcp.WithString("term", mcp.Description("Only return the comments containing this text (case-insensitive), e.g. FIXME or a user name")),
+		mcp.WithString("path", mcp.Description("Limit the search to this file or directory (absolute or relative to project root)")),
+		mcp.WithBoolean("blame", mcp.Description("Look up the author and date of each comment, true by default")),
+		mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("Number of comments, %d by default, at most %d", defaultTodos, maxTodos))),
+		mcp.WithOutputSchema[TodoReport](),
+		readOnlyAnnotations("Find TODOs"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		var targetPath string
+		if pathArg := request.GetString("path", ""); pathArg != "" {
+			targetPath = resolvePath(rootPath, pathArg)
+			// Security Check
+			if !isAllowedPath(ctx, targetPath) {
+				return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Searching %s is not allowed.", pathArg)), nil
+			}
+		}
+
+		limit := min(max(request.GetInt("limit", defaultTodos), 1), maxTodos)
+		files, total, err := FindTodos(ctx, rootPath, targetPath, request.GetString("term", ""), limit)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("find todos failed: %v", err)), nil
+		}
+		if request.GetBool("blame", true) && inGitWorkTree(rootPath) {
+			for i := range files {
+				blameTodos(ctx, rootPath, &files[i])
+			}
+		}
+		result := TodoReport{Files: files, Total: total}
+		if result.Files == nil {
+			result.Files = []TodoFile{}
+		}
+		for i := range result.Files {
+			result.Files[i].Path = filepath.ToSlash(result.Files[i].Path)
+		}
+		return jsonToolResult(result, result), nil
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AdminEndpoint is the HTTP endpoint changing the exposed tools at runtime.
+const AdminEndpoint = "/admin/tools"
+
+// AdminToken, when set, enables AdminEndpoint, and is the bearer token it
+// requires (AuthToken does not grant access to it).
+// Set via the --admin-token flag or CODEMCP_ADMIN_TOKEN.
+var AdminToken string
+
+// toolsMu guards Mode, EnabledTools and DisabledTools once the server runs.
+var toolsMu sync.Mutex
+
+// ToolSet holds every tool of the server, exposed or not, so the mode and the
+// tool selection can change at runtime. Connected clients get a
+// tools/list_changed notification.
+type ToolSet struct {
+	server *server.MCPServer
+	tools  map[string]server.ServerTool
+}
+
+// ToolChange is a runtime change of the exposed tools: a new Mode, tools to
+// enable and tools to disable, in that order.
+type ToolChange struct {
+	Mode    string   `json:"mode,omitempty"`
+	Enable  []string `json:"enable,omitempty"`
+	Disable []string `json:"disable,omitempty"`
+}
+
+// ToolState is the mode and the tools of the server, as reported by
+// AdminEndpoint.
+type ToolState struct {
+	Mode      string   `json:"mode"`
+	Tools     []string `json:"tools"`     // Exposed
+	Available []string `json:"available"` // Exposed or not
+}
+
+// NewToolSet returns the ToolSet of the tools registered in s, write tools
+// included, and exposes those selected by the mode, EnabledTools and
+// DisabledTools.
+func NewToolSet(s *server.MCPServer) *ToolSet {
+	t := &ToolSet{server: s, tools: make(map[string]server.ServerTool)}
+	for name, tool := range s.ListTools() {
+		t.tools[name] = *tool
+	}
+	toolsMu.Lock()
+	defer toolsMu.Unlock()
+	t.apply()
+	return t
+}
+
+// exposed reports whether the tool name should be listed. toolsMu must be
+// held.
+func (t *ToolSet) exposed(name string) bool {
+	if Mode != ModeReadWrite && slices.Contains(writeToolNames, name) {
+		return false
+	}
+	return toolEnabled(name)
+}
+
+// apply adds the tools to expose missing from the server and deletes the
+// others, with one notification each. toolsMu must be held.
+func (t *ToolSet) apply() {
+	current := t.server.ListTools()
+	var add []server.ServerTool
+	var remove []string
+	for name, tool := range t.tools {
+		switch exposed := t.exposed(name); {
+		case exposed && current[name] == nil:
+			add = append(add, tool)
+		case !exposed && current[name] != nil:
+			remove = append(remove, name)
+		}
+	}
+	if len(remove) > 0 {
+		t.server.DeleteTools(remove...)
+	}
+	if len(add) > 0 {
+		t.server.AddTools(add...)
+	}
+}
+
+// Update applies change, entirely or not at all when a mode or tool name is
+// unknown.
+func (t *ToolSet) Update(change ToolChange) (ToolState, error) {
+	if change.Mode != "" && change.Mode != ModeReadOnly && change.Mode != ModeReadWrite {
+		return ToolState{}, fmt.Errorf("invalid mode %q, expected %s or %s", change.Mode, ModeReadOnly, ModeReadWrite)
+	}
+	for _, name := range slices.Concat(change.Enable, change.Disable) {
+		if _, ok := t.tools[name]; !ok {
+			return ToolState{}, fmt.Errorf("unknown tool %q", name)
+		}
+	}
+
+	toolsMu.Lock()
+	if change.Mode != "" {
+		Mode = change.Mode
+	}
+	for _, name := range change.Enable {
+		delete(DisabledTools, name)
+		if len(EnabledTools) > 0 {
+			EnabledTools[name] = true
+		}
+	}
+	for _, name := range change.Disable {
+		DisabledTools[name] = true
+		delete(EnabledTools, name)
+	}
+	t.apply()
+	toolsMu.Unlock()
+
+	state := t.State()
+	slog.Info("tools changed", "mode", state.Mode, "tools", state.Tools)
+	return state, nil
+}
+
+// State returns the mode and the exposed and available tools.
+func (t *ToolSet) State() ToolState {
+	toolsMu.Lock()
+	defer toolsMu.Unlock()
+	state := ToolState{Mode: Mode, Tools: []string{}}
+	for name := range t.tools {
+		state.Available = append(state.Available, name)
+		if t.exposed(name) {
+			state.Tools = append(state.Tools, name)
+		}
+	}
+	sort.Strings(state.Tools)
+	sort.Strings(state.Available)
+	return state
+}
+
+// adminHandler serves AdminEndpoint: GET returns the ToolState, POST applies
+// the ToolChange of its body and returns the new state.
+func adminHandler(t *ToolSet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := t.State()
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var change ToolChange
+			if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+				http.Error(w, "invalid tool change: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			var err error
+			if state, err = t.Update(change); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(state)
+	})
+}
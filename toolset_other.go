@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "context"
+
+// handleModeSignals does nothing: SIGUSR1 and SIGUSR2 are Unix only.
+func handleModeSignals(ctx context.Context, t *ToolSet) {}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleModeSignals switches the server to read-write mode on SIGUSR1 and
+// back to read-only mode on SIGUSR2, until ctx is done.
+func handleModeSignals(ctx context.Context, t *ToolSet) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				mode := ModeReadOnly
+				if sig == syscall.SIGUSR1 {
+					mode = ModeReadWrite
+				}
+				_, _ = t.Update(ToolChange{Mode: mode})
+			}
+		}
+	}()
+}
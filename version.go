@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/debug"
+)
+
+// version may be set at link time: go build -ldflags "-X main.version=v1.3.0".
+// It defaults to the module version of go install builds.
+var version string
+
+// BuildInfo describes the running binary, for --version and the MCP server
+// version.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // Built from a dirty tree
+	GoVersion string `json:"go_version"`
+}
+
+// ReadBuildInfo returns the version of the binary, from the link time version
+// or the module and VCS information embedded by the go command.
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{Version: version}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		if info.Version == "" {
+			info.Version = "devel"
+		}
+		return info
+	}
+	info.GoVersion = bi.GoVersion
+	if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.Time = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	if info.Version == "" {
+		info.Version = "devel"
+	}
+	return info
+}
+
+// String returns the version, with the short revision of development builds,
+// e.g. "v1.3.0" or "devel-3c90cd8-dirty".
+func (b BuildInfo) String() string {
+	v := b.Version
+	if v == "devel" && b.Revision != "" {
+		v += "-" + b.Revision[:min(7, len(b.Revision))]
+		if b.Modified {
+			v += "-dirty"
+		}
+	}
+	return v
+}
+
+// printVersion prints the build information for --version.
+func printVersion(asJson bool) {
+	info := ReadBuildInfo()
+	if asJson {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(info)
+		return
+	}
+	fmt.Printf("codemcp %s\n", info.Version)
+	if info.Revision != "" {
+		dirty := ""
+		if info.Modified {
+			dirty = " (modified)"
+		}
+		fmt.Printf("revision %s%s %s\n", info.Revision, dirty, info.Time)
+	}
+	fmt.Printf("built with %s\n", info.GoVersion)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// analyzerName matches the name of a go vet analyzer, e.g. printf.
+var analyzerName = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// Finding is a diagnostic of a Go analyzer.
+type Finding struct {
+	Path     string `json:"path"` // Relative to the project root when inside it
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Analyzer string `json:"analyzer"`
+	Message  string `json:"message"`
+}
+
+// VetReport is the result of the vet tool.
+type VetReport struct {
+	Findings []Finding `json:"findings"`
+	Errors   []string  `json:"errors,omitempty"` // Packages that failed to build or type-check
+}
+
+// Vet runs go vet -json in root on pattern (a package pattern, e.g. ./...),
+// with only the analyzers when set, and returns its findings sorted by
+// position.
+func Vet(ctx context.Context, root string, pattern string, analyzers []string) (*VetReport, error) {
+	args := []string{"vet", "-json"}
+	for _, a := range analyzers {
+		if !analyzerName.MatchString(a) {
+			return nil, fmt.Errorf("invalid analyzer name %q", a)
+		}
+		args = append(args, "-"+a)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "go", append(args, "--", pattern)...)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), Build.Env()...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, err
+	}
+	if exitErr != nil && exitErr.ExitCode() == 2 {
+		// Usage error, e.g. an unknown analyzer
+		msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		return nil, errors.New(msg)
+	}
+
+	report := &VetReport{Findings: []Finding{}}
+	// A JSON object per package on stdout, from "{" to "}" at the start of
+	// a line; "# package" headers and build errors on stderr
+	var block []string
+	for _, line := range strings.Split(stdout.String()+"\n"+stderr.String(), "\n") {
+		switch {
+		case block != nil:
+			block = append(block, line)
+			if line == "}" {
+				report.Findings = append(report.Findings, vetFindings(root, strings.Join(block, "\n"))...)
+				block = nil
+			}
+		case line == "{":
+			block = []string{line}
+		case line == "" || strings.HasPrefix(line, "# "):
+		default:
+			report.Errors = append(report.Errors, strings.TrimPrefix(line, "vet: "))
+		}
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return report, nil
+}
+
+// vetFindings returns the findings of a go vet -json object: analyzers by
+// package, each with its diagnostics or an error.
+func vetFindings(root string, text string) []Finding {
+	var byPackage map[string]map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &byPackage); err != nil {
+		return nil
+	}
+	var findings []Finding
+	for _, byAnalyzer := range byPackage {
+		for analyzer, raw := range byAnalyzer {
+			var diags []struct {
+				Posn    string `json:"posn"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(raw, &diags); err != nil {
+				// {"error": "..."} when the analyzer failed
+				continue
+			}
+			for _, d := range diags {
+				f := Finding{Analyzer: analyzer, Message: d.Message}
+				f.Path, f.Line, f.Column = splitPosition(d.Posn)
+				f.Path = projectRelative(root, f.Path)
+				findings = append(findings, f)
+			}
+		}
+	}
+	return findings
+}
+
+// splitPosition splits a file:line:column position.
+func splitPosition(posn string) (string, int, int) {
+	rest, col, ok := cutLastColon(posn)
+	if !ok {
+		return posn, 0, 0
+	}
+	path, line, ok := cutLastColon(rest)
+	if !ok {
+		// file:line
+		return rest, col, 0
+	}
+	return path, line, col
+}
+
+// cutLastColon splits s at its last colon, followed by a number.
+func cutLastColon(s string) (string, int, bool) {
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return s, 0, false
+	}
+	n, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return s, 0, false
+	}
+	return s[:i], n, true
+}
+
+// packagePattern returns the go command pattern of pkg: ./... for the whole
+// module, ./dir for a directory relative to root, pkg itself otherwise (an
+// import path or pattern).
+func packagePattern(root string, pkg string) string {
+	switch pkg {
+	case "":
+		return "./..."
+	case ".", "./...":
+		return pkg
+	}
+	dir := strings.TrimSuffix(pkg, "/...")
+	if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(dir))); err == nil && info.IsDir() && !filepath.IsAbs(dir) {
+		return "./" + filepath.ToSlash(filepath.Clean(pkg))
+	}
+	return pkg
+}
+
+// vetTool runs go vet on the project.
+func vetTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("vet",
+		mcp.WithDescription("Run go vet on a package or the whole module and return its findings (path, line, analyzer, message), and the packages failing to build. Use it to check Go code after editing it."),
+		mcp.WithString("package", mcp.Description("Directory relative to project root (e.g. internal/auth, internal/... for the packages under it), import path or pattern; the whole module by default")),
+		mcp.WithString("analyzers", mcp.Description("Comma-separated analyzers to run instead of the default set, e.g. \"printf,copylocks,lostcancel\" (see go tool vet help)")),
+		mcp.WithOutputSchema[VetReport](),
+		readOnlyAnnotations("Go vet"),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		var analyzers []string
+		for _, a := range strings.Split(request.GetString("analyzers", ""), ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				analyzers = append(analyzers, a)
+			}
+		}
+		report, err := Vet(ctx, rootPath, packagePattern(rootPath, request.GetString("package", "")), analyzers)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("go vet failed: %v", err)), nil
+		}
+		return jsonToolResult(report, report), nil
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// WatchInterval is how often --watch checks the project for changes.
+// Set via the --watch-interval flag.
+var WatchInterval = time.Second
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+// runWatch runs query, then again whenever a project file is created,
+// changed or deleted, until interrupted. Changes are detected by polling the
+// project fingerprint (paths, sizes and modification times), which keeps the
+// language servers warm between runs.
+func runWatch(query string, absPath string, opts SearchOptions, format string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	last := ""
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+	for {
+		fingerprint, err := RootsFingerprint(ctx, absPath)
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("cannot scan the project for changes", "err", err)
+		}
+		if err == nil && fingerprint != last {
+			last = fingerprint
+			start := time.Now()
+			results, err := Search(ctx, absPath, query, opts, nil)
+			if ctx.Err() != nil {
+				return
+			}
+			if format == FormatTable && isTerminal(os.Stdout) {
+				fmt.Print(clearScreen)
+			}
+			if isBackendError(err) {
+				slog.Warn("search incomplete", "err", err)
+			}
+			if err != nil && !isBackendError(err) {
+				slog.Error("search failed", "err", err)
+			} else {
+				duration := time.Since(start)
+				output := CLIOutput{
+					Query:    query,
+					Ref:      opts.Ref,
+					Worktree: FindWorktree(ctx, absPath),
+					Duration: duration.String(),
+					Count:    len(results),
+					Files:    results,
+				}
+				printOutput(output, absPath, LSP.Running(), duration, format)
+			}
+			if format == FormatTable && !Quiet {
+				fmt.Printf("\nWatching for changes (%s), Ctrl-C to stop\n", time.Now().Format(time.TimeOnly))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Worktree is the git working tree holding a search root.
+type Worktree struct {
+	Root   string `json:"root"`
+	Branch string `json:"branch,omitempty"` // Empty on a detached HEAD
+	Linked bool   `json:"linked,omitempty"` // Added by git worktree add
+}
+
+// inGitWorkTree reports whether dir is inside a git working tree: it or a
+// parent holds .git, a directory in the main working tree, a file in linked
+// ones.
+func inGitWorkTree(dir string) bool {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// FindWorktree returns the git working tree holding dir, or nil outside git
+// repositories.
+func FindWorktree(ctx context.Context, dir string) *Worktree {
+	if !inGitWorkTree(dir) {
+		return nil
+	}
+	out, err := runGit(ctx, dir, "rev-parse", "--show-toplevel", "--absolute-git-dir", "--git-common-dir")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if err != nil || len(lines) != 3 {
+		return nil
+	}
+	wt := &Worktree{Root: filepath.Clean(lines[0])}
+	// The common directory is relative to dir, unless absolute
+	common := lines[2]
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(dir, common)
+	}
+	wt.Linked = filepath.Clean(lines[1]) != filepath.Clean(common)
+	if branch, err := runGit(ctx, dir, "symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+		wt.Branch = strings.TrimSpace(branch)
+	}
+	return wt
+}
+
+// worktreeLabel returns the branch and kind of wt for the CLI header, e.g.
+// " (branch main, linked worktree /src/app-fix)".
+func worktreeLabel(wt *Worktree) string {
+	if wt == nil {
+		return ""
+	}
+	branch := "detached HEAD"
+	if wt.Branch != "" {
+		branch = "branch " + wt.Branch
+	}
+	if wt.Linked {
+		return fmt.Sprintf(" (%s, linked worktree %s)", branch, wt.Root)
+	}
+	return " (" + branch + ")"
+}
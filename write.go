@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Server modes. In read-only mode the write tools are not exposed at all.
+const (
+	ModeReadOnly  = "ro"
+	ModeReadWrite = "rw"
+)
+
+// Mode is the server mode, ModeReadOnly or ModeReadWrite.
+// Set via the --mode flag or CODEMCP_MODE only: a config file checked into a
+// repository cannot grant itself write access. Changed at runtime by SIGUSR1
+// (read-write), SIGUSR2 (read-only) and AdminEndpoint.
+var Mode = ModeReadOnly
+
+// writeToolNames are the tools registered in read-write mode.
+var writeToolNames = []string{"write_file", "edit_file", "apply_patch"}
+
+// writeTools returns the write tools, exposed by the ToolSet in read-write
+// mode only.
+func writeTools(rootPath string) []server.ServerTool {
+	return []server.ServerTool{
+		serverTool(writeFileTool(rootPath)),
+		serverTool(editFileTool(rootPath)),
+		serverTool(applyPatchTool(rootPath)),
+	}
+}
+
+func serverTool(tool mcp.Tool, handler server.ToolHandlerFunc) server.ServerTool {
+	return server.ServerTool{Tool: tool, Handler: handler}
+}
+
+// isWritablePath checks that target is inside the project root (symbolic
+// links resolved, dangling ones refused), outside .git, and neither denied
+// by read_access nor a secret file.
+func isWritablePath(rootPath string, target string) bool {
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return false
+	}
+	cleanTarget := filepath.Clean(absTarget)
+	if isDeniedPath(cleanTarget) {
+		return false
+	}
+	if _, ok := IsSecretFile(cleanTarget); ok {
+		return false
+	}
+
+	root, err := filepath.EvalSymlinks(rootPath)
+	if err != nil {
+		return false
+	}
+	// Resolve the deepest existing parent, the rest is created
+	existing, rest := cleanTarget, ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(existing); err == nil {
+			existing = filepath.Join(resolved, rest)
+			break
+		}
+		// A dangling symbolic link: writing follows it wherever it points
+		if _, err := os.Lstat(existing); err == nil {
+			return false
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return false
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+	rel, err := filepath.Rel(root, existing)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return false
+	}
+	return !slices.Contains(strings.Split(filepath.ToSlash(rel), "/"), ".git")
+}
+
+// writeFile writes content to path, creating its directory and keeping the
+// permissions of an existing file, then notifies the language servers.
+func writeFile(path string, content string) error {
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		return err
+	}
+	fileLSP(path).FileChanged(path)
+	return nil
+}
+
+// writeAnnotations returns the annotations of a tool changing project files:
+// it may overwrite or delete content, so clients should ask for confirmation.
+// idempotent tells whether repeating a call has no further effect.
+func writeAnnotations(title string, idempotent bool) mcp.ToolOption {
+	return mcp.WithToolAnnotation(mcp.ToolAnnotation{
+		Title:           title,
+		ReadOnlyHint:    mcp.ToBoolPtr(false),
+		DestructiveHint: mcp.ToBoolPtr(true),
+		IdempotentHint:  mcp.ToBoolPtr(idempotent),
+		OpenWorldHint:   mcp.ToBoolPtr(false),
+	})
+}
+
+// writeFileTool returns the write_file tool and its handler.
+func writeFileTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("write_file",
+		mcp.WithDescription("Create or overwrite a file inside the project root with the given content. Prefer edit_file or apply_patch to change part of an existing file."),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Path of the file, relative to project root (or absolute inside it)")),
+		mcp.WithString("content", mcp.Required(), mcp.Description("Full content of the file")),
+		mcp.WithBoolean("confirm", mcp.Description("Set once the user approved the changes, for clients without MCP elicitation: without it, the call returns the changes to approve")),
+		writeAnnotations("Write file", true),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		pathArg, _ := request.RequireString("path")
+		content, err := request.RequireString("content")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		targetPath := resolvePath(rootPath, pathArg)
+		if !isWritablePath(rootPath, targetPath) {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Writing file %s is not allowed. Scope restricted to project root.", pathArg)), nil
+		}
+		summary := fmt.Sprintf("A %s (%d bytes)", pathArg, len(content))
+		if _, err := os.Stat(targetPath); err == nil {
+			summary = fmt.Sprintf("M %s (overwritten, %d bytes)", pathArg, len(content))
+		}
+		if result := confirmChanges(ctx, request, summary); result != nil {
+			return result, nil
+		}
+		if err := writeFile(targetPath, content); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Wrote %d bytes to %s", len(content), pathArg)), nil
+	}
+}
+
+// editFileTool returns the edit_file tool and its handler.
+func editFileTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("edit_file",
+		mcp.WithDescription("Replace an exact string of a file inside the project root. old_string must appear exactly once, unless replace_all is set: include enough surrounding lines to make it unique."),
+		mcp.WithString("path", mcp.Required(), mcp.Description("Path of the file, relative to project root (or absolute inside it)")),
+		mcp.WithString("old_string", mcp.Required(), mcp.Description("Exact text to replace, including indentation")),
+		mcp.WithString("new_string", mcp.Required(), mcp.Description("Replacement text")),
+		mcp.WithBoolean("replace_all", mcp.Description("Replace every occurrence of old_string. Off by default.")),
+		mcp.WithBoolean("confirm", mcp.Description("Set once the user approved the changes, for clients without MCP elicitation: without it, the call returns the changes to approve")),
+		writeAnnotations("Edit file", false),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		pathArg, _ := request.RequireString("path")
+		oldString, err := request.RequireString("old_string")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		newString, err := request.RequireString("new_string")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		targetPath := resolvePath(rootPath, pathArg)
+		if !isWritablePath(rootPath, targetPath) {
+			return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Writing file %s is not allowed. Scope restricted to project root.", pathArg)), nil
+		}
+		if oldString == "" || oldString == newString {
+			return mcp.NewToolResultError("old_string must be non-empty and differ from new_string"), nil
+		}
+
+		content, err := os.ReadFile(targetPath)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		count := strings.Count(string(content), oldString)
+		switch {
+		case count == 0:
+			return mcp.NewToolResultError(fmt.Sprintf("old_string not found in %s", pathArg)), nil
+		case count > 1 && !request.GetBool("replace_all", false):
+			return mcp.NewToolResultError(fmt.Sprintf("old_string found %d times in %s: add context to make it unique, or set replace_all", count, pathArg)), nil
+		}
+		if result := confirmChanges(ctx, request, fmt.Sprintf("M %s (%d occurrence(s) replaced)", pathArg, count)); result != nil {
+			return result, nil
+		}
+		if err := writeFile(targetPath, strings.ReplaceAll(string(content), oldString, newString)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Replaced %d occurrence(s) in %s", count, pathArg)), nil
+	}
+}
+
+// applyPatchTool returns the apply_patch tool and its handler.
+func applyPatchTool(rootPath string) (mcp.Tool, server.ToolHandlerFunc) {
+	tool := mcp.NewTool("apply_patch",
+		mcp.WithDescription("Apply a unified diff (git diff or diff -u format) to files inside the project root. It may create (--- /dev/null), delete (+++ /dev/null) and rename files. Nothing is written unless every hunk applies and the user approves the changes."),
+		mcp.WithString("patch", mcp.Required(), mcp.Description("Unified diff, paths relative to project root (a/ and b/ prefixes accepted)")),
+		mcp.WithBoolean("confirm", mcp.Description("Set once the user approved the changes, for clients without MCP elicitation: without it, the call returns the changes to approve")),
+		writeAnnotations("Apply patch", false),
+	)
+
+	return tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rootPath := projectRoot(ctx, rootPath)
+		patch, err := request.RequireString("patch")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		files, err := ParsePatch(patch)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid patch: %v", err)), nil
+		}
+
+		// Compute every change before writing anything
+		type change struct {
+			path    string
+			content string
+			delete  bool
+			renamed string // Old path of a renamed file, removed once written
+			summary string
+		}
+		var changes []change
+		for _, fp := range files {
+			for _, p := range []string{fp.OldPath, fp.NewPath} {
+				if p != "" && !isWritablePath(rootPath, resolvePath(rootPath, p)) {
+					return mcp.NewToolResultError(fmt.Sprintf("Access Denied: Writing file %s is not allowed. Scope restricted to project root.", p)), nil
+				}
+			}
+			var content string
+			if fp.OldPath != "" {
+				data, err := os.ReadFile(resolvePath(rootPath, fp.OldPath))
+				if err != nil {
+					return mcp.NewToolResultError(err.Error()), nil
+				}
+				content = string(data)
+			}
+			if fp.NewPath == "" {
+				changes = append(changes, change{path: resolvePath(rootPath, fp.OldPath), delete: true, summary: "D " + fp.OldPath})
+				continue
+			}
+			newContent, err := fp.Apply(content)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("%s: %v", fp.NewPath, err)), nil
+			}
+			c := change{path: resolvePath(rootPath, fp.NewPath), content: newContent, summary: "M " + fp.NewPath}
+			switch {
+			case fp.OldPath == "":
+				c.summary = "A " + fp.NewPath
+			case resolvePath(rootPath, fp.OldPath) != c.path:
+				c.renamed = resolvePath(rootPath, fp.OldPath)
+				c.summary = "R " + fp.OldPath + " -> " + fp.NewPath
+			}
+			changes = append(changes, c)
+		}
+
+		var summaries []string
+		for _, c := range changes {
+			summaries = append(summaries, c.summary)
+		}
+		if result := confirmChanges(ctx, request, strings.Join(summaries, "\n")); result != nil {
+			return result, nil
+		}
+
+		for _, c := range changes {
+			if c.delete {
+				if err := os.Remove(c.path); err != nil {
+					return mcp.NewToolResultError(err.Error()), nil
+				}
+				fileLSP(c.path).FileChanged(c.path)
+				continue
+			}
+			if err := writeFile(c.path, c.content); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			if c.renamed != "" {
+				if err := os.Remove(c.renamed); err != nil {
+					return mcp.NewToolResultError(err.Error()), nil
+				}
+				fileLSP(c.renamed).FileChanged(c.renamed)
+			}
+		}
+		return mcp.NewToolResultText(strings.Join(summaries, "\n")), nil
+	}
+}
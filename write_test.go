@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestIsWritablePath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(os.WriteFile(filepath.Join(root, "main.go"), nil, 0o644))
+	must(os.WriteFile(filepath.Join(outside, "victim.txt"), nil, 0o644))
+	must(os.Mkdir(filepath.Join(root, "pkg"), 0o755))
+	must(os.Mkdir(filepath.Join(root, ".git"), 0o755))
+	must(os.Symlink(filepath.Join(root, "main.go"), filepath.Join(root, "inner")))
+	must(os.Symlink(outside, filepath.Join(root, "outdir")))
+	must(os.Symlink(filepath.Join(outside, "victim.txt"), filepath.Join(root, "outfile")))
+	must(os.Symlink(filepath.Join(outside, "missing.txt"), filepath.Join(root, "dangling")))
+	must(os.Symlink(filepath.Join(outside, "missing"), filepath.Join(root, "danglingdir")))
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"main.go", true},
+		{"pkg/new.go", true},
+		{"new/dir/file.go", true},
+		{"inner", true},
+		{".", false},
+		{"../escape.go", false},
+		{"pkg/../../escape.go", false},
+		{filepath.Join(outside, "victim.txt"), false},
+		{".git/config", false},
+		{".env", false},
+		{"outdir/victim.txt", false},
+		{"outdir/new.txt", false},
+		{"outfile", false},
+		{"dangling", false},
+		{"danglingdir/new.txt", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			target := tt.path
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(root, target)
+			}
+			if got := isWritablePath(root, target); got != tt.want {
+				t.Errorf("isWritablePath(%s) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// decliningSession is a client session supporting elicitation whose user
+// declines every request.
+type decliningSession struct {
+	asked int
+}
+
+func (s *decliningSession) Initialize()                                         {}
+func (s *decliningSession) Initialized() bool                                   { return true }
+func (s *decliningSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return nil }
+func (s *decliningSession) SessionID() string                                   { return "declining" }
+func (s *decliningSession) GetClientInfo() mcp.Implementation                   { return mcp.Implementation{} }
+func (s *decliningSession) SetClientInfo(mcp.Implementation)                    {}
+func (s *decliningSession) SetClientCapabilities(mcp.ClientCapabilities)        {}
+
+func (s *decliningSession) GetClientCapabilities() mcp.ClientCapabilities {
+	return mcp.ClientCapabilities{Elicitation: &struct{}{}}
+}
+
+func (s *decliningSession) RequestElicitation(context.Context, mcp.ElicitationRequest) (*mcp.ElicitationResult, error) {
+	s.asked++
+	return &mcp.ElicitationResult{ElicitationResponse: mcp.ElicitationResponse{Action: mcp.ElicitationResponseActionDecline}}, nil
+}
+
+func TestWriteFileDeclined(t *testing.T) {
+	root := t.TempDir()
+	_, handler := writeFileTool(root)
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "write_file"
+	request.Params.Arguments = map[string]any{"path": "new.go", "content": "package main\n"}
+
+	session := &decliningSession{}
+	ctx := server.NewMCPServer("test", "1").WithContext(context.Background(), session)
+	result, err := handler(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError || session.asked != 1 {
+		t.Errorf("declined write: error = %v, asked %d times, want an error after one request", result.IsError, session.asked)
+	}
+	// Without elicitation nor confirm, the changes come back to approve
+	result, err = handler(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; !result.IsError || !strings.Contains(text, "A new.go") {
+		t.Errorf("unconfirmed write = %q, want the changes to approve", text)
+	}
+	if _, err := os.Stat(filepath.Join(root, "new.go")); !os.IsNotExist(err) {
+		t.Errorf("new.go written without approval: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// zigLanguage describes zls, used for projects with a build.zig.
+func zigLanguage() Language {
+	return Language{
+		Name:        "zig",
+		Command:     []string{"zls"},
+		VersionArgs: []string{"--version"},
+		Markers:     []string{"build.zig", "build.zig.zon"},
+		Classify: func(root string, path string) (bool, bool) {
+			// Filter out the Zig standard library.
+			if strings.Contains(path, "/lib/zig/std/") || strings.Contains(path, "/lib/std/") {
+				return true, false
+			}
+			return false, strings.Contains(path, "/zig/p/")
+		},
+		ReadPaths: func(root string) []string {
+			// Fetched build.zig.zon dependencies live in the global package cache
+			if dir := zigCacheDir(); dir != "" {
+				return []string{filepath.Join(dir, "p")}
+			}
+			return nil
+		},
+	}
+}
+
+// zigCacheDir returns the Zig global cache ($ZIG_GLOBAL_CACHE_DIR or ~/.cache/zig).
+func zigCacheDir() string {
+	if dir := os.Getenv("ZIG_GLOBAL_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "zig")
+	}
+	return ""
+}